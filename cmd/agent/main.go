@@ -8,6 +8,7 @@ import (
 
 	"github.com/prbllm/go-metrics/internal/agent"
 	"github.com/prbllm/go-metrics/internal/config"
+	"github.com/prbllm/go-metrics/internal/tenant"
 )
 
 func main() {
@@ -18,6 +19,28 @@ func main() {
 	}
 
 	collector := &agent.RuntimeMetricsCollector{}
-	agent := agent.NewAgent(http.DefaultClient, collector, "http://"+config.GetConfig().ServerHost+config.UpdatePath, config.GetConfig().AgentPollInterval, config.GetConfig().AgentReportInterval)
+	var opts []agent.Option
+	if token := config.GetConfig().AgentToken; token != "" {
+		opts = append(opts, agent.WithToken(token))
+	}
+	if name := config.GetConfig().AgentTenant; name != "" {
+		if err := tenant.ValidateName(name); err != nil {
+			fmt.Println("Error initializing config: ", err)
+			os.Exit(1)
+		}
+		opts = append(opts, agent.WithTenant(name))
+	}
+	if targets := config.GetConfig().AgentScrapeTargets; len(targets) > 0 {
+		opts = append(opts, agent.WithScraper(agent.NewScraper(http.DefaultClient, targets, config.GetConfig().AgentScrapeTimeout)))
+	}
+	agent := agent.NewAgent(http.DefaultClient, collector, "http://"+config.GetConfig().ServerHost+config.UpdatePath, config.GetConfig().AgentPollInterval, config.GetConfig().AgentReportInterval, opts...)
+	if address := config.GetConfig().AgentStatusAddress; address != "" {
+		go func() {
+			fmt.Println("Agent status listener starting on ", address)
+			if err := http.ListenAndServe(address, agent.StatusHandler(config.GetConfig().AgentMaxFailures)); err != nil {
+				fmt.Println("Error starting agent status listener: ", err)
+			}
+		}()
+	}
 	agent.Start(context.Background())
 }
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/prbllm/go-metrics/internal/metricsctl"
+)
+
+func main() {
+	os.Exit(metricsctl.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
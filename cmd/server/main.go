@@ -1,14 +1,29 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"regexp"
+	"time"
 
+	"github.com/prbllm/go-metrics/internal/alerting"
+	"github.com/prbllm/go-metrics/internal/audit"
+	"github.com/prbllm/go-metrics/internal/auth"
 	"github.com/prbllm/go-metrics/internal/config"
+	"github.com/prbllm/go-metrics/internal/graphite"
 	"github.com/prbllm/go-metrics/internal/handler"
+	"github.com/prbllm/go-metrics/internal/influx"
+	"github.com/prbllm/go-metrics/internal/otlp"
+	"github.com/prbllm/go-metrics/internal/ratelimit"
+	"github.com/prbllm/go-metrics/internal/recording"
+	"github.com/prbllm/go-metrics/internal/remotewrite"
 	"github.com/prbllm/go-metrics/internal/repository"
+	"github.com/prbllm/go-metrics/internal/selfmetrics"
 	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/prbllm/go-metrics/internal/tenant"
+	"github.com/prbllm/go-metrics/internal/tsdb"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -20,20 +35,179 @@ func main() {
 		os.Exit(1)
 	}
 
-	storage := repository.NewMemStorage()
-	metricsService := service.NewMetricsService(storage)
-	handlers := handler.NewHandlers(metricsService)
+	selfMetrics := selfmetrics.NewRegistry()
+	var memStorage repository.MetricsRepository = repository.NewMemStorage()
+	if dir := config.GetConfig().StorageDir; dir != "" {
+		durableStorage, err := repository.NewDurableStorage(memStorage, dir, config.GetConfig().WALSync)
+		if err != nil {
+			fmt.Println("Error restoring storage: ", err)
+			os.Exit(1)
+		}
+		go durableStorage.Run(context.Background(), config.GetConfig().SnapshotInterval)
+		memStorage = durableStorage
+	}
+	limitedStorage := repository.NewLimitedStorage(memStorage, repository.CardinalityLimits{
+		MaxSeries:        config.GetConfig().MaxSeries,
+		MaxSeriesPerName: config.GetConfig().MaxSeriesPerName,
+		MaxNewSeries:     config.GetConfig().MaxNewSeries,
+		NewSeriesWindow:  config.GetConfig().NewSeriesWindow,
+	})
+	var historyStorage *repository.HistoryStorage
+	var repositoryStorage repository.MetricsRepository = limitedStorage
+	if retention := config.GetConfig().HistoryRetention; retention > 0 {
+		history, err := tsdb.NewHistory(retention, config.GetConfig().HistoryTiers)
+		if err != nil {
+			fmt.Println("Error initializing history: ", err)
+			os.Exit(1)
+		}
+		go history.Run(context.Background(), config.GetConfig().HistoryCompactInterval)
+		historyStorage = repository.NewHistoryStorage(limitedStorage, history)
+		repositoryStorage = historyStorage
+	}
+	storage := repository.NewCompositeStorage(repositoryStorage, selfMetrics)
+
+	namingPolicy, err := service.NewNamingPolicy(config.GetConfig().NameAllowedChars, config.GetConfig().NameMaxLength, config.GetConfig().ReservedPrefixes, config.GetConfig().SanitizeNames)
+	if err != nil {
+		fmt.Println("Error initializing naming policy: ", err)
+		os.Exit(1)
+	}
+
+	metricsService := service.NewMetricsService(storage,
+		service.WithHistogramBuckets(config.GetConfig().HistogramBuckets),
+		service.WithNamingPolicy(namingPolicy),
+		service.WithIngestionObserver(selfMetrics.ObserveIngested),
+	)
+	var handlerOptions []handler.Option
+	var auditSink audit.Sink
+	if path := config.GetConfig().AuditFile; path != "" {
+		auditSink, err = audit.NewFileSink(path)
+		if err != nil {
+			fmt.Println("Error opening audit log: ", err)
+			os.Exit(1)
+		}
+	} else if url := config.GetConfig().AuditWebhook; url != "" {
+		auditSink = audit.NewWebhookSink(&http.Client{Timeout: 10 * time.Second}, url)
+	}
+	if auditSink != nil {
+		auditLog := audit.NewLogger(auditSink, config.GetConfig().AuditBufferSize, func(reason string, n int) {
+			selfMetrics.Add(selfmetrics.AuditDroppedEvents, map[string]string{"reason": reason}, int64(n))
+		})
+		handlerOptions = append(handlerOptions, handler.WithAuditLog(auditLog))
+	}
+
+	handlers := handler.NewHandlers(metricsService, handlerOptions...)
+
+	var influxRule influx.Rule
+	if pattern := config.GetConfig().InfluxCounterPattern; pattern != "" {
+		influxRule.CounterPattern = regexp.MustCompile(pattern)
+	}
+	influxHandler := handler.NewInfluxHandler(metricsService, influx.NewReceiver(influxRule), handlerOptions...)
+	otlpHandler := handler.NewOTLPHandler(metricsService, otlp.NewReceiver(config.GetConfig().OTLPPrefixAttributes), handlerOptions...)
+	transferHandler := handler.NewTransferHandler(storage, namingPolicy, handlerOptions...)
+	remoteWriteHandler := handler.NewRemoteWriteHandler(metricsService, remotewrite.NewReceiver(), handlerOptions...)
+	var alertsHandler *handler.AlertsHandler
+	if path := config.GetConfig().AlertRulesFile; path != "" {
+		alertConfig, err := alerting.LoadConfig(path)
+		if err != nil {
+			fmt.Println("Error loading alerting rules: ", err)
+			os.Exit(1)
+		}
+		notifier := alerting.NewWebhookNotifier(&http.Client{Timeout: 10 * time.Second}, alertConfig.Webhooks)
+		engine := alerting.NewEngine(metricsService, alertConfig.Rules, notifier)
+		alertsHandler = handler.NewAlertsHandler(engine)
+		fmt.Printf("Evaluating %d alerting rules every %v\n", len(alertConfig.Rules), config.GetConfig().AlertInterval)
+		go engine.Run(context.Background(), config.GetConfig().AlertInterval)
+	}
+
+	if path := config.GetConfig().RecordingRulesFile; path != "" {
+		recordingConfig, err := recording.LoadConfig(path, namingPolicy)
+		if err != nil {
+			fmt.Println("Error loading recording rules: ", err)
+			os.Exit(1)
+		}
+		// Результаты правил пишет сам сервер, поэтому они не попадают в журнал аудита.
+		evaluator := recording.NewEvaluator(metricsService, recordingConfig.Rules)
+		fmt.Printf("Evaluating %d recording rules every %v\n", len(recordingConfig.Rules), config.GetConfig().RecordingInterval)
+		go evaluator.Run(context.Background(), config.GetConfig().RecordingInterval)
+	}
+
+	tenantResolver, err := tenant.NewResolver(config.GetConfig().TenantTokens)
+	if err != nil {
+		fmt.Println("Error initializing tenant resolver: ", err)
+		os.Exit(1)
+	}
+
+	var authTokens []*auth.Token
+	if path := config.GetConfig().AuthTokensFile; path != "" {
+		authConfig, err := auth.LoadConfig(path)
+		if err != nil {
+			fmt.Println("Error loading auth tokens: ", err)
+			os.Exit(1)
+		}
+		authTokens = authConfig.Tokens
+		fmt.Printf("Authentication enabled with %d tokens\n", len(authTokens))
+	}
+	authenticator := auth.NewAuthenticator(authTokens)
+	writeLimiter := ratelimit.NewLimiter(config.GetConfig().WriteRateLimit, config.GetConfig().WriteRateBurst)
+	readLimiter := ratelimit.NewLimiter(config.GetConfig().ReadRateLimit, config.GetConfig().ReadRateBurst)
+	writeLimit := writeLimiter.Middleware(func() { selfMetrics.ObserveThrottled("write") })
+	readLimit := readLimiter.Middleware(func() { selfMetrics.ObserveThrottled("read") })
+
+	read := chi.Chain(authenticator.Require(auth.ScopeRead), readLimit)
+	write := chi.Chain(authenticator.Require(auth.ScopeWrite), writeLimit)
+	admin := chi.Chain(authenticator.Require(auth.ScopeAdmin), writeLimit)
+
 	router := chi.NewRouter()
+	router.Use(selfMetrics.Middleware)
 	router.Route(config.CommonPath, func(r chi.Router) {
-		r.Get("/", handlers.GetAllMetricsHandler)
-		r.Route(config.UpdatePath, func(r chi.Router) {
-			r.Post("/{metricType}/{metricName}/{metricValue}", handlers.UpdateMetricHandler)
-		})
-		r.Route(config.ValuePath, func(r chi.Router) {
-			r.Get("/{metricType}/{metricName}", handlers.GetValueHandler)
+		// Проверки состояния не зависят от арендатора и доступны без токена.
+		r.Get(config.PingPath, handlers.ReadinessHandler)
+		r.Get(config.LivenessPath, handlers.LivenessHandler)
+		r.Get(config.ReadinessPath, handlers.ReadinessHandler)
+
+		r.Group(func(r chi.Router) {
+			r.Use(tenantResolver.Middleware)
+			r.With(read...).Get("/", handlers.GetAllMetricsHandler)
+			r.With(read...).Get(config.MetricsPath, handlers.GetPrometheusMetricsHandler)
+			r.With(write...).Post(config.WritePath, influxHandler.WriteHandler)
+			r.With(write...).Post(config.OTLPMetricsPath, otlpHandler.MetricsHandler)
+			r.With(write...).Post(config.RemoteWritePath, remoteWriteHandler.WriteHandler)
+			if alertsHandler != nil {
+				r.With(read...).Get(config.AlertsPath, alertsHandler.ListHandler)
+			}
+			if historyStorage != nil {
+				r.With(read...).Get(config.HistoryPath+"/{metricType}/{metricName}", handler.NewHistoryHandler(historyStorage, namingPolicy).QueryHandler)
+			}
+			r.Route(config.UpdatePath, func(r chi.Router) {
+				r.Use(write...)
+				r.Post("/", handlers.UpdateMetricJSONHandler)
+				r.Post("/{metricType}/{metricName}/{metricValue}", handlers.UpdateMetricHandler)
+			})
+			r.Route(config.UpdatesPath, func(r chi.Router) {
+				r.Use(write...)
+				r.Post("/", handlers.UpdateMetricsBatchHandler)
+			})
+			r.With(admin...).Get(config.AdminExportPath, transferHandler.ExportHandler)
+			r.With(admin...).Post(config.AdminImportPath, transferHandler.ImportHandler)
+			r.Route(config.ValuePath, func(r chi.Router) {
+				r.With(read...).Post("/", handlers.GetValueJSONHandler)
+				r.With(read...).Get("/{metricType}/{metricName}", handlers.GetValueHandler)
+				r.With(admin...).Delete("/{metricType}/{metricName}", handlers.DeleteMetricHandler)
+			})
 		})
 	})
 
+	if address := config.GetConfig().GraphiteAddress; address != "" {
+		// Протокол Graphite не передает токен и арендатора, запись через него не аудируется.
+		graphiteServer := graphite.NewServer(metricsService, config.GetConfig().GraphiteMaxConnections, config.GetConfig().GraphiteIdleTimeout)
+		go func() {
+			fmt.Println("Graphite listener starting on ", address)
+			if err := graphiteServer.ListenAndServe(address); err != nil {
+				fmt.Println("Error starting graphite listener: ", err)
+			}
+		}()
+	}
+
 	fmt.Println("Server starting on ", config.GetConfig().ServerHost)
 	err = http.ListenAndServe(config.GetConfig().ServerHost, router)
 	if err != nil {
@@ -4,14 +4,18 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/prbllm/go-metrics/internal/config"
 	"github.com/prbllm/go-metrics/internal/handler"
+	"github.com/prbllm/go-metrics/internal/influx"
 	"github.com/prbllm/go-metrics/internal/model"
 	"github.com/prbllm/go-metrics/internal/repository"
 	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/prbllm/go-metrics/pkg/client"
 	"github.com/stretchr/testify/require"
 
 	"github.com/go-chi/chi/v5"
@@ -21,15 +25,27 @@ func TestFullIntegration(t *testing.T) {
 	storage := repository.NewMemStorage()
 	metricsService := service.NewMetricsService(storage)
 	handlers := handler.NewHandlers(metricsService)
+	influxHandler := handler.NewInfluxHandler(metricsService, influx.NewReceiver(influx.Rule{CounterPattern: regexp.MustCompile(`_total$`)}))
 
 	router := chi.NewRouter()
 	router.Route(config.CommonPath, func(r chi.Router) {
 		r.Get("/", handlers.GetAllMetricsHandler)
+		r.Get(config.MetricsPath, handlers.GetPrometheusMetricsHandler)
+		r.Get(config.PingPath, handlers.ReadinessHandler)
+		r.Get(config.LivenessPath, handlers.LivenessHandler)
+		r.Get(config.ReadinessPath, handlers.ReadinessHandler)
+		r.Post(config.WritePath, influxHandler.WriteHandler)
 		r.Route(config.UpdatePath, func(r chi.Router) {
+			r.Post("/", handlers.UpdateMetricJSONHandler)
 			r.Post("/{metricType}/{metricName}/{metricValue}", handlers.UpdateMetricHandler)
 		})
+		r.Route(config.UpdatesPath, func(r chi.Router) {
+			r.Post("/", handlers.UpdateMetricsBatchHandler)
+		})
 		r.Route(config.ValuePath, func(r chi.Router) {
+			r.Post("/", handlers.GetValueJSONHandler)
 			r.Get("/{metricType}/{metricName}", handlers.GetValueHandler)
+			r.Delete("/{metricType}/{metricName}", handlers.DeleteMetricHandler)
 		})
 	})
 
@@ -145,7 +161,8 @@ func TestFullIntegration(t *testing.T) {
 		body, err := io.ReadAll(resp.Body)
 		require.NoError(t, err, "Failed to read response body")
 		require.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"), "Expected content type %s, got %s", "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
-		require.Contains(t, string(body), "test_all_metrics_counter: 10")
+		require.Contains(t, string(body), `<td><a href="/value/counter/test_all_metrics_counter">test_all_metrics_counter</a></td>`)
+		require.Regexp(t, `(?s)test_all_metrics_counter</a></td>\s*<td></td>\s*<td class="value">10</td>`, string(body))
 	})
 
 	t.Run("get value", func(t *testing.T) {
@@ -170,6 +187,68 @@ func TestFullIntegration(t *testing.T) {
 		require.Equal(t, "10", string(body), "Expected body 10, got %s", string(body))
 	})
 
+	t.Run("labels", func(t *testing.T) {
+		for _, path := range []string{"/update/counter/labeled/1?host=a", "/update/counter/labeled/2?host=b", "/update/counter/labeled/3?host=a"} {
+			resp, err := http.Post(server.URL+path, "text/plain", nil)
+			require.NoError(t, err, "Failed to send request")
+			resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode, "Expected status 200, got %d", resp.StatusCode)
+		}
+
+		resp, err := http.Get(server.URL + "/value/counter/labeled?host=a")
+		require.NoError(t, err, "Failed to send request")
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err, "Failed to read response body")
+		require.Equal(t, "4", string(body))
+
+		resp, err = http.Get(server.URL + "/metrics?match=host=b")
+		require.NoError(t, err, "Failed to send request")
+		body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err, "Failed to read response body")
+		require.Equal(t, "# TYPE labeled counter\nlabeled{host=\"b\"} 2\n", string(body))
+	})
+
+	t.Run("client batch", func(t *testing.T) {
+		c := client.New(server.URL, client.WithFlushInterval(0), client.WithLabels(map[string]string{"app": "test"}))
+		c.Counter("client_requests", nil).Add(2)
+		c.Gauge("client_queue", nil).Set(1.5)
+		require.NoError(t, c.Close(), "Failed to flush metrics")
+
+		metric, err := storage.GetMetric(&model.Metrics{MType: model.Counter, ID: "client_requests", Labels: map[string]string{"app": "test"}})
+		require.NoError(t, err, "Expected metric to be saved")
+		require.Equal(t, int64(2), *metric.Delta)
+
+		metric, err = storage.GetMetric(&model.Metrics{MType: model.Gauge, ID: "client_queue", Labels: map[string]string{"app": "test"}})
+		require.NoError(t, err, "Expected metric to be saved")
+		require.Equal(t, 1.5, *metric.Value)
+
+		resp, err := http.Post(server.URL+"/updates/", "application/json", strings.NewReader(`[{"id":"ok","type":"gauge","value":1},{"id":"bad","type":"gauge"}]`))
+		require.NoError(t, err, "Failed to send request")
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		_, err = storage.GetMetric(&model.Metrics{MType: model.Gauge, ID: "ok"})
+		require.Error(t, err, "Invalid batch must not be partially saved")
+	})
+
+	t.Run("influx write", func(t *testing.T) {
+		// Целые counter накопительные: первое значение - точка отсчета, дальше прибавляется прирост.
+		body := "http,host=a requests_total=3i,latency=0.2\nhttp,host=a requests_total=8i\nbroken line\n"
+		resp, err := http.Post(server.URL+"/write", "text/plain", strings.NewReader(body))
+		require.NoError(t, err, "Failed to send request")
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, "Broken line must be reported")
+
+		metric, err := storage.GetMetric(&model.Metrics{MType: model.Counter, ID: "http_requests_total", Labels: map[string]string{"host": "a"}})
+		require.NoError(t, err, "Expected valid lines to be saved")
+		require.Equal(t, int64(5), *metric.Delta)
+
+		metric, err = storage.GetMetric(&model.Metrics{MType: model.Gauge, ID: "http_latency", Labels: map[string]string{"host": "a"}})
+		require.NoError(t, err, "Expected valid lines to be saved")
+		require.Equal(t, 0.2, *metric.Value)
+	})
+
 	t.Run("error cases", func(t *testing.T) {
 		testCases := []struct {
 			name           string
@@ -201,6 +280,18 @@ func TestFullIntegration(t *testing.T) {
 				method:         http.MethodPost,
 				expectedStatus: http.StatusBadRequest,
 			},
+			{
+				name:           "invalid metric name",
+				path:           "/update/counter/bad%20name/1",
+				method:         http.MethodPost,
+				expectedStatus: http.StatusBadRequest,
+			},
+			{
+				name:           "reserved metric name",
+				path:           "/update/gauge/gometrics_series_count/1",
+				method:         http.MethodPost,
+				expectedStatus: http.StatusBadRequest,
+			},
 		}
 
 		for _, tc := range testCases {
@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/tenant"
 )
 
 type Agent struct {
@@ -16,16 +19,49 @@ type Agent struct {
 	route          string
 	pollInterval   time.Duration
 	reportInterval time.Duration
+	status         *statusTracker
+	scraper        *Scraper
+	tenant         string
+	token          string
 }
 
-func NewAgent(client *http.Client, collector *RuntimeMetricsCollector, route string, pollInterval time.Duration, reportInterval time.Duration) *Agent {
-	return &Agent{
+type Option func(*Agent)
+
+// WithScraper добавляет опрос целей Prometheus. Цели опрашиваются один раз за интервал
+// отправки, непосредственно перед ней.
+func WithScraper(scraper *Scraper) Option {
+	return func(a *Agent) {
+		a.scraper = scraper
+	}
+}
+
+// WithTenant отправляет метрики в пространство арендатора через заголовок X-Tenant-ID.
+func WithTenant(tenant string) Option {
+	return func(a *Agent) {
+		a.tenant = tenant
+	}
+}
+
+// WithToken передает API-токен в заголовке Authorization: Bearer.
+func WithToken(token string) Option {
+	return func(a *Agent) {
+		a.token = token
+	}
+}
+
+func NewAgent(client *http.Client, collector *RuntimeMetricsCollector, route string, pollInterval time.Duration, reportInterval time.Duration, opts ...Option) *Agent {
+	agent := &Agent{
 		client:         client,
 		collector:      collector,
 		route:          route,
 		pollInterval:   pollInterval,
 		reportInterval: reportInterval,
+		status:         newStatusTracker(),
 	}
+	for _, opt := range opts {
+		opt(agent)
+	}
+	return agent
 }
 
 func (a *Agent) Start(context context.Context) {
@@ -53,8 +89,13 @@ func (a *Agent) Start(context context.Context) {
 			default:
 			}
 			metrics = a.collector.Collect()
+			a.status.collected(metrics)
 			time.Sleep(a.pollInterval)
 		}
+		if a.scraper != nil {
+			metrics = append(metrics, a.scraper.Collect()...)
+			a.status.collected(metrics)
+		}
 		err := a.sendMetrics(metrics)
 		if err != nil {
 			fmt.Println("Error sending metrics: ", err)
@@ -67,6 +108,8 @@ func (a *Agent) sendMetrics(metrics []model.Metrics) error {
 		return fmt.Errorf("client is nil")
 	}
 
+	failed := 0
+	var lastErr error
 	for _, metric := range metrics {
 		url, err := a.generateURL(metric)
 		if err != nil {
@@ -74,17 +117,39 @@ func (a *Agent) sendMetrics(metrics []model.Metrics) error {
 			continue
 		}
 		fmt.Println("Sending metric: ", metric.String(), "to url: ", url)
-		response, err := a.client.Post(url, "text/plain", strings.NewReader(""))
+		response, err := a.post(url)
 		if err != nil {
 			fmt.Println("Error sending metric: ", err, ". Skipping...")
+			failed++
+			lastErr = err
 			continue
 		}
 		fmt.Println("Response: ", response.Status)
 		response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			failed++
+			lastErr = fmt.Errorf("metric %s rejected: %s", metric.ID, response.Status)
+		}
 	}
+	a.status.sent(failed, lastErr)
 	return nil
 }
 
+func (a *Agent) post(url string) (*http.Response, error) {
+	request, err := http.NewRequest(http.MethodPost, url, strings.NewReader(""))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "text/plain")
+	if a.tenant != "" {
+		request.Header.Set(tenant.Header, a.tenant)
+	}
+	if a.token != "" {
+		request.Header.Set("Authorization", "Bearer "+a.token)
+	}
+	return a.client.Do(request)
+}
+
 func (a *Agent) generateURL(metric model.Metrics) (string, error) {
 	var value string
 
@@ -97,12 +162,20 @@ func (a *Agent) generateURL(metric model.Metrics) (string, error) {
 		if metric.Value == nil {
 			return "", fmt.Errorf("metric %s has no value", metric.ID)
 		}
-		value = fmt.Sprintf("%f", *metric.Value)
+		value = strconv.FormatFloat(*metric.Value, 'g', -1, 64)
 	}
 
-	url := a.route
-	if url[len(url)-1] != '/' {
-		url += "/"
+	route := a.route
+	if route[len(route)-1] != '/' {
+		route += "/"
+	}
+	result := fmt.Sprintf("%s%s/%s/%s", route, metric.MType, url.PathEscape(metric.ID), value)
+	if len(metric.Labels) > 0 {
+		query := url.Values{}
+		for name, value := range metric.Labels {
+			query.Set(name, value)
+		}
+		result += "?" + query.Encode()
 	}
-	return fmt.Sprintf("%s%s/%s/%s", url, metric.MType, metric.ID, value), nil
+	return result, nil
 }
@@ -1,7 +1,10 @@
 package agent
 
 import (
+	"encoding/json"
 	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 
 	"github.com/prbllm/go-metrics/internal/model"
@@ -10,6 +13,7 @@ import (
 
 func TestAgentGenerateUrl(t *testing.T) {
 	commonValue := float64(1.0)
+	smallValue := 1.5e-9
 	commonDelta := int64(1)
 
 	testData := []struct {
@@ -19,7 +23,12 @@ func TestAgentGenerateUrl(t *testing.T) {
 	}{
 		{
 			metric:      model.Metrics{ID: "test_metric", MType: model.Gauge, Value: &commonValue},
-			expectedURL: "http://localhost:8080/update/gauge/test_metric/1.000000",
+			expectedURL: "http://localhost:8080/update/gauge/test_metric/1",
+			expectError: false,
+		},
+		{
+			metric:      model.Metrics{ID: "test_metric", MType: model.Gauge, Value: &smallValue},
+			expectedURL: "http://localhost:8080/update/gauge/test_metric/1.5e-09",
 			expectError: false,
 		},
 		{
@@ -63,3 +72,75 @@ func TestAgentSendMetrics(t *testing.T) {
 	err := agent.sendMetrics(metrics)
 	require.NoError(t, err, "Failed to send metrics")
 }
+
+func TestAgentSendMetricsHeaders(t *testing.T) {
+	var authorization, tenantName atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization.Store(r.Header.Get("Authorization"))
+		tenantName.Store(r.Header.Get("X-Tenant-ID"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	value := float64(1.0)
+	agent := NewAgent(http.DefaultClient, nil, server.URL+"/update/", 0, 0, WithToken("secret"), WithTenant("team-a"))
+	require.NoError(t, agent.sendMetrics([]model.Metrics{{ID: "test_metric", MType: model.Gauge, Value: &value}}))
+	require.Equal(t, "Bearer secret", authorization.Load())
+	require.Equal(t, "team-a", tenantName.Load())
+	require.Equal(t, 0, agent.Status().ConsecutiveFailures)
+}
+
+func TestAgentStatus(t *testing.T) {
+	var accept atomic.Bool
+	accept.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !accept.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	commonValue := float64(1.0)
+	metrics := []model.Metrics{{ID: "test_metric", MType: model.Gauge, Value: &commonValue}}
+	agent := NewAgent(http.DefaultClient, nil, server.URL+"/update/", 0, 0)
+	handler := agent.StatusHandler(2)
+
+	agent.status.collected(metrics)
+	require.Equal(t, 1, agent.Status().PendingMetrics)
+	require.NoError(t, agent.sendMetrics(metrics))
+
+	status := agent.Status()
+	require.Equal(t, 0, status.PendingMetrics)
+	require.Equal(t, 0, status.LastSendFailed)
+	require.Equal(t, 0, status.ConsecutiveFailures)
+	require.NotNil(t, status.LastSuccessfulSend)
+	require.Equal(t, metrics, status.LatestMetrics)
+
+	accept.Store(false)
+	for range 2 {
+		require.NoError(t, agent.sendMetrics(metrics))
+	}
+	require.Equal(t, 2, agent.Status().ConsecutiveFailures)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	require.Contains(t, rr.Body.String(), "500 Internal Server Error")
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/status", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var got Status
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	require.Equal(t, 2, got.ConsecutiveFailures)
+	require.Equal(t, 1, got.LastSendFailed)
+	require.Len(t, got.LatestMetrics, 1)
+
+	accept.Store(true)
+	require.NoError(t, agent.sendMetrics(metrics))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+}
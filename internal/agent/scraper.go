@@ -0,0 +1,264 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+)
+
+// InstanceLabel добавляется к метрикам цели, если цель не передала такую метку сама.
+const InstanceLabel = "instance"
+
+// Scraper опрашивает HTTP-цели, отдающие метрики в текстовом формате Prometheus.
+// Gauge и untyped сохраняются как gauge. Prometheus counter накопительный, поэтому
+// отправляется прирост с предыдущего опроса: при первом опросе ряда - 0, при уменьшении
+// значения (перезапуске цели) - текущее значение. Histogram и summary пропускаются.
+// Последние значения counter хранятся по целям: ряды, которых нет в успешном ответе
+// цели, забываются, а у недоступной цели сохраняются до следующего опроса.
+type Scraper struct {
+	client  *http.Client
+	targets []string
+	timeout time.Duration
+
+	mu       sync.Mutex
+	counters map[string]map[string]float64
+}
+
+func NewScraper(client *http.Client, targets []string, timeout time.Duration) *Scraper {
+	return &Scraper{
+		client:   client,
+		targets:  targets,
+		timeout:  timeout,
+		counters: make(map[string]map[string]float64),
+	}
+}
+
+// Collect опрашивает все цели. Недоступные цели пропускаются.
+func (s *Scraper) Collect() []model.Metrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metrics := []model.Metrics{}
+	for _, target := range s.targets {
+		fmt.Println("Scraping target: ", target)
+		samples, err := s.scrape(target)
+		if err != nil {
+			fmt.Println("Error scraping target: ", target, err)
+			continue
+		}
+		counters := make(map[string]float64)
+		for _, sample := range samples {
+			if _, ok := sample.Labels[InstanceLabel]; !ok {
+				sample.Labels[InstanceLabel] = targetInstance(target)
+			}
+			metric := model.Metrics{ID: sample.Name, Labels: sample.Labels}
+			if sample.Type != model.Counter {
+				value := sample.Value
+				metric.MType = model.Gauge
+				metric.Value = &value
+				metrics = append(metrics, metric)
+				continue
+			}
+
+			key := metric.FullID()
+			previous, seen := s.counters[target][key]
+			counters[key] = sample.Value
+			delta := int64(0)
+			switch {
+			case !seen:
+			case sample.Value < previous:
+				delta = int64(math.Floor(sample.Value))
+			default:
+				delta = int64(math.Floor(sample.Value) - math.Floor(previous))
+			}
+			metric.MType = model.Counter
+			metric.Delta = &delta
+			metrics = append(metrics, metric)
+		}
+		s.counters[target] = counters
+	}
+	return metrics
+}
+
+func (s *Scraper) scrape(target string) ([]promSample, error) {
+	ctx := context.Background()
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "text/plain;version=0.0.4")
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", response.Status)
+	}
+	return parsePrometheusText(response.Body)
+}
+
+func targetInstance(target string) string {
+	parsed, err := url.Parse(target)
+	if err != nil || parsed.Host == "" {
+		return target
+	}
+	return parsed.Host
+}
+
+type promSample struct {
+	Name   string
+	Type   string
+	Labels map[string]string
+	Value  float64
+}
+
+// parsePrometheusText разбирает текстовый формат Prometheus 0.0.4. Возвращаются только
+// counter, gauge и untyped (как gauge) ряды с конечными значениями; строки, которые не
+// удалось разобрать, пропускаются.
+func parsePrometheusText(r io.Reader) ([]promSample, error) {
+	types := make(map[string]string)
+	samples := []promSample{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 && fields[1] == "TYPE" {
+				types[fields[2]] = fields[3]
+			}
+			continue
+		}
+
+		sample, err := parseSampleLine(line)
+		if err != nil {
+			fmt.Println("Skipping invalid sample line: ", line, err)
+			continue
+		}
+		switch familyType(types, sample.Name) {
+		case "counter":
+			sample.Type = model.Counter
+		case "gauge", "untyped", "":
+			sample.Type = model.Gauge
+		default:
+			continue
+		}
+		if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	return samples, scanner.Err()
+}
+
+// familyType ищет тип семейства, к которому относится ряд: x_bucket, x_sum и x_count
+// относятся к histogram или summary x, x_total - к counter x (OpenMetrics).
+func familyType(types map[string]string, name string) string {
+	if t, ok := types[name]; ok {
+		return t
+	}
+	for _, suffix := range []string{"_bucket", "_sum", "_count", "_total"} {
+		if family, ok := strings.CutSuffix(name, suffix); ok {
+			if t, ok := types[family]; ok {
+				return t
+			}
+		}
+	}
+	return ""
+}
+
+func parseSampleLine(line string) (promSample, error) {
+	sample := promSample{Labels: make(map[string]string)}
+
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return sample, fmt.Errorf("missing value")
+	}
+	sample.Name = line[:end]
+	rest := line[end:]
+
+	if strings.HasPrefix(rest, "{") {
+		var err error
+		rest, err = parseLabels(rest[1:], sample.Labels)
+		if err != nil {
+			return sample, err
+		}
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return sample, fmt.Errorf("expected value and optional timestamp")
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return sample, fmt.Errorf("invalid value %q", fields[0])
+	}
+	sample.Value = value
+	return sample, nil
+}
+
+// parseLabels разбирает метки после "{" и возвращает остаток строки после "}".
+func parseLabels(s string, labels map[string]string) (string, error) {
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if strings.HasPrefix(s, "}") {
+			return s[1:], nil
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return "", fmt.Errorf("invalid label")
+		}
+		name := strings.TrimSpace(s[:eq])
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		if !strings.HasPrefix(s, `"`) {
+			return "", fmt.Errorf("label %s: value must be quoted", name)
+		}
+
+		var value strings.Builder
+		i := 1
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				continue
+			}
+			value.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return "", fmt.Errorf("label %s: unterminated value", name)
+		}
+		if value.Len() > 0 {
+			labels[name] = value.String()
+		}
+
+		s = strings.TrimLeft(s[i+1:], " \t")
+		s = strings.TrimPrefix(s, ",")
+	}
+}
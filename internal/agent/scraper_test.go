@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+const exposition = `# HELP http_requests_total Total requests.
+# TYPE http_requests_total counter
+http_requests_total{code="200",path="/a\"b"} %d
+http_requests_total{code="500"} 1 1700000000000
+# TYPE temperature gauge
+temperature 21.5
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 3
+latency_seconds_bucket{le="+Inf"} 4
+latency_seconds_sum 0.3
+latency_seconds_count 4
+# TYPE queue_size untyped
+queue_size NaN
+legacy_metric 7
+broken_line{code="200" 1
+`
+
+func findMetric(metrics []model.Metrics, id string, labels map[string]string) *model.Metrics {
+	for i := range metrics {
+		if metrics[i].ID == id && model.FormatLabels(metrics[i].Labels) == model.FormatLabels(labels) {
+			return &metrics[i]
+		}
+	}
+	return nil
+}
+
+func TestScraperCollect(t *testing.T) {
+	var requests atomic.Int64
+	requests.Store(10)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, exposition, requests.Load())
+	}))
+	defer target.Close()
+	instance := strings.TrimPrefix(target.URL, "http://")
+
+	scraper := NewScraper(http.DefaultClient, []string{target.URL, "http://127.0.0.1:1/metrics"}, time.Second)
+	okLabels := map[string]string{"code": "200", "path": `/a"b`, InstanceLabel: instance}
+
+	metrics := scraper.Collect()
+	require.Len(t, metrics, 4)
+
+	counter := findMetric(metrics, "http_requests_total", okLabels)
+	require.NotNil(t, counter)
+	require.Equal(t, model.Counter, counter.MType)
+	require.Equal(t, int64(0), *counter.Delta, "First scrape only sets the baseline")
+
+	gauge := findMetric(metrics, "temperature", map[string]string{InstanceLabel: instance})
+	require.NotNil(t, gauge)
+	require.Equal(t, model.Gauge, gauge.MType)
+	require.Equal(t, 21.5, *gauge.Value)
+
+	require.NotNil(t, findMetric(metrics, "legacy_metric", map[string]string{InstanceLabel: instance}))
+	require.Nil(t, findMetric(metrics, "latency_seconds_count", map[string]string{InstanceLabel: instance}))
+
+	requests.Store(15)
+	metrics = scraper.Collect()
+	require.Equal(t, int64(5), *findMetric(metrics, "http_requests_total", okLabels).Delta)
+
+	requests.Store(3)
+	metrics = scraper.Collect()
+	require.Equal(t, int64(3), *findMetric(metrics, "http_requests_total", okLabels).Delta, "Counter reset sends the current value")
+}
+
+func TestScraperForgetsVanishedCounters(t *testing.T) {
+	var body atomic.Value
+	body.Store("# TYPE a_total counter\na_total 10\n# TYPE b_total counter\nb_total 10\n")
+	var available atomic.Bool
+	available.Store(true)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, body.Load())
+	}))
+	defer target.Close()
+
+	scraper := NewScraper(http.DefaultClient, []string{target.URL}, time.Second)
+	scraper.Collect()
+	require.Len(t, scraper.counters[target.URL], 2)
+
+	available.Store(false)
+	require.Empty(t, scraper.Collect())
+	require.Len(t, scraper.counters[target.URL], 2, "Unavailable target keeps its counters")
+
+	available.Store(true)
+	body.Store("# TYPE a_total counter\na_total 12\n")
+	metrics := scraper.Collect()
+	labels := map[string]string{InstanceLabel: strings.TrimPrefix(target.URL, "http://")}
+	require.Equal(t, int64(2), *findMetric(metrics, "a_total", labels).Delta)
+	require.Len(t, scraper.counters[target.URL], 1, "Vanished series must be forgotten")
+}
+
+func TestAgentSendsScrapedMetrics(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "# TYPE up gauge\nup{job=\"node\"} 1\n")
+	}))
+	defer target.Close()
+
+	var received atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/gauge/up/") {
+			received.Store(r.URL.RequestURI())
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	scraper := NewScraper(http.DefaultClient, []string{target.URL}, time.Second)
+	agent := NewAgent(http.DefaultClient, nil, server.URL+"/update/", 0, 0, WithScraper(scraper))
+	require.NoError(t, agent.sendMetrics(scraper.Collect()))
+
+	instance := strings.TrimPrefix(target.URL, "http://")
+	require.Equal(t, "/update/gauge/up/1?instance="+strings.ReplaceAll(instance, ":", "%3A")+"&job=node", received.Load())
+}
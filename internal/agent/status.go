@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+)
+
+// Status - состояние агента. Агент не хранит очередь: метрики, которые не удалось
+// отправить, отбрасываются, поэтому PendingMetrics - это собранные и еще не
+// отправленные метрики текущего отчета, а LastSendFailed - сколько метрик потеряно
+// при последней отправке.
+type Status struct {
+	StartedAt           time.Time       `json:"started_at"`
+	LastCollect         *time.Time      `json:"last_collect,omitempty"`
+	LastSuccessfulSend  *time.Time      `json:"last_successful_send,omitempty"`
+	LastError           string          `json:"last_error,omitempty"`
+	ConsecutiveFailures int             `json:"consecutive_failures"`
+	PendingMetrics      int             `json:"pending_metrics"`
+	LastSendFailed      int             `json:"last_send_failed"`
+	LatestMetrics       []model.Metrics `json:"latest_metrics"`
+}
+
+type statusTracker struct {
+	mu     sync.RWMutex
+	status Status
+	now    func() time.Time
+}
+
+func newStatusTracker() *statusTracker {
+	return &statusTracker{
+		status: Status{StartedAt: time.Now(), LatestMetrics: []model.Metrics{}},
+		now:    time.Now,
+	}
+}
+
+func (t *statusTracker) collected(metrics []model.Metrics) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	t.status.LastCollect = &now
+	t.status.LatestMetrics = metrics
+	t.status.PendingMetrics = len(metrics)
+}
+
+// sent учитывает результат отправки: отчет считается успешным, только если сервер
+// принял все метрики.
+func (t *statusTracker) sent(failed int, lastErr error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status.PendingMetrics = 0
+	t.status.LastSendFailed = failed
+	if failed == 0 {
+		now := t.now()
+		t.status.LastSuccessfulSend = &now
+		t.status.ConsecutiveFailures = 0
+		t.status.LastError = ""
+		return
+	}
+	t.status.ConsecutiveFailures++
+	if lastErr != nil {
+		t.status.LastError = fmt.Sprintf("%d metrics failed, last error: %v", failed, lastErr)
+	}
+}
+
+func (t *statusTracker) snapshot() Status {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	status := t.status
+	status.LatestMetrics = append([]model.Metrics(nil), t.status.LatestMetrics...)
+	return status
+}
+
+func (a *Agent) Status() Status {
+	return a.status.snapshot()
+}
+
+// StatusHandler отдает состояние агента для отладки (/status) и проверок контейнера (/healthz).
+// /healthz отвечает 503, если maxFailures отправок подряд завершились ошибкой.
+func (a *Agent) StatusHandler(maxFailures int) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeStatusJSON(w, http.StatusOK, a.Status())
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		status := a.Status()
+		response := struct {
+			Status              string     `json:"status"`
+			ConsecutiveFailures int        `json:"consecutive_failures"`
+			LastSuccessfulSend  *time.Time `json:"last_successful_send,omitempty"`
+			Reason              string     `json:"reason,omitempty"`
+		}{Status: "ok", ConsecutiveFailures: status.ConsecutiveFailures, LastSuccessfulSend: status.LastSuccessfulSend}
+
+		code := http.StatusOK
+		if maxFailures > 0 && status.ConsecutiveFailures >= maxFailures {
+			code = http.StatusServiceUnavailable
+			response.Status = "unavailable"
+			response.Reason = status.LastError
+		}
+		writeStatusJSON(w, code, response)
+	})
+	return mux
+}
+
+func writeStatusJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Println("Error encoding status: ", err)
+	}
+}
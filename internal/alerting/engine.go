@@ -0,0 +1,258 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/service"
+)
+
+const (
+	StatePending  = "pending"
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+// Alert - состояние правила для одного ряда.
+type Alert struct {
+	Rule      string            `json:"rule"`
+	Metric    string            `json:"metric"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	State     string            `json:"state"`
+	Condition string            `json:"condition"`
+	Value     float64           `json:"value"`
+	ActiveAt  time.Time         `json:"active_at"`
+	FiredAt   *time.Time        `json:"fired_at,omitempty"`
+}
+
+// MaxUndelivered - сколько неотправленных уведомлений движок хранит для повторной
+// отправки. При переполнении отбрасываются самые старые.
+const MaxUndelivered = 1000
+
+type sample struct {
+	at    time.Time
+	value float64
+}
+
+// Engine вычисляет правила и хранит состояние алертов между вычислениями.
+type Engine struct {
+	service  service.Service
+	rules    []*Rule
+	notifier Notifier
+	now      func() time.Time
+
+	mu          sync.Mutex
+	alerts      map[string]*Alert
+	samples     map[string][]sample
+	undelivered []Notification
+}
+
+// NewEngine создает движок. Правила должны быть проверены через Validate.
+func NewEngine(service service.Service, rules []*Rule, notifier Notifier) *Engine {
+	return &Engine{
+		service:  service,
+		rules:    rules,
+		notifier: notifier,
+		now:      time.Now,
+		alerts:   make(map[string]*Alert),
+		samples:  make(map[string][]sample),
+	}
+}
+
+// Run вычисляет правила каждые interval, пока не будет отменен ctx.
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.Evaluate(ctx)
+		}
+	}
+}
+
+// Evaluate один раз вычисляет все правила и отправляет уведомления о переходах
+// в firing и resolved. Переход в pending и обратно уведомлений не создает.
+// Уведомления, которые не удалось отправить, повторяются при следующем вычислении
+// перед новыми.
+func (e *Engine) Evaluate(ctx context.Context) {
+	now := e.now()
+
+	e.mu.Lock()
+	notifications := e.undelivered
+	e.undelivered = nil
+	for _, rule := range e.rules {
+		metrics, err := e.service.GetAllMetrics(rule.matchers...)
+		if err != nil {
+			fmt.Printf("Error evaluating rule %s: %v\n", rule.Name, err)
+			continue
+		}
+
+		seen := make(map[string]bool)
+		for _, metric := range metrics {
+			if metric.ID != rule.Metric || metric.MType != rule.Type {
+				continue
+			}
+			key := rule.Name + "/" + metric.FullID()
+			seen[key] = true
+
+			value, ok := e.value(rule, key, metric, now)
+			active := false
+			if ok {
+				active, _ = compare(rule.Op, value, rule.Threshold)
+			}
+			if notification := e.transition(rule, key, metric, value, active, now); notification != nil {
+				notifications = append(notifications, *notification)
+			}
+		}
+
+		// Ряды, которые пропали из хранилища, считаются вернувшимися в норму.
+		for key, alert := range e.alerts {
+			if alert.Rule == rule.Name && !seen[key] {
+				if notification := e.resolve(key, alert, now); notification != nil {
+					notifications = append(notifications, *notification)
+				}
+			}
+		}
+		for key := range e.samples {
+			if strings.HasPrefix(key, rule.Name+"/") && !seen[key] {
+				delete(e.samples, key)
+			}
+		}
+	}
+	e.mu.Unlock()
+
+	undelivered := e.send(ctx, notifications)
+	if len(undelivered) == 0 {
+		return
+	}
+	e.mu.Lock()
+	e.undelivered = append(undelivered, e.undelivered...)
+	if dropped := len(e.undelivered) - MaxUndelivered; dropped > 0 {
+		fmt.Printf("Dropping %d undelivered alert notifications\n", dropped)
+		e.undelivered = e.undelivered[dropped:]
+	}
+	e.mu.Unlock()
+}
+
+// send отправляет уведомления по порядку и возвращает неотправленные. После ошибки
+// следующие уведомления того же алерта тоже откладываются, чтобы resolved не пришел
+// раньше firing.
+func (e *Engine) send(ctx context.Context, notifications []Notification) []Notification {
+	var undelivered []Notification
+	blocked := make(map[string]bool)
+	for _, notification := range notifications {
+		key := notification.Rule + "/" + notification.Metric + model.FormatLabels(notification.Labels)
+		if blocked[key] {
+			undelivered = append(undelivered, notification)
+			continue
+		}
+		if err := e.notifier.Notify(ctx, notification); err != nil {
+			fmt.Printf("Error sending alert notification for rule %s, will retry: %v\n", notification.Rule, err)
+			blocked[key] = true
+			undelivered = append(undelivered, notification)
+		}
+	}
+	return undelivered
+}
+
+// value возвращает значение ряда для правила. Для rate-правил второе значение равно
+// false, пока в окне нет хотя бы двух точек.
+func (e *Engine) value(rule *Rule, key string, metric *model.Metrics, now time.Time) (float64, bool) {
+	var current float64
+	switch {
+	case metric.Value != nil:
+		current = *metric.Value
+	case metric.Delta != nil:
+		current = float64(*metric.Delta)
+	default:
+		return 0, false
+	}
+	if rule.Kind != KindRate {
+		return current, true
+	}
+
+	window := time.Duration(rule.Window)
+	samples := append(e.samples[key], sample{at: now, value: current})
+	first := 0
+	for first < len(samples)-1 && now.Sub(samples[first+1].at) >= window {
+		first++
+	}
+	samples = samples[first:]
+	e.samples[key] = samples
+
+	oldest := samples[0]
+	elapsed := now.Sub(oldest.at).Seconds()
+	if elapsed <= 0 {
+		return 0, false
+	}
+	return (current - oldest.value) / elapsed, true
+}
+
+func (e *Engine) transition(rule *Rule, key string, metric *model.Metrics, value float64, active bool, now time.Time) *Notification {
+	alert, exists := e.alerts[key]
+	if !active {
+		if exists {
+			return e.resolve(key, alert, now)
+		}
+		return nil
+	}
+
+	if !exists {
+		alert = &Alert{
+			Rule:      rule.Name,
+			Metric:    metric.ID,
+			Labels:    metric.Labels,
+			State:     StatePending,
+			Condition: rule.Condition(),
+			ActiveAt:  now,
+		}
+		e.alerts[key] = alert
+	}
+	alert.Value = value
+
+	if alert.State == StatePending && now.Sub(alert.ActiveAt) >= time.Duration(rule.For) {
+		alert.State = StateFiring
+		firedAt := now
+		alert.FiredAt = &firedAt
+		notification := newNotification(alert, now)
+		return &notification
+	}
+	return nil
+}
+
+// resolve удаляет алерт. Уведомление создается, только если алерт успел сработать.
+func (e *Engine) resolve(key string, alert *Alert, now time.Time) *Notification {
+	delete(e.alerts, key)
+	if alert.State != StateFiring {
+		return nil
+	}
+	alert.State = StateResolved
+	notification := newNotification(alert, now)
+	return &notification
+}
+
+// Alerts возвращает текущие pending и firing алерты, отсортированные по правилу и ряду.
+func (e *Engine) Alerts() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	keys := make([]string, 0, len(e.alerts))
+	for key := range e.alerts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	alerts := make([]Alert, 0, len(keys))
+	for _, key := range keys {
+		alerts = append(alerts, *e.alerts[key])
+	}
+	return alerts
+}
@@ -0,0 +1,221 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/repository"
+	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+type webhookReceiver struct {
+	mu            sync.Mutex
+	notifications []Notification
+	unavailable   bool
+}
+
+func (r *webhookReceiver) setUnavailable(unavailable bool) {
+	r.mu.Lock()
+	r.unavailable = unavailable
+	r.mu.Unlock()
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	unavailable := r.unavailable
+	r.mu.Unlock()
+	if unavailable {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var notification Notification
+	if err := json.NewDecoder(req.Body).Decode(&notification); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.mu.Lock()
+	r.notifications = append(r.notifications, notification)
+	r.mu.Unlock()
+}
+
+func (r *webhookReceiver) take() []Notification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	notifications := r.notifications
+	r.notifications = nil
+	return notifications
+}
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func setupEngine(t *testing.T, rules ...*Rule) (*Engine, service.Service, *webhookReceiver, *testClock) {
+	t.Helper()
+	for _, rule := range rules {
+		require.NoError(t, rule.Validate())
+	}
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+
+	metricsService := service.NewMetricsService(repository.NewMemStorage())
+	engine := NewEngine(metricsService, rules, NewWebhookNotifier(http.DefaultClient, []string{server.URL}))
+	clock := &testClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	engine.now = func() time.Time { return clock.now }
+	return engine, metricsService, receiver, clock
+}
+
+func TestThresholdRuleLifecycle(t *testing.T) {
+	rule := &Rule{Name: "HighHeap", Metric: "HeapAlloc", Op: ">", Threshold: 100, For: Duration(2 * time.Minute)}
+	engine, metricsService, receiver, clock := setupEngine(t, rule)
+	ctx := context.Background()
+
+	require.NoError(t, metricsService.UpdateMetric(model.Gauge, "HeapAlloc", "50", nil))
+	engine.Evaluate(ctx)
+	require.Empty(t, engine.Alerts())
+
+	require.NoError(t, metricsService.UpdateMetric(model.Gauge, "HeapAlloc", "150", nil))
+	clock.advance(time.Minute)
+	engine.Evaluate(ctx)
+	alerts := engine.Alerts()
+	require.Len(t, alerts, 1)
+	require.Equal(t, StatePending, alerts[0].State)
+	require.Empty(t, receiver.take(), "Pending alerts must not notify")
+
+	clock.advance(2 * time.Minute)
+	engine.Evaluate(ctx)
+	require.Equal(t, StateFiring, engine.Alerts()[0].State)
+	notifications := receiver.take()
+	require.Len(t, notifications, 1)
+	require.Equal(t, StateFiring, notifications[0].Status)
+	require.Equal(t, "HeapAlloc > 100", notifications[0].Condition)
+	require.Equal(t, 150.0, notifications[0].Value)
+
+	clock.advance(time.Minute)
+	engine.Evaluate(ctx)
+	require.Empty(t, receiver.take(), "Firing alert must notify only once")
+
+	require.NoError(t, metricsService.UpdateMetric(model.Gauge, "HeapAlloc", "10", nil))
+	clock.advance(time.Minute)
+	engine.Evaluate(ctx)
+	require.Empty(t, engine.Alerts())
+	notifications = receiver.take()
+	require.Len(t, notifications, 1)
+	require.Equal(t, StateResolved, notifications[0].Status)
+	require.NotNil(t, notifications[0].EndsAt)
+}
+
+func TestPendingAlertResetsWithoutNotification(t *testing.T) {
+	rule := &Rule{Name: "HighHeap", Metric: "HeapAlloc", Op: ">", Threshold: 100, For: Duration(2 * time.Minute)}
+	engine, metricsService, receiver, clock := setupEngine(t, rule)
+	ctx := context.Background()
+
+	require.NoError(t, metricsService.UpdateMetric(model.Gauge, "HeapAlloc", "150", nil))
+	engine.Evaluate(ctx)
+	require.NoError(t, metricsService.UpdateMetric(model.Gauge, "HeapAlloc", "50", nil))
+	clock.advance(3 * time.Minute)
+	engine.Evaluate(ctx)
+
+	require.Empty(t, engine.Alerts())
+	require.Empty(t, receiver.take())
+}
+
+func TestUndeliveredNotificationsAreRetried(t *testing.T) {
+	rule := &Rule{Name: "HighHeap", Metric: "HeapAlloc", Op: ">", Threshold: 100}
+	engine, metricsService, receiver, clock := setupEngine(t, rule)
+	ctx := context.Background()
+
+	receiver.setUnavailable(true)
+	require.NoError(t, metricsService.UpdateMetric(model.Gauge, "HeapAlloc", "150", nil))
+	engine.Evaluate(ctx)
+	require.Equal(t, StateFiring, engine.Alerts()[0].State)
+	require.Empty(t, receiver.take())
+
+	require.NoError(t, metricsService.UpdateMetric(model.Gauge, "HeapAlloc", "10", nil))
+	clock.advance(time.Minute)
+	engine.Evaluate(ctx)
+	require.Empty(t, engine.Alerts())
+	require.Empty(t, receiver.take())
+
+	receiver.setUnavailable(false)
+	clock.advance(time.Minute)
+	engine.Evaluate(ctx)
+	notifications := receiver.take()
+	require.Len(t, notifications, 2)
+	require.Equal(t, StateFiring, notifications[0].Status)
+	require.Equal(t, StateResolved, notifications[1].Status)
+
+	engine.Evaluate(ctx)
+	require.Empty(t, receiver.take(), "Delivered notifications must not be resent")
+}
+
+func TestRateRulePerSeries(t *testing.T) {
+	rule := &Rule{Name: "ErrorBurst", Metric: "errors", Type: model.Counter, Match: []string{"env=prod"}, Kind: KindRate, Op: ">", Threshold: 1, Window: Duration(time.Minute)}
+	engine, metricsService, receiver, clock := setupEngine(t, rule)
+	ctx := context.Background()
+
+	for _, host := range []string{"a", "b"} {
+		require.NoError(t, metricsService.UpdateMetric(model.Counter, "errors", "10", map[string]string{"env": "prod", "host": host}))
+	}
+	require.NoError(t, metricsService.UpdateMetric(model.Counter, "errors", "1000", map[string]string{"env": "dev"}))
+	engine.Evaluate(ctx)
+	require.Empty(t, engine.Alerts(), "Rate needs two samples")
+
+	clock.advance(30 * time.Second)
+	require.NoError(t, metricsService.UpdateMetric(model.Counter, "errors", "60", map[string]string{"env": "prod", "host": "a"}))
+	require.NoError(t, metricsService.UpdateMetric(model.Counter, "errors", "5", map[string]string{"env": "prod", "host": "b"}))
+	require.NoError(t, metricsService.UpdateMetric(model.Counter, "errors", "1000", map[string]string{"env": "dev"}))
+	engine.Evaluate(ctx)
+
+	notifications := receiver.take()
+	require.Len(t, notifications, 1)
+	require.Equal(t, map[string]string{"env": "prod", "host": "a"}, notifications[0].Labels)
+	require.Equal(t, 2.0, notifications[0].Value)
+
+	clock.advance(2 * time.Minute)
+	engine.Evaluate(ctx)
+	notifications = receiver.take()
+	require.Len(t, notifications, 1)
+	require.Equal(t, StateResolved, notifications[0].Status)
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.json")
+	require.NoError(t, os.WriteFile(valid, []byte(`{"webhooks":["http://localhost/hook"],"rules":[{"name":"HighHeap","metric":"HeapAlloc","op":">","threshold":1e9,"for":"2m"}]}`), 0o600))
+	config, err := LoadConfig(valid)
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://localhost/hook"}, config.Webhooks)
+	require.Equal(t, Duration(2*time.Minute), config.Rules[0].For)
+	require.Equal(t, model.Gauge, config.Rules[0].Type)
+	require.Equal(t, KindThreshold, config.Rules[0].Kind)
+
+	invalid := []string{
+		`{"rules":[{"name":"x","metric":"m","op":"~"}]}`,
+		`{"rules":[{"name":"x","metric":"m","op":">","kind":"rate"}]}`,
+		`{"rules":[{"name":"x","metric":"m","op":">","for":"soon"}]}`,
+		`{"rules":[{"name":"x","metric":"m","op":">"},{"name":"x","metric":"m","op":"<"}]}`,
+		`{"rules":[{"name":"x","metric":"m","op":">","match":["host"]}]}`,
+	}
+	for i, content := range invalid {
+		path := filepath.Join(dir, "invalid.json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		_, err := LoadConfig(path)
+		require.Error(t, err, "case %d", i)
+	}
+}
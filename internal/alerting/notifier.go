@@ -0,0 +1,80 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Notification - тело запроса к webhook-у.
+type Notification struct {
+	Status    string            `json:"status"`
+	Rule      string            `json:"rule"`
+	Metric    string            `json:"metric"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Condition string            `json:"condition"`
+	Value     float64           `json:"value"`
+	StartsAt  time.Time         `json:"starts_at"`
+	EndsAt    *time.Time        `json:"ends_at,omitempty"`
+}
+
+func newNotification(alert *Alert, now time.Time) Notification {
+	notification := Notification{
+		Status:    alert.State,
+		Rule:      alert.Rule,
+		Metric:    alert.Metric,
+		Labels:    alert.Labels,
+		Condition: alert.Condition,
+		Value:     alert.Value,
+		StartsAt:  alert.ActiveAt,
+	}
+	if alert.State == StateResolved {
+		notification.EndsAt = &now
+	}
+	return notification
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// WebhookNotifier отправляет уведомление POST-запросом с JSON во все webhook-и.
+type WebhookNotifier struct {
+	client *http.Client
+	urls   []string
+}
+
+func NewWebhookNotifier(client *http.Client, urls []string) *WebhookNotifier {
+	return &WebhookNotifier{client: client, urls: urls}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, url := range n.urls {
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		request.Header.Set("Content-Type", "application/json")
+		response, err := n.client.Do(request)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		response.Body.Close()
+		if response.StatusCode < 200 || response.StatusCode >= 300 {
+			errs = append(errs, fmt.Errorf("webhook %s responded with %s", url, response.Status))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,161 @@
+// Package alerting периодически проверяет правила над сохраненными метриками и
+// отправляет уведомления о смене состояния алертов в webhook-и.
+package alerting
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+)
+
+const (
+	KindThreshold = "threshold"
+	KindRate      = "rate"
+)
+
+// Config - содержимое файла правил.
+type Config struct {
+	Webhooks []string `json:"webhooks"`
+	Rules    []*Rule  `json:"rules"`
+}
+
+// Rule описывает условие над каждым рядом метрики Metric типа Type, подходящим под Match.
+// Для KindThreshold с порогом сравнивается значение ряда, для KindRate - скорость его
+// изменения в секунду за окно Window. Алерт срабатывает, если условие выполняется
+// не меньше For.
+type Rule struct {
+	Name      string   `json:"name"`
+	Metric    string   `json:"metric"`
+	Type      string   `json:"type"`
+	Match     []string `json:"match"`
+	Kind      string   `json:"kind"`
+	Op        string   `json:"op"`
+	Threshold float64  `json:"threshold"`
+	For       Duration `json:"for"`
+	Window    Duration `json:"window"`
+
+	matchers []*model.LabelMatcher
+}
+
+// Duration принимает в JSON строки вида "2m" или "30s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"2m\"")
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// LoadConfig читает и проверяет файл правил.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %w", path, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+func (c *Config) Validate() error {
+	names := make(map[string]bool, len(c.Rules))
+	for i, rule := range c.Rules {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("rule #%d: %w", i+1, err)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("rule #%d: duplicate name %q", i+1, rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	return nil
+}
+
+// Validate проверяет правило и подготавливает его к вычислению.
+func (r *Rule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name cannot be empty")
+	}
+	if r.Metric == "" {
+		return fmt.Errorf("rule %s: metric cannot be empty", r.Name)
+	}
+	if r.Type == "" {
+		r.Type = model.Gauge
+	}
+	if r.Type != model.Gauge && r.Type != model.Counter {
+		return fmt.Errorf("rule %s: type must be gauge or counter", r.Name)
+	}
+	if r.Kind == "" {
+		r.Kind = KindThreshold
+	}
+	switch r.Kind {
+	case KindThreshold:
+	case KindRate:
+		if r.Window <= 0 {
+			return fmt.Errorf("rule %s: window must be positive for rate rules", r.Name)
+		}
+	default:
+		return fmt.Errorf("rule %s: unknown kind %q", r.Name, r.Kind)
+	}
+	if _, err := compare(r.Op, 0, 0); err != nil {
+		return fmt.Errorf("rule %s: %w", r.Name, err)
+	}
+	if r.For < 0 {
+		return fmt.Errorf("rule %s: for cannot be negative", r.Name)
+	}
+
+	r.matchers = r.matchers[:0]
+	for _, s := range r.Match {
+		matcher, err := model.ParseLabelMatcher(s)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+		r.matchers = append(r.matchers, matcher)
+	}
+	return nil
+}
+
+// Condition возвращает условие правила в читаемом виде: HeapAlloc > 1e+09.
+func (r *Rule) Condition() string {
+	if r.Kind == KindRate {
+		return fmt.Sprintf("rate(%s[%s]) %s %g", r.Metric, time.Duration(r.Window), r.Op, r.Threshold)
+	}
+	return fmt.Sprintf("%s %s %g", r.Metric, r.Op, r.Threshold)
+}
+
+func compare(op string, value, threshold float64) (bool, error) {
+	switch op {
+	case ">":
+		return value > threshold, nil
+	case ">=":
+		return value >= threshold, nil
+	case "<":
+		return value < threshold, nil
+	case "<=":
+		return value <= threshold, nil
+	case "==":
+		return value == threshold, nil
+	case "!=":
+		return value != threshold, nil
+	}
+	return false, fmt.Errorf("unknown operator %q", op)
+}
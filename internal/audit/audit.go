@@ -0,0 +1,180 @@
+// Package audit асинхронно записывает журнал изменений метрик: кто, когда и какие
+// значения записал или удалил.
+package audit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prbllm/go-metrics/internal/auth"
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/tenant"
+)
+
+const (
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	ActionImport = "import"
+
+	// DropBufferFull - событие отброшено, потому что очередь записи заполнена.
+	DropBufferFull = "buffer_full"
+	// DropSinkError - событие потеряно из-за ошибки записи в приемник.
+	DropSinkError = "sink_error"
+	// DropClosed - событие пришло после закрытия журнала при остановке сервера.
+	DropClosed = "closed"
+)
+
+// maxBatchSize ограничивает количество событий в одной записи в приемник.
+const maxBatchSize = 100
+
+// Source описывает, откуда пришло изменение.
+type Source struct {
+	ClientIP string `json:"client_ip"`
+	Token    string `json:"token,omitempty"`
+	Tenant   string `json:"tenant,omitempty"`
+	Handler  string `json:"handler,omitempty"`
+}
+
+// NewSource определяет источник по запросу: IP-адрес соединения, имя API-токена,
+// арендатора и шаблон маршрута.
+func NewSource(r *http.Request) Source {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	source := Source{ClientIP: host, Tenant: tenant.FromContext(r.Context())}
+	if token := auth.FromContext(r.Context()); token != nil {
+		source.Token = token.Name
+	}
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		source.Handler = rctx.RoutePattern()
+	}
+	return source
+}
+
+// Change - одно изменение ряда в том виде, в котором его прислал клиент.
+type Change struct {
+	ID     string            `json:"id"`
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  string            `json:"value,omitempty"`
+}
+
+// NewChange описывает запись метрики: для counter - приращение, для gauge - значение,
+// для гистограмм и сводок - количество и сумму наблюдений.
+func NewChange(metric *model.Metrics) Change {
+	change := Change{ID: metric.ID, Type: metric.MType, Labels: metric.Labels}
+	switch {
+	case metric.MType == model.Counter && metric.Delta != nil:
+		change.Value = strconv.FormatInt(*metric.Delta, 10)
+	case metric.MType == model.Gauge && metric.Value != nil:
+		change.Value = strconv.FormatFloat(*metric.Value, 'g', -1, 64)
+	case metric.MType == model.Histogram || metric.MType == model.Summary:
+		change.Value = metric.DistributionString()
+	}
+	return change
+}
+
+// Event - запись журнала об одном запросе.
+type Event struct {
+	Time time.Time `json:"time"`
+	Source
+	Action  string   `json:"action"`
+	Changes []Change `json:"changes"`
+}
+
+// Sink сохраняет пачку событий.
+type Sink interface {
+	Write(events []Event) error
+	Close() error
+}
+
+// Logger принимает события без блокировки обработчиков и пишет их в приемник из
+// отдельной горутины. Если приемник не успевает и очередь заполнена, новые события
+// отбрасываются, а onDrop получает причину и количество потерянных событий.
+type Logger struct {
+	sink   Sink
+	onDrop func(reason string, n int)
+	now    func() time.Time
+
+	mu     sync.RWMutex
+	closed bool
+	events chan Event
+	done   chan struct{}
+}
+
+func NewLogger(sink Sink, bufferSize int, onDrop func(reason string, n int)) *Logger {
+	if onDrop == nil {
+		onDrop = func(string, int) {}
+	}
+	l := &Logger{
+		sink:   sink,
+		onDrop: onDrop,
+		now:    time.Now,
+		events: make(chan Event, bufferSize),
+		done:   make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// Record ставит событие в очередь записи. Время события проставляется, если не задано.
+func (l *Logger) Record(event Event) {
+	if event.Time.IsZero() {
+		event.Time = l.now().UTC()
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		l.onDrop(DropClosed, 1)
+		return
+	}
+	select {
+	case l.events <- event:
+	default:
+		fmt.Printf("Audit queue is full, dropping %s event from %s\n", event.Action, event.ClientIP)
+		l.onDrop(DropBufferFull, 1)
+	}
+}
+
+// Close записывает события, оставшиеся в очереди, и закрывает приемник.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.events)
+	}
+	l.mu.Unlock()
+
+	<-l.done
+	return l.sink.Close()
+}
+
+func (l *Logger) run() {
+	defer close(l.done)
+	for event := range l.events {
+		batch := []Event{event}
+	collect:
+		for len(batch) < maxBatchSize {
+			select {
+			case next, ok := <-l.events:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, next)
+			default:
+				break collect
+			}
+		}
+		if err := l.sink.Write(batch); err != nil {
+			fmt.Printf("Error writing %d audit events: %v\n", len(batch), err)
+			l.onDrop(DropSinkError, len(batch))
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/auth"
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/tenant"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingSink принимает события только после закрытия release.
+type blockingSink struct {
+	release chan struct{}
+	mu      sync.Mutex
+	events  []Event
+}
+
+func (s *blockingSink) Write(events []Event) error {
+	<-s.release
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *blockingSink) Close() error {
+	return nil
+}
+
+func TestLoggerDropsWhenQueueIsFull(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	dropped := map[string]int{}
+	var mu sync.Mutex
+	logger := NewLogger(sink, 2, func(reason string, n int) {
+		mu.Lock()
+		dropped[reason] += n
+		mu.Unlock()
+	})
+
+	// Первое событие забирает горутина записи и блокируется в приемнике.
+	logger.Record(Event{Action: ActionUpdate, Source: Source{ClientIP: "first"}})
+	require.Eventually(t, func() bool { return len(logger.events) == 0 }, time.Second, time.Millisecond)
+	for range 4 {
+		logger.Record(Event{Action: ActionUpdate})
+	}
+	mu.Lock()
+	require.Equal(t, 2, dropped[DropBufferFull])
+	mu.Unlock()
+
+	close(sink.release)
+	require.NoError(t, logger.Close())
+	require.Len(t, sink.events, 3)
+	require.Equal(t, "first", sink.events[0].ClientIP)
+	require.False(t, sink.events[0].Time.IsZero())
+
+	logger.Record(Event{Action: ActionUpdate})
+	require.Equal(t, 1, dropped[DropClosed], "events after Close are dropped")
+	require.Equal(t, 2, dropped[DropBufferFull])
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	delta := int64(5)
+	event := Event{
+		Time:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Source:  Source{ClientIP: "10.0.0.1", Token: "agent", Handler: "/update/"},
+		Action:  ActionUpdate,
+		Changes: []Change{NewChange(&model.Metrics{ID: "requests", MType: model.Counter, Delta: &delta})},
+	}
+
+	for range 2 {
+		sink, err := NewFileSink(path)
+		require.NoError(t, err)
+		require.NoError(t, sink.Write([]Event{event}))
+		require.NoError(t, sink.Close())
+	}
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	lines := 0
+	for scanner.Scan() {
+		lines++
+		require.JSONEq(t, `{"time":"2025-01-01T00:00:00Z","client_ip":"10.0.0.1","token":"agent","handler":"/update/","action":"update","changes":[{"id":"requests","type":"counter","value":"5"}]}`, scanner.Text())
+	}
+	require.Equal(t, 2, lines, "file is appended, not truncated")
+}
+
+func TestWebhookSink(t *testing.T) {
+	var received []Event
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(server.Client(), server.URL)
+	require.NoError(t, sink.Write([]Event{{Action: ActionDelete}, {Action: ActionUpdate}}))
+	require.Len(t, received, 2)
+
+	status = http.StatusBadGateway
+	require.Error(t, sink.Write([]Event{{Action: ActionDelete}}))
+}
+
+func TestNewSource(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/update/", nil)
+	r.RemoteAddr = "192.168.1.5:4242"
+	ctx := tenant.NewContext(r.Context(), "team-a")
+	ctx = auth.NewContext(ctx, &auth.Token{Name: "agent"})
+
+	source := NewSource(r.WithContext(ctx))
+	require.Equal(t, Source{ClientIP: "192.168.1.5", Token: "agent", Tenant: "team-a"}, source)
+}
@@ -0,0 +1,175 @@
+// Package auth проверяет bearer-токены запросов и права, выданные токенам.
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/prbllm/go-metrics/internal/tenant"
+)
+
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+type contextKey struct{}
+
+// Config - содержимое файла токенов.
+type Config struct {
+	Tokens []*Token `json:"tokens"`
+}
+
+// Token выдает владельцу права Scopes. Право admin включает read и write. Если задан
+// Tenant, запросы с токеном работают только с пространством этого арендатора,
+// иначе арендатор берется из заголовка X-Tenant-ID.
+type Token struct {
+	Name   string   `json:"name"`
+	Token  string   `json:"token"`
+	Scopes []string `json:"scopes"`
+	Tenant string   `json:"tenant"`
+}
+
+// LoadConfig читает и проверяет файл токенов.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid tokens file %s: %w", path, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid tokens file %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+func (c *Config) Validate() error {
+	if len(c.Tokens) == 0 {
+		return fmt.Errorf("no tokens configured")
+	}
+	seen := make(map[string]bool, len(c.Tokens))
+	for i, token := range c.Tokens {
+		if err := token.Validate(); err != nil {
+			return fmt.Errorf("token #%d: %w", i+1, err)
+		}
+		if seen[token.Token] {
+			return fmt.Errorf("token #%d (%s): duplicate token", i+1, token.Name)
+		}
+		seen[token.Token] = true
+	}
+	return nil
+}
+
+func (t *Token) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if t.Token == "" {
+		return fmt.Errorf("%s: token is required", t.Name)
+	}
+	if len(t.Scopes) == 0 {
+		return fmt.Errorf("%s: at least one scope is required", t.Name)
+	}
+	for _, scope := range t.Scopes {
+		if scope != ScopeRead && scope != ScopeWrite && scope != ScopeAdmin {
+			return fmt.Errorf("%s: unknown scope %q, expected read, write or admin", t.Name, scope)
+		}
+	}
+	if t.Tenant != "" {
+		if err := tenant.ValidateName(t.Tenant); err != nil {
+			return fmt.Errorf("%s: %w", t.Name, err)
+		}
+	}
+	return nil
+}
+
+// Allows сообщает, выдано ли токену право scope.
+func (t *Token) Allows(scope string) bool {
+	for _, granted := range t.Scopes {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator проверяет токены запросов. Без токенов проверка отключена и все
+// запросы пропускаются.
+type Authenticator struct {
+	tokens []*Token
+}
+
+func NewAuthenticator(tokens []*Token) *Authenticator {
+	return &Authenticator{tokens: tokens}
+}
+
+// Enabled сообщает, включена ли проверка токенов.
+func (a *Authenticator) Enabled() bool {
+	return len(a.tokens) > 0
+}
+
+// Authenticate возвращает токен из заголовка Authorization: Bearer <token> или nil,
+// если токен не передан или неизвестен.
+func (a *Authenticator) Authenticate(r *http.Request) *Token {
+	value, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil
+	}
+	value = strings.TrimSpace(value)
+	var found *Token
+	for _, token := range a.tokens {
+		// Сравниваются все токены, чтобы время ответа не зависело от совпадения.
+		if subtle.ConstantTimeCompare([]byte(value), []byte(token.Token)) == 1 {
+			found = token
+		}
+	}
+	return found
+}
+
+// Require пропускает только запросы с токеном, которому выдано право scope. Без токена
+// или с неизвестным токеном запрос отклоняется с кодом 401, без права - с кодом 403.
+func (a *Authenticator) Require(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !a.Enabled() {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := a.Authenticate(r)
+			if token == nil {
+				fmt.Printf("Unauthorized request: %s %s\n", r.Method, r.URL.Path)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="go-metrics"`)
+				http.Error(w, "missing or unknown token", http.StatusUnauthorized)
+				return
+			}
+			if !token.Allows(scope) {
+				fmt.Printf("Token %s has no %s scope: %s %s\n", token.Name, scope, r.Method, r.URL.Path)
+				http.Error(w, fmt.Sprintf("token has no %s scope", scope), http.StatusForbidden)
+				return
+			}
+			ctx := NewContext(r.Context(), token)
+			if token.Tenant != "" {
+				ctx = tenant.NewContext(ctx, token.Tenant)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func NewContext(ctx context.Context, token *Token) context.Context {
+	return context.WithValue(ctx, contextKey{}, token)
+}
+
+// FromContext возвращает токен, с которым прошел запрос, или nil, если проверка отключена.
+func FromContext(ctx context.Context) *Token {
+	token, _ := ctx.Value(contextKey{}).(*Token)
+	return token
+}
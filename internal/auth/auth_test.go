@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prbllm/go-metrics/internal/tenant"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequire(t *testing.T) {
+	authenticator := NewAuthenticator([]*Token{
+		{Name: "reader", Token: "r", Scopes: []string{ScopeRead}},
+		{Name: "writer", Token: "w", Scopes: []string{ScopeWrite}, Tenant: "team-a"},
+		{Name: "root", Token: "a", Scopes: []string{ScopeAdmin}},
+	})
+
+	tests := []struct {
+		name           string
+		scope          string
+		authorization  string
+		expectedStatus int
+		expectedTenant string
+	}{
+		{name: "missing token", scope: ScopeRead, expectedStatus: http.StatusUnauthorized},
+		{name: "unknown token", scope: ScopeRead, authorization: "Bearer x", expectedStatus: http.StatusUnauthorized},
+		{name: "not bearer", scope: ScopeRead, authorization: "Basic r", expectedStatus: http.StatusUnauthorized},
+		{name: "read allowed", scope: ScopeRead, authorization: "Bearer r", expectedStatus: http.StatusOK},
+		{name: "read cannot write", scope: ScopeWrite, authorization: "Bearer r", expectedStatus: http.StatusForbidden},
+		{name: "write pins tenant", scope: ScopeWrite, authorization: "Bearer w", expectedStatus: http.StatusOK, expectedTenant: "team-a"},
+		{name: "write cannot delete", scope: ScopeAdmin, authorization: "Bearer w", expectedStatus: http.StatusForbidden},
+		{name: "admin can read", scope: ScopeRead, authorization: "Bearer a", expectedStatus: http.StatusOK},
+		{name: "admin can delete", scope: ScopeAdmin, authorization: "Bearer a", expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got string
+			handler := authenticator.Require(tc.scope)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = tenant.FromContext(r.Context())
+			}))
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.authorization != "" {
+				request.Header.Set("Authorization", tc.authorization)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, request)
+
+			require.Equal(t, tc.expectedStatus, rr.Code)
+			require.Equal(t, tc.expectedTenant, got)
+			if tc.expectedStatus == http.StatusUnauthorized {
+				require.NotEmpty(t, rr.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestRequireDisabled(t *testing.T) {
+	called := false
+	handler := NewAuthenticator(nil).Require(ScopeAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/", nil))
+	require.True(t, called)
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "valid",
+			content: `{"tokens": [{"name": "agent", "token": "s1", "scopes": ["write"]}, {"name": "ops", "token": "s2", "scopes": ["read", "admin"], "tenant": "team-a"}]}`,
+		},
+		{name: "no tokens", content: `{"tokens": []}`, wantErr: "no tokens configured"},
+		{name: "empty token", content: `{"tokens": [{"name": "agent", "scopes": ["write"]}]}`, wantErr: "token is required"},
+		{name: "unknown scope", content: `{"tokens": [{"name": "agent", "token": "s", "scopes": ["delete"]}]}`, wantErr: "unknown scope"},
+		{name: "no scopes", content: `{"tokens": [{"name": "agent", "token": "s"}]}`, wantErr: "at least one scope"},
+		{name: "invalid tenant", content: `{"tokens": [{"name": "agent", "token": "s", "scopes": ["read"], "tenant": "a b"}]}`, wantErr: "invalid tenant"},
+		{name: "duplicate", content: `{"tokens": [{"name": "a", "token": "s", "scopes": ["read"]}, {"name": "b", "token": "s", "scopes": ["read"]}]}`, wantErr: "duplicate token"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tokens.json")
+			require.NoError(t, os.WriteFile(path, []byte(tc.content), 0o600))
+
+			config, err := LoadConfig(path)
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, config.Tokens, 2)
+		})
+	}
+}
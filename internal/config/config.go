@@ -4,23 +4,103 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"regexp"
 	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/prbllm/go-metrics/internal/tsdb"
 )
 
 type Config struct {
 	ServerHost string
 
+	HistogramBuckets []float64
+
+	NameAllowedChars string
+	NameMaxLength    int
+	ReservedPrefixes []string
+	SanitizeNames    bool
+
+	StorageDir       string
+	SnapshotInterval time.Duration
+	WALSync          bool
+
+	HistoryRetention       time.Duration
+	HistoryTiers           []tsdb.Tier
+	HistoryCompactInterval time.Duration
+
+	MaxSeries        int
+	MaxSeriesPerName int
+	MaxNewSeries     int
+	NewSeriesWindow  time.Duration
+
+	InfluxCounterPattern string
+	OTLPPrefixAttributes []string
+
+	GraphiteAddress        string
+	GraphiteMaxConnections int
+	GraphiteIdleTimeout    time.Duration
+	// GraphiteAllowUnauthenticated разрешает приемник Graphite, у которого нет
+	// аутентификации, вместе с -auth-tokens или -tenant-tokens.
+	GraphiteAllowUnauthenticated bool
+
+	AlertRulesFile string
+	AlertInterval  time.Duration
+
+	RecordingRulesFile string
+	RecordingInterval  time.Duration
+
+	TenantTokens   map[string]string
+	AuthTokensFile string
+
+	WriteRateLimit float64
+	WriteRateBurst int
+	ReadRateLimit  float64
+	ReadRateBurst  int
+
+	AuditFile       string
+	AuditWebhook    string
+	AuditBufferSize int
+
 	AgentPollInterval   time.Duration
 	AgentReportInterval time.Duration
+	AgentStatusAddress  string
+	AgentMaxFailures    int
+	AgentScrapeTargets  []string
+	AgentScrapeTimeout  time.Duration
+	AgentTenant         string
+	AgentToken          string
 }
 
 var globalConfig *Config
 
+// defaultHistoryTiers хранит минутные агрегаты месяц, а часовые - год.
+var defaultHistoryTiers = []tsdb.Tier{
+	{Resolution: time.Minute, Retention: 30 * 24 * time.Hour},
+	{Resolution: time.Hour, Retention: 365 * 24 * time.Hour},
+}
+
 func defaultConfig() *Config {
 	return &Config{
-		ServerHost:          "localhost:8080",
-		AgentPollInterval:   2 * time.Second,
-		AgentReportInterval: 10 * time.Second,
+		ServerHost:             "localhost:8080",
+		HistogramBuckets:       model.DefaultHistogramBuckets,
+		NewSeriesWindow:        time.Minute,
+		SnapshotInterval:       5 * time.Minute,
+		HistoryTiers:           defaultHistoryTiers,
+		HistoryCompactInterval: time.Minute,
+		NameAllowedChars:       service.DefaultAllowedNameChars,
+		NameMaxLength:          service.DefaultMaxNameLength,
+		ReservedPrefixes:       []string{model.SelfMetricPrefix},
+		GraphiteMaxConnections: 100,
+		GraphiteIdleTimeout:    time.Minute,
+		AlertInterval:          15 * time.Second,
+		RecordingInterval:      15 * time.Second,
+		AuditBufferSize:        10000,
+		AgentPollInterval:      2 * time.Second,
+		AgentReportInterval:    10 * time.Second,
+		AgentMaxFailures:       3,
+		AgentScrapeTimeout:     5 * time.Second,
 	}
 }
 
@@ -41,6 +121,74 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("server host cannot be empty")
 	}
 
+	if err := service.ValidateHistogramBuckets(c.HistogramBuckets); err != nil {
+		return err
+	}
+
+	if _, err := service.NewNamingPolicy(c.NameAllowedChars, c.NameMaxLength, c.ReservedPrefixes, c.SanitizeNames); err != nil {
+		return err
+	}
+
+	if c.StorageDir != "" && c.SnapshotInterval <= 0 {
+		return fmt.Errorf("snapshot interval must be positive")
+	}
+
+	if c.HistoryRetention < 0 {
+		return fmt.Errorf("history retention cannot be negative")
+	}
+
+	if c.HistoryRetention > 0 && c.HistoryCompactInterval <= 0 {
+		return fmt.Errorf("history compaction interval must be positive")
+	}
+
+	if c.MaxSeries < 0 || c.MaxSeriesPerName < 0 || c.MaxNewSeries < 0 {
+		return fmt.Errorf("series limits cannot be negative")
+	}
+
+	if c.MaxNewSeries > 0 && c.NewSeriesWindow <= 0 {
+		return fmt.Errorf("new series window must be positive")
+	}
+
+	if _, err := regexp.Compile(c.InfluxCounterPattern); err != nil {
+		return fmt.Errorf("invalid influx counter pattern: %w", err)
+	}
+
+	if c.GraphiteMaxConnections < 0 {
+		return fmt.Errorf("graphite max connections cannot be negative")
+	}
+
+	if c.GraphiteIdleTimeout < 0 {
+		return fmt.Errorf("graphite idle timeout cannot be negative")
+	}
+
+	if c.AlertRulesFile != "" && c.AlertInterval <= 0 {
+		return fmt.Errorf("alert evaluation interval must be positive")
+	}
+
+	if c.RecordingRulesFile != "" && c.RecordingInterval <= 0 {
+		return fmt.Errorf("recording rules evaluation interval must be positive")
+	}
+
+	if len(c.TenantTokens) > 0 && c.AuthTokensFile != "" {
+		return fmt.Errorf("tenant tokens cannot be combined with auth tokens, set tenant in the auth tokens file instead")
+	}
+
+	if c.GraphiteAddress != "" && (c.AuthTokensFile != "" || len(c.TenantTokens) > 0) && !c.GraphiteAllowUnauthenticated {
+		return fmt.Errorf("graphite listener does not support authentication, set -graphite-allow-unauthenticated to enable it together with auth or tenant tokens")
+	}
+
+	if c.WriteRateLimit < 0 || c.ReadRateLimit < 0 || c.WriteRateBurst < 0 || c.ReadRateBurst < 0 {
+		return fmt.Errorf("rate limits cannot be negative")
+	}
+
+	if c.AuditFile != "" && c.AuditWebhook != "" {
+		return fmt.Errorf("audit log can be written either to a file or to a webhook")
+	}
+
+	if (c.AuditFile != "" || c.AuditWebhook != "") && c.AuditBufferSize <= 0 {
+		return fmt.Errorf("audit buffer size must be positive")
+	}
+
 	if c.AgentPollInterval <= 0 {
 		return fmt.Errorf("agent poll interval must be positive")
 	}
@@ -49,10 +197,18 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("agent report interval must be positive")
 	}
 
+	if c.AgentMaxFailures < 0 {
+		return fmt.Errorf("agent max failures cannot be negative")
+	}
+
+	if len(c.AgentScrapeTargets) > 0 && c.AgentScrapeTimeout <= 0 {
+		return fmt.Errorf("agent scrape timeout must be positive")
+	}
+
 	return nil
 }
 
 func (c *Config) String() string {
-	return fmt.Sprintf("Config{ServerHost: %s, AgentPollInterval: %v, AgentReportInterval: %v}",
-		c.ServerHost, c.AgentPollInterval, c.AgentReportInterval)
+	return fmt.Sprintf("Config{ServerHost: %s, HistogramBuckets: %v, NameAllowedChars: %s, NameMaxLength: %d, ReservedPrefixes: %v, SanitizeNames: %t, StorageDir: %s, SnapshotInterval: %v, WALSync: %t, HistoryRetention: %v, HistoryTiers: %v, HistoryCompactInterval: %v, MaxSeries: %d, MaxSeriesPerName: %d, MaxNewSeries: %d, NewSeriesWindow: %v, InfluxCounterPattern: %s, OTLPPrefixAttributes: %v, GraphiteAddress: %s, GraphiteMaxConnections: %d, GraphiteIdleTimeout: %v, GraphiteAllowUnauthenticated: %t, AlertRulesFile: %s, AlertInterval: %v, RecordingRulesFile: %s, RecordingInterval: %v, TenantTokens: %d, AuthTokensFile: %s, WriteRateLimit: %g, WriteRateBurst: %d, ReadRateLimit: %g, ReadRateBurst: %d, AuditFile: %s, AuditWebhook: %s, AuditBufferSize: %d, AgentPollInterval: %v, AgentReportInterval: %v, AgentStatusAddress: %s, AgentMaxFailures: %d, AgentScrapeTargets: %v, AgentScrapeTimeout: %v, AgentTenant: %s}",
+		c.ServerHost, c.HistogramBuckets, c.NameAllowedChars, c.NameMaxLength, c.ReservedPrefixes, c.SanitizeNames, c.StorageDir, c.SnapshotInterval, c.WALSync, c.HistoryRetention, c.HistoryTiers, c.HistoryCompactInterval, c.MaxSeries, c.MaxSeriesPerName, c.MaxNewSeries, c.NewSeriesWindow, c.InfluxCounterPattern, c.OTLPPrefixAttributes, c.GraphiteAddress, c.GraphiteMaxConnections, c.GraphiteIdleTimeout, c.GraphiteAllowUnauthenticated, c.AlertRulesFile, c.AlertInterval, c.RecordingRulesFile, c.RecordingInterval, len(c.TenantTokens), c.AuthTokensFile, c.WriteRateLimit, c.WriteRateBurst, c.ReadRateLimit, c.ReadRateBurst, c.AuditFile, c.AuditWebhook, c.AuditBufferSize, c.AgentPollInterval, c.AgentReportInterval, c.AgentStatusAddress, c.AgentMaxFailures, c.AgentScrapeTargets, c.AgentScrapeTimeout, c.AgentTenant)
 }
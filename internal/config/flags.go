@@ -2,7 +2,12 @@ package config
 
 import (
 	"flag"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/prbllm/go-metrics/internal/tsdb"
 )
 
 func ParseFlags(flagsetName string, args []string, flagErrorHandling flag.ErrorHandling) *Config {
@@ -12,11 +17,98 @@ func ParseFlags(flagsetName string, args []string, flagErrorHandling flag.ErrorH
 
 	fs.StringVar(&config.ServerHost, "a", config.ServerHost, "Server address (default: localhost:8080)")
 
+	fs.Func("histogram-buckets", "Comma-separated histogram bucket bounds (default: Prometheus default buckets)", func(value string) error {
+		buckets, err := parseFloatList(value)
+		if err != nil {
+			return err
+		}
+		config.HistogramBuckets = buckets
+		return nil
+	})
+
+	fs.StringVar(&config.NameAllowedChars, "name-chars", config.NameAllowedChars, "Allowed characters of metric names as a regexp character class (default: a-zA-Z0-9_:)")
+	fs.IntVar(&config.NameMaxLength, "name-max-length", config.NameMaxLength, "Maximum metric name length (default: 255)")
+	fs.Func("reserved-prefixes", "Comma-separated metric name prefixes clients cannot write (default: gometrics_)", func(value string) error {
+		config.ReservedPrefixes = parseStringList(value)
+		return nil
+	})
+	fs.BoolVar(&config.SanitizeNames, "sanitize-names", config.SanitizeNames, "Replace invalid characters in metric and label names instead of rejecting them")
+
+	fs.StringVar(&config.StorageDir, "storage-dir", config.StorageDir, "Directory for the write-ahead log and snapshots of stored metrics, empty keeps metrics only in memory")
+	fs.DurationVar(&config.SnapshotInterval, "snapshot-interval", config.SnapshotInterval, "Interval between snapshots that truncate the write-ahead log (default: 5m)")
+	fs.BoolVar(&config.WALSync, "wal-sync", config.WALSync, "Flush every write-ahead log record to disk so updates survive an operating system crash, not only a process crash")
+
+	fs.DurationVar(&config.HistoryRetention, "history-retention", config.HistoryRetention, "How long raw values of counters and gauges are kept in memory for /history queries, 0 disables history")
+	fs.Func("history-tiers", "Comma-separated resolution=retention pairs of history rollup tiers (default: 1m=720h,1h=8760h)", func(value string) error {
+		tiers, err := parseTierList(value)
+		if err != nil {
+			return err
+		}
+		config.HistoryTiers = tiers
+		return nil
+	})
+	fs.DurationVar(&config.HistoryCompactInterval, "history-compact-interval", config.HistoryCompactInterval, "Interval between history rollups and retention cleanups (default: 1m)")
+
+	fs.IntVar(&config.MaxSeries, "max-series", config.MaxSeries, "Maximum number of stored series, 0 means unlimited")
+	fs.IntVar(&config.MaxSeriesPerName, "max-series-per-metric", config.MaxSeriesPerName, "Maximum number of series with the same metric name, 0 means unlimited")
+	fs.IntVar(&config.MaxNewSeries, "max-new-series", config.MaxNewSeries, "Maximum number of new series per window, 0 means unlimited")
+	fs.DurationVar(&config.NewSeriesWindow, "new-series-window", config.NewSeriesWindow, "Window for -max-new-series (default: 1m)")
+
+	fs.StringVar(&config.InfluxCounterPattern, "influx-counter-pattern", config.InfluxCounterPattern, "Regexp of metric names whose integer line protocol fields are stored as counters, empty stores all as gauges (example: _total$)")
+
+	fs.Func("otlp-prefix-attributes", "Comma-separated OTLP resource attributes whose values prefix metric IDs instead of becoming labels (example: service.name)", func(value string) error {
+		config.OTLPPrefixAttributes = parseStringList(value)
+		return nil
+	})
+	fs.StringVar(&config.GraphiteAddress, "graphite-address", config.GraphiteAddress, "Graphite plaintext protocol TCP listener address, empty disables it (example: localhost:2003)")
+	fs.IntVar(&config.GraphiteMaxConnections, "graphite-max-connections", config.GraphiteMaxConnections, "Maximum number of concurrent Graphite connections, 0 means unlimited (default: 100)")
+	fs.DurationVar(&config.GraphiteIdleTimeout, "graphite-idle-timeout", config.GraphiteIdleTimeout, "Idle timeout after which Graphite connections are closed, 0 disables it (default: 1m)")
+	fs.BoolVar(&config.GraphiteAllowUnauthenticated, "graphite-allow-unauthenticated", config.GraphiteAllowUnauthenticated, "Allow the unauthenticated Graphite listener together with -auth-tokens or -tenant-tokens")
+
+	fs.StringVar(&config.AlertRulesFile, "alert-rules", config.AlertRulesFile, "Path to a JSON file with alerting rules and webhooks, empty disables alerting")
+	fs.DurationVar(&config.AlertInterval, "alert-interval", config.AlertInterval, "Alerting rules evaluation interval (default: 15s)")
+
+	fs.StringVar(&config.RecordingRulesFile, "recording-rules", config.RecordingRulesFile, "Path to a JSON file with recording rules, empty disables them")
+	fs.DurationVar(&config.RecordingInterval, "recording-interval", config.RecordingInterval, "Recording rules evaluation interval (default: 15s)")
+
+	fs.Func("tenant-tokens", "Comma-separated token=tenant pairs; when set, the tenant is taken from the Authorization: Bearer token instead of the X-Tenant-ID header", func(value string) error {
+		tokens, err := parseKeyValueList(value)
+		if err != nil {
+			return err
+		}
+		config.TenantTokens = tokens
+		return nil
+	})
+
+	fs.StringVar(&config.AuthTokensFile, "auth-tokens", config.AuthTokensFile, "Path to a JSON file with API tokens and their scopes, empty disables authentication")
+
+	fs.Float64Var(&config.WriteRateLimit, "rate-limit-write", config.WriteRateLimit, "Requests per second each client may send to update and ingestion routes, 0 means unlimited")
+	fs.IntVar(&config.WriteRateBurst, "rate-limit-write-burst", config.WriteRateBurst, "Burst of -rate-limit-write, 0 means the per-second rate rounded up")
+	fs.Float64Var(&config.ReadRateLimit, "rate-limit-read", config.ReadRateLimit, "Requests per second each client may send to read routes, 0 means unlimited")
+	fs.IntVar(&config.ReadRateBurst, "rate-limit-read-burst", config.ReadRateBurst, "Burst of -rate-limit-read, 0 means the per-second rate rounded up")
+
+	fs.StringVar(&config.AuditFile, "audit-file", config.AuditFile, "Path to an append-only audit log of accepted metric updates and deletes, empty disables it")
+	fs.StringVar(&config.AuditWebhook, "audit-webhook", config.AuditWebhook, "URL receiving audit events as JSON arrays, empty disables it")
+	fs.IntVar(&config.AuditBufferSize, "audit-buffer", config.AuditBufferSize, "Number of audit events queued for writing; events beyond it are dropped (default: 10000)")
+
 	var reportIntervalSec int
 	var pollIntervalSec int
 	fs.IntVar(&reportIntervalSec, "r", int(config.AgentReportInterval.Seconds()), "Agent report interval in seconds (default: 10)")
 	fs.IntVar(&pollIntervalSec, "p", int(config.AgentPollInterval.Seconds()), "Agent poll interval in seconds (default: 2)")
 
+	fs.StringVar(&config.AgentStatusAddress, "status-address", config.AgentStatusAddress, "Agent status listener address, empty disables it (example: localhost:8081)")
+	fs.IntVar(&config.AgentMaxFailures, "status-max-failures", config.AgentMaxFailures, "Consecutive failed reports after which agent /healthz returns 503, 0 disables the check (default: 3)")
+
+	fs.Func("scrape-targets", "Comma-separated URLs of Prometheus text format endpoints scraped by the agent (example: http://localhost:9100/metrics)", func(value string) error {
+		config.AgentScrapeTargets = parseStringList(value)
+		return nil
+	})
+	fs.DurationVar(&config.AgentScrapeTimeout, "scrape-timeout", config.AgentScrapeTimeout, "Timeout of a single target scrape (default: 5s)")
+
+	fs.StringVar(&config.AgentTenant, "tenant", config.AgentTenant, "Tenant the agent reports metrics to via the X-Tenant-ID header, empty uses the default namespace")
+
+	fs.StringVar(&config.AgentToken, "token", config.AgentToken, "API token the agent sends in the Authorization: Bearer header")
+
 	fs.Parse(args)
 
 	config.AgentReportInterval = time.Duration(reportIntervalSec) * time.Second
@@ -24,3 +116,60 @@ func ParseFlags(flagsetName string, args []string, flagErrorHandling flag.ErrorH
 
 	return config
 }
+
+func parseFloatList(value string) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	result := make([]float64, 0, len(parts))
+	for _, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q: %w", part, err)
+		}
+		result = append(result, f)
+	}
+	return result, nil
+}
+
+func parseStringList(value string) []string {
+	result := []string{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+func parseKeyValueList(value string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, part := range parseStringList(value) {
+		key, val, ok := strings.Cut(part, "=")
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		if !ok || key == "" || val == "" {
+			return nil, fmt.Errorf("invalid pair %q: expected key=value", part)
+		}
+		result[key] = val
+	}
+	return result, nil
+}
+
+func parseTierList(value string) ([]tsdb.Tier, error) {
+	tiers := []tsdb.Tier{}
+	for _, part := range parseStringList(value) {
+		resolution, retention, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid tier %q: expected resolution=retention", part)
+		}
+		var tier tsdb.Tier
+		var err error
+		if tier.Resolution, err = time.ParseDuration(strings.TrimSpace(resolution)); err != nil {
+			return nil, fmt.Errorf("invalid tier %q: %w", part, err)
+		}
+		if tier.Retention, err = time.ParseDuration(strings.TrimSpace(retention)); err != nil {
+			return nil, fmt.Errorf("invalid tier %q: %w", part, err)
+		}
+		tiers = append(tiers, tier)
+	}
+	return tiers, nil
+}
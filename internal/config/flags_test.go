@@ -2,9 +2,11 @@ package config
 
 import (
 	"flag"
+	"math"
 	"testing"
 	"time"
 
+	"github.com/prbllm/go-metrics/internal/tsdb"
 	"github.com/stretchr/testify/require"
 )
 
@@ -44,6 +46,49 @@ func TestParseArgs(t *testing.T) {
 				return cfg
 			},
 		},
+		{
+			name: "Histogram buckets",
+			args: []string{"-histogram-buckets", "0.1, 1,10"},
+			expected: func() Config {
+				cfg := *defaultConfig()
+				cfg.HistogramBuckets = []float64{0.1, 1, 10}
+				return cfg
+			},
+		},
+		{
+			name: "Tenant tokens",
+			args: []string{"-tenant-tokens", "t1=team-a, t2=team-b", "-tenant", "team-a"},
+			expected: func() Config {
+				cfg := *defaultConfig()
+				cfg.TenantTokens = map[string]string{"t1": "team-a", "t2": "team-b"}
+				cfg.AgentTenant = "team-a"
+				return cfg
+			},
+		},
+		{
+			name: "Graphite with auth tokens",
+			args: []string{"-graphite-address", "localhost:2003", "-auth-tokens", "tokens.json", "-graphite-allow-unauthenticated"},
+			expected: func() Config {
+				cfg := *defaultConfig()
+				cfg.GraphiteAddress = "localhost:2003"
+				cfg.AuthTokensFile = "tokens.json"
+				cfg.GraphiteAllowUnauthenticated = true
+				return cfg
+			},
+		},
+		{
+			name: "History tiers",
+			args: []string{"-history-retention", "24h", "-history-tiers", "1m=720h, 1h=8760h"},
+			expected: func() Config {
+				cfg := *defaultConfig()
+				cfg.HistoryRetention = 24 * time.Hour
+				cfg.HistoryTiers = []tsdb.Tier{
+					{Resolution: time.Minute, Retention: 720 * time.Hour},
+					{Resolution: time.Hour, Retention: 8760 * time.Hour},
+				}
+				return cfg
+			},
+		},
 		{
 			name: "unknown_flag_rejected",
 			args: []string{"-foo"},
@@ -60,6 +105,65 @@ func TestParseArgs(t *testing.T) {
 			require.Equal(t, expected.ServerHost, got.ServerHost, "ServerHost is not equal to expected")
 			require.Equal(t, expected.AgentPollInterval, got.AgentPollInterval, "AgentPollInterval is not equal to expected")
 			require.Equal(t, expected.AgentReportInterval, got.AgentReportInterval, "AgentReportInterval is not equal to expected")
+			require.Equal(t, expected.HistogramBuckets, got.HistogramBuckets, "HistogramBuckets is not equal to expected")
+			require.Equal(t, expected.TenantTokens, got.TenantTokens, "TenantTokens is not equal to expected")
+			require.Equal(t, expected.AgentTenant, got.AgentTenant, "AgentTenant is not equal to expected")
+			require.Equal(t, expected.HistoryRetention, got.HistoryRetention, "HistoryRetention is not equal to expected")
+			require.Equal(t, expected.HistoryTiers, got.HistoryTiers, "HistoryTiers is not equal to expected")
+			require.Equal(t, expected.GraphiteAddress, got.GraphiteAddress, "GraphiteAddress is not equal to expected")
+			require.Equal(t, expected.AuthTokensFile, got.AuthTokensFile, "AuthTokensFile is not equal to expected")
+			require.Equal(t, expected.GraphiteAllowUnauthenticated, got.GraphiteAllowUnauthenticated, "GraphiteAllowUnauthenticated is not equal to expected")
+		})
+	}
+}
+
+func TestValidateGraphiteAuthentication(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(cfg *Config)
+		wantErr bool
+	}{
+		{name: "graphite without auth", modify: func(cfg *Config) {}},
+		{name: "auth without graphite", modify: func(cfg *Config) { cfg.GraphiteAddress = ""; cfg.AuthTokensFile = "tokens.json" }},
+		{name: "graphite with auth tokens", modify: func(cfg *Config) { cfg.AuthTokensFile = "tokens.json" }, wantErr: true},
+		{name: "graphite with tenant tokens", modify: func(cfg *Config) { cfg.TenantTokens = map[string]string{"t1": "team-a"} }, wantErr: true},
+		{name: "graphite with auth tokens allowed", modify: func(cfg *Config) {
+			cfg.AuthTokensFile = "tokens.json"
+			cfg.GraphiteAllowUnauthenticated = true
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.GraphiteAddress = "localhost:2003"
+			tc.modify(cfg)
+			err := cfg.Validate()
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
 		})
 	}
 }
+
+func TestValidateHistogramBuckets(t *testing.T) {
+	for _, buckets := range [][]float64{nil, {1, 1}, {2, 1}, {0.1, math.NaN()}, {1, math.Inf(1)}, {math.Inf(-1), 1}} {
+		cfg := defaultConfig()
+		cfg.HistogramBuckets = buckets
+		require.Error(t, cfg.Validate(), "buckets %v", buckets)
+	}
+}
+
+func TestValidateNamingPolicy(t *testing.T) {
+	for _, modify := range []func(cfg *Config){
+		func(cfg *Config) { cfg.NameAllowedChars = "" },
+		func(cfg *Config) { cfg.NameAllowedChars = "z-a" },
+		func(cfg *Config) { cfg.NameMaxLength = 0 },
+	} {
+		cfg := defaultConfig()
+		modify(cfg)
+		require.Error(t, cfg.Validate())
+	}
+}
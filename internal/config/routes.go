@@ -1,7 +1,23 @@
 package config
 
 const (
-	ValuePath  = "/value"
-	UpdatePath = "/update"
-	CommonPath = "/"
+	ValuePath   = "/value"
+	UpdatePath  = "/update"
+	UpdatesPath = "/updates"
+	CommonPath  = "/"
+	MetricsPath = "/metrics"
+	WritePath   = "/write"
+
+	OTLPMetricsPath = "/v1/metrics"
+	RemoteWritePath = "/api/v1/write"
+
+	AlertsPath  = "/alerts"
+	HistoryPath = "/history"
+
+	AdminExportPath = "/admin/export"
+	AdminImportPath = "/admin/import"
+
+	PingPath      = "/ping"
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
 )
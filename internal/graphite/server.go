@@ -0,0 +1,205 @@
+// Package graphite принимает метрики по текстовому протоколу Graphite (plaintext):
+//
+//	path[;tag=value...] value [timestamp]
+//
+// Точки в пути заменяются на "_", теги становятся метками, все значения сохраняются как gauge.
+package graphite
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/service"
+)
+
+const maxLineLength = 64 * 1024
+
+type Server struct {
+	service        service.Service
+	maxConnections int
+	idleTimeout    time.Duration
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewServer создает сервер. maxConnections <= 0 снимает ограничение на число соединений,
+// idleTimeout <= 0 отключает закрытие неактивных соединений.
+func NewServer(service service.Service, maxConnections int, idleTimeout time.Duration) *Server {
+	return &Server{
+		service:        service,
+		maxConnections: maxConnections,
+		idleTimeout:    idleTimeout,
+		conns:          make(map[net.Conn]struct{}),
+	}
+}
+
+func (s *Server) ListenAndServe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve принимает соединения, пока listener не будет закрыт через Close.
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		listener.Close()
+		return net.ErrClosed
+	}
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return nil
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+		if !s.track(conn) {
+			fmt.Printf("Graphite connection from %s rejected: limit of %d connections reached\n", conn.RemoteAddr(), s.maxConnections)
+			conn.Close()
+			continue
+		}
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+// Close закрывает listener и все открытые соединения и ждет завершения их обработки.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || (s.maxConnections > 0 && len(s.conns) >= s.maxConnections) {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer s.untrack(conn)
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), maxLineLength)
+	for {
+		if s.idleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.idleTimeout))
+		}
+		if !scanner.Scan() {
+			break
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		metric, err := ParseLine(line)
+		if err != nil {
+			fmt.Printf("Invalid graphite line from %s: %v\n", conn.RemoteAddr(), err)
+			continue
+		}
+		if _, err := s.service.SaveMetric(metric); err != nil {
+			fmt.Printf("Error saving graphite metric %s: %v\n", metric.FullID(), err)
+		}
+	}
+
+	var netErr net.Error
+	if err := scanner.Err(); err != nil && !(errors.As(err, &netErr) && netErr.Timeout()) && !s.isClosed() {
+		fmt.Printf("Error reading graphite connection from %s: %v\n", conn.RemoteAddr(), err)
+	}
+}
+
+// ParseLine разбирает строку "path[;tag=value...] value [timestamp]" в gauge.
+// Timestamp проверяется, но не сохраняется.
+func ParseLine(line string) (*model.Metrics, error) {
+	parts := strings.Fields(line)
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, fmt.Errorf("expected \"path value [timestamp]\", got %q", line)
+	}
+
+	segments := strings.Split(parts[0], ";")
+	path := segments[0]
+	if path == "" || strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".") || strings.Contains(path, "..") {
+		return nil, fmt.Errorf("invalid path %q", parts[0])
+	}
+
+	var labels map[string]string
+	for _, tag := range segments[1:] {
+		name, value, ok := strings.Cut(tag, "=")
+		if !ok || name == "" || value == "" {
+			return nil, fmt.Errorf("invalid tag %q", tag)
+		}
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[name] = value
+	}
+
+	value, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, fmt.Errorf("invalid value %q", parts[1])
+	}
+
+	if len(parts) == 3 {
+		if _, err := strconv.ParseFloat(parts[2], 64); err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q", parts[2])
+		}
+	}
+
+	return &model.Metrics{
+		ID:     strings.ReplaceAll(path, ".", "_"),
+		MType:  model.Gauge,
+		Value:  &value,
+		Labels: labels,
+	}, nil
+}
@@ -0,0 +1,130 @@
+package graphite
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/repository"
+	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		id      string
+		value   float64
+		labels  map[string]string
+		wantErr bool
+	}{
+		{name: "with timestamp", line: "servers.web1.cpu 0.5 1700000000", id: "servers_web1_cpu", value: 0.5},
+		{name: "without timestamp", line: "load 3", id: "load", value: 3},
+		{name: "tags", line: "disk.free;host=a;mount=/ 10 -1", id: "disk_free", value: 10, labels: map[string]string{"host": "a", "mount": "/"}},
+		{name: "missing value", line: "load", wantErr: true},
+		{name: "invalid value", line: "load abc 1700000000", wantErr: true},
+		{name: "nan value", line: "load nan 1700000000", wantErr: true},
+		{name: "invalid timestamp", line: "load 1 now", wantErr: true},
+		{name: "empty path segment", line: "servers..cpu 1", wantErr: true},
+		{name: "invalid tag", line: "load;host 1", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			metric, err := ParseLine(test.line)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.id, metric.ID)
+			require.Equal(t, model.Gauge, metric.MType)
+			require.Equal(t, test.value, *metric.Value)
+			require.Equal(t, test.labels, metric.Labels)
+		})
+	}
+}
+
+func startServer(t *testing.T, maxConnections int, idleTimeout time.Duration) (*Server, repository.MetricsRepository, string) {
+	t.Helper()
+	storage := repository.NewMemStorage()
+	server := NewServer(service.NewMetricsService(storage), maxConnections, idleTimeout)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(listener) }()
+	t.Cleanup(func() {
+		require.NoError(t, server.Close())
+		require.NoError(t, <-done)
+	})
+	return server, storage, listener.Addr().String()
+}
+
+func TestServerStoresMetrics(t *testing.T) {
+	_, storage, address := startServer(t, 0, 0)
+
+	conn, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	_, err = fmt.Fprint(conn, "servers.web1.cpu 0.5 1700000000\nbroken\nservers.web1.cpu 0.75 1700000010\nload;host=a 2\n")
+	require.NoError(t, err)
+	conn.Close()
+
+	require.Eventually(t, func() bool {
+		metric, err := storage.GetMetric(&model.Metrics{MType: model.Gauge, ID: "load", Labels: map[string]string{"host": "a"}})
+		return err == nil && *metric.Value == 2
+	}, time.Second, 10*time.Millisecond)
+
+	metric, err := storage.GetMetric(&model.Metrics{MType: model.Gauge, ID: "servers_web1_cpu"})
+	require.NoError(t, err)
+	require.Equal(t, 0.75, *metric.Value)
+}
+
+func TestServerClosesIdleConnections(t *testing.T) {
+	_, _, address := startServer(t, 0, 50*time.Millisecond)
+
+	conn, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.EOF, "Idle connection must be closed by server")
+}
+
+func TestServerLimitsConnections(t *testing.T) {
+	_, storage, address := startServer(t, 1, 0)
+
+	first, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	defer first.Close()
+	_, err = fmt.Fprint(first, "first 1\n")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		_, err := storage.GetMetric(&model.Metrics{MType: model.Gauge, ID: "first"})
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	second, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = second.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.EOF, "Connection over the limit must be closed")
+
+	first.Close()
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			return false
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "third 1\n")
+		_, err = storage.GetMetric(&model.Metrics{MType: model.Gauge, ID: "third"})
+		return err == nil
+	}, time.Second, 20*time.Millisecond)
+}
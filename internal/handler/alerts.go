@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prbllm/go-metrics/internal/alerting"
+	"github.com/prbllm/go-metrics/internal/tenant"
+)
+
+type AlertsHandler struct {
+	engine *alerting.Engine
+}
+
+func NewAlertsHandler(engine *alerting.Engine) *AlertsHandler {
+	return &AlertsHandler{engine: engine}
+}
+
+// ListHandler возвращает текущие pending и firing алерты. Правила вычисляются только
+// над рядами пространства по умолчанию, поэтому запросы арендаторов получают пустой
+// список и не видят чужие имена, метки и значения.
+func (h *AlertsHandler) ListHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("method=%s uri=%s\n", r.Method, r.RequestURI)
+	if tenant.FromContext(r.Context()) != "" {
+		writeJSON(w, http.StatusOK, []alerting.Alert{})
+		return
+	}
+	writeJSON(w, http.StatusOK, h.engine.Alerts())
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/prbllm/go-metrics/internal/audit"
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/prbllm/go-metrics/internal/tenant"
+)
+
+type Option func(*requestScope)
+
+// WithAuditLog записывает в журнал аудита каждое принятое изменение метрик.
+func WithAuditLog(auditLog *audit.Logger) Option {
+	return func(s *requestScope) {
+		s.auditLog = auditLog
+	}
+}
+
+// requestScope выдает обработчикам сервис, ограниченный запросом: арендатором и,
+// для изменений, журналом аудита.
+type requestScope struct {
+	service  service.Service
+	auditLog *audit.Logger
+}
+
+func newRequestScope(service service.Service, opts []Option) requestScope {
+	scope := requestScope{service: service}
+	for _, opt := range opts {
+		opt(&scope)
+	}
+	return scope
+}
+
+// tenantService возвращает сервис, ограниченный арендатором запроса.
+func (s *requestScope) tenantService(r *http.Request) service.Service {
+	return s.service.ForTenant(tenant.FromContext(r.Context()))
+}
+
+// writeService возвращает сервис арендатора запроса, который запоминает принятые
+// изменения. done записывает их в журнал аудита одним событием на действие и должна
+// вызываться после обработки запроса.
+func (s *requestScope) writeService(r *http.Request) (tenantService service.Service, done func()) {
+	tenantService = s.tenantService(r)
+	if s.auditLog == nil {
+		return tenantService, func() {}
+	}
+	recorder := &auditRecorder{auditLog: s.auditLog, source: audit.NewSource(r)}
+	return &auditedService{Service: tenantService, recorder: recorder}, recorder.flush
+}
+
+type auditRecorder struct {
+	auditLog *audit.Logger
+	source   audit.Source
+	updates  []audit.Change
+	deletes  []audit.Change
+}
+
+func (r *auditRecorder) flush() {
+	if len(r.updates) > 0 {
+		r.auditLog.Record(audit.Event{Source: r.source, Action: audit.ActionUpdate, Changes: r.updates})
+	}
+	if len(r.deletes) > 0 {
+		r.auditLog.Record(audit.Event{Source: r.source, Action: audit.ActionDelete, Changes: r.deletes})
+	}
+	r.updates, r.deletes = nil, nil
+}
+
+// auditedService запоминает изменения, принятые сервисом. Пакеты метрик сервис
+// записывает атомарно, поэтому при ошибке в журнал не попадает ни одно изменение пакета.
+type auditedService struct {
+	service.Service
+	recorder *auditRecorder
+}
+
+func (s *auditedService) UpdateMetric(metricType, metricName, metricValue string, labels map[string]string) error {
+	if err := s.Service.UpdateMetric(metricType, metricName, metricValue, labels); err != nil {
+		return err
+	}
+	change := s.change(metricType, metricName, labels)
+	change.Value = metricValue
+	s.recorder.updates = append(s.recorder.updates, change)
+	return nil
+}
+
+func (s *auditedService) SaveMetric(metric *model.Metrics) (*model.Metrics, error) {
+	saved, err := s.Service.SaveMetric(metric)
+	if err != nil {
+		return nil, err
+	}
+	s.recorder.updates = append(s.recorder.updates, audit.NewChange(metric))
+	return saved, nil
+}
+
+func (s *auditedService) SaveMetrics(metrics []*model.Metrics) ([]*model.Metrics, error) {
+	saved, err := s.Service.SaveMetrics(metrics)
+	if err != nil {
+		return nil, err
+	}
+	for _, metric := range metrics {
+		s.recorder.updates = append(s.recorder.updates, audit.NewChange(metric))
+	}
+	return saved, nil
+}
+
+func (s *auditedService) DeleteMetric(metricType, metricName string, labels map[string]string) error {
+	change := s.change(metricType, metricName, labels)
+	if err := s.Service.DeleteMetric(metricType, metricName, labels); err != nil {
+		return err
+	}
+	s.recorder.deletes = append(s.recorder.deletes, change)
+	return nil
+}
+
+// change описывает ряд так, как он хранится: сервис приводит имя и метки по политике
+// имен, поэтому они берутся из записанного ряда. Если ряд не найден, в журнал
+// попадают имя и метки из запроса.
+func (s *auditedService) change(metricType, metricName string, labels map[string]string) audit.Change {
+	if stored, err := s.Service.GetMetric(metricType, metricName, labels); err == nil {
+		return audit.Change{ID: stored.ID, Type: metricType, Labels: stored.Labels}
+	}
+	return audit.Change{ID: metricName, Type: metricType, Labels: labels}
+}
+
+func (s *auditedService) ForTenant(tenant string) service.Service {
+	return &auditedService{Service: s.Service.ForTenant(tenant), recorder: s.recorder}
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"embed"
+	"html/template"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/prbllm/go-metrics/internal/config"
+	"github.com/prbllm/go-metrics/internal/model"
+)
+
+//go:embed templates/dashboard.html
+var templatesFS embed.FS
+
+var dashboardTemplate = template.Must(template.ParseFS(templatesFS, "templates/dashboard.html"))
+
+var dashboardSections = []struct {
+	MType string
+	Title string
+}{
+	{model.Counter, "Counters"},
+	{model.Gauge, "Gauges"},
+	{model.Histogram, "Histograms"},
+	{model.Summary, "Summaries"},
+}
+
+var refreshOptions = []int{0, 5, 10, 30, 60}
+
+type dashboardRow struct {
+	Name   string
+	Labels string
+	Value  string
+	Link   string
+}
+
+type dashboardSection struct {
+	Title string
+	Rows  []dashboardRow
+}
+
+type refreshOption struct {
+	Seconds  int
+	Title    string
+	Selected bool
+}
+
+type dashboardData struct {
+	Tenant         string
+	Refresh        int
+	Filter         string
+	Matches        []string
+	RefreshOptions []refreshOption
+	Sections       []dashboardSection
+}
+
+// newDashboardData готовит данные для шаблона: метрики разбиты по типам и отсортированы
+// по имени и меткам, чтобы порядок строк не зависел от порядка обхода хранилища.
+func newDashboardData(metrics []*model.Metrics, tenant string, refresh int, filter string, matches []string) dashboardData {
+	data := dashboardData{Tenant: tenant, Refresh: refresh, Filter: filter, Matches: matches}
+
+	for _, seconds := range refreshOptions {
+		title := "off"
+		if seconds > 0 {
+			title = strconv.Itoa(seconds) + "s"
+		}
+		data.RefreshOptions = append(data.RefreshOptions, refreshOption{Seconds: seconds, Title: title, Selected: seconds == refresh})
+	}
+
+	byType := make(map[string][]*model.Metrics)
+	for _, metric := range metrics {
+		byType[metric.MType] = append(byType[metric.MType], metric)
+	}
+
+	for _, section := range dashboardSections {
+		sectionMetrics := byType[section.MType]
+		if len(sectionMetrics) == 0 {
+			continue
+		}
+		sort.Slice(sectionMetrics, func(i, j int) bool {
+			if sectionMetrics[i].ID != sectionMetrics[j].ID {
+				return sectionMetrics[i].ID < sectionMetrics[j].ID
+			}
+			return sectionMetrics[i].FullID() < sectionMetrics[j].FullID()
+		})
+
+		rows := make([]dashboardRow, 0, len(sectionMetrics))
+		for _, metric := range sectionMetrics {
+			rows = append(rows, dashboardRow{
+				Name:   metric.ID,
+				Labels: model.FormatLabels(metric.Labels),
+				Value:  dashboardValue(metric),
+				Link:   valueLink(metric),
+			})
+		}
+		data.Sections = append(data.Sections, dashboardSection{Title: section.Title, Rows: rows})
+	}
+	return data
+}
+
+func dashboardValue(metric *model.Metrics) string {
+	switch {
+	case metric.MType == model.Counter && metric.Delta != nil:
+		return strconv.FormatInt(*metric.Delta, 10)
+	case metric.MType == model.Gauge && metric.Value != nil:
+		return formatFloat(*metric.Value)
+	case metric.MType == model.Histogram || metric.MType == model.Summary:
+		return metric.DistributionString()
+	}
+	return "N/A"
+}
+
+// valueLink возвращает ссылку на текстовое значение метрики, метки передаются параметрами запроса.
+func valueLink(metric *model.Metrics) string {
+	link := config.ValuePath + "/" + url.PathEscape(metric.MType) + "/" + url.PathEscape(metric.ID)
+	if len(metric.Labels) > 0 {
+		query := url.Values{}
+		for name, value := range metric.Labels {
+			query.Set(name, value)
+		}
+		link += "?" + query.Encode()
+	}
+	return link
+}
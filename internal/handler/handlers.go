@@ -1,20 +1,28 @@
 package handler
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/repository"
 	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/prbllm/go-metrics/internal/tenant"
 )
 
 type Handlers struct {
-	service service.Service
+	requestScope
 }
 
-func NewHandlers(service service.Service) *Handlers {
-	return &Handlers{service: service}
+func NewHandlers(service service.Service, opts ...Option) *Handlers {
+	return &Handlers{requestScope: newRequestScope(service, opts)}
 }
 
 func (h *Handlers) UpdateMetricHandler(w http.ResponseWriter, r *http.Request) {
@@ -50,9 +58,11 @@ func (h *Handlers) UpdateMetricHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Printf("Received metric: Type=%s, Name=%s, Value=%s\n", metricType, metricName, metricValue)
 
 	if h.service != nil {
-		if err := h.service.UpdateMetric(metricType, metricName, metricValue); err != nil {
+		tenantService, done := h.writeService(r)
+		defer done()
+		if err := tenantService.UpdateMetric(metricType, metricName, metricValue, labelsFromQuery(r.URL.Query())); err != nil {
 			fmt.Printf("Error updating metric: %v\n", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			writeUpdateError(w, err)
 			return
 		}
 	} else {
@@ -75,40 +85,41 @@ func (h *Handlers) GetAllMetricsHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	metrics, err := h.service.GetAllMetrics()
+	matchers, err := matchersFromQuery(r.URL.Query())
+	if err != nil {
+		fmt.Printf("Invalid label matcher: %v\n", err)
+		http.Error(w, "Invalid label matcher", http.StatusBadRequest)
+		return
+	}
+
+	metrics, err := h.tenantService(r).GetAllMetrics(matchers...)
 	if err != nil {
 		fmt.Printf("Error getting metrics: %v\n", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		writeJSON(w, http.StatusOK, metrics)
+		return
+	}
 
-	html := `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <title>Metrics Dashboard</title>
-</head>
-<body>
-<ul>`
-
-	for _, metric := range metrics {
-		if metric.MType == model.Counter && metric.Delta != nil {
-			html += fmt.Sprintf(`<li>%s: %d</li>`, metric.ID, *metric.Delta)
-		} else if metric.MType == model.Gauge && metric.Value != nil {
-			html += fmt.Sprintf(`<li>%s: %f</li>`, metric.ID, *metric.Value)
-		} else {
-			html += fmt.Sprintf(`<li>%s: N/A</li>`, metric.ID)
-		}
+	refresh, err := strconv.Atoi(r.URL.Query().Get("refresh"))
+	if err != nil || refresh < 0 {
+		refresh = 0
 	}
+	data := newDashboardData(metrics, tenant.FromContext(r.Context()), refresh, r.URL.Query().Get("filter"), r.URL.Query()["match"])
 
-	html += `</ul>
-</body>
-</html>`
+	var buf bytes.Buffer
+	if err := dashboardTemplate.Execute(&buf, data); err != nil {
+		fmt.Printf("Error rendering dashboard: %v\n", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 
-	w.Write([]byte(html))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
 }
 
 func (h *Handlers) GetValueHandler(w http.ResponseWriter, r *http.Request) {
@@ -128,7 +139,7 @@ func (h *Handlers) GetValueHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	metric, err := h.service.GetMetric(metricType, metricName)
+	metric, err := h.tenantService(r).GetMetric(metricType, metricName, labelsFromQuery(r.URL.Query()))
 	if metric == nil || err != nil {
 		fmt.Printf("Error getting metric: %v\n", err)
 		http.Error(w, "Not found", http.StatusNotFound)
@@ -142,8 +153,195 @@ func (h *Handlers) GetValueHandler(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "%d", *metric.Delta)
 	} else if metric.MType == model.Gauge && metric.Value != nil {
 		fmt.Fprintf(w, "%g", *metric.Value)
+	} else if metric.MType == model.Histogram || metric.MType == model.Summary {
+		fmt.Fprint(w, metric.DistributionString())
 	} else {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 }
+
+func (h *Handlers) DeleteMetricHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("method=%s uri=%s\n", r.Method, r.RequestURI)
+	if r.Method != http.MethodDelete {
+		fmt.Printf("Method %s not allowed\n", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	metricType := chi.URLParam(r, "metricType")
+	metricName := chi.URLParam(r, "metricName")
+
+	if metricType == "" || metricName == "" {
+		fmt.Printf("Invalid path: Type=%s, Name=%s\n", metricType, metricName)
+		http.NotFound(w, r)
+		return
+	}
+
+	tenantService, done := h.writeService(r)
+	defer done()
+	err := tenantService.DeleteMetric(metricType, metricName, labelsFromQuery(r.URL.Query()))
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusOK)
+	case errors.Is(err, repository.ErrMetricNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidMetricName):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		fmt.Printf("Error deleting metric: %v\n", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handlers) UpdateMetricJSONHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("method=%s uri=%s\n", r.Method, r.RequestURI)
+	if r.Method != http.MethodPost {
+		fmt.Printf("Method %s not allowed\n", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var metric model.Metrics
+	if err := json.NewDecoder(r.Body).Decode(&metric); err != nil {
+		fmt.Printf("Error decoding metric: %v\n", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := service.ValidateMetric(&metric); err != nil {
+		fmt.Printf("Invalid metric: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fmt.Printf("Received metric: %s\n", metric.String())
+
+	tenantService, done := h.writeService(r)
+	defer done()
+	updated, err := tenantService.SaveMetric(&metric)
+	if err != nil {
+		fmt.Printf("Error updating metric: %v\n", err)
+		writeUpdateError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *Handlers) UpdateMetricsBatchHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("method=%s uri=%s\n", r.Method, r.RequestURI)
+	if r.Method != http.MethodPost {
+		fmt.Printf("Method %s not allowed\n", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var metrics []*model.Metrics
+	if err := json.NewDecoder(r.Body).Decode(&metrics); err != nil {
+		fmt.Printf("Error decoding metrics: %v\n", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	for i, metric := range metrics {
+		if err := service.ValidateMetric(metric); err != nil {
+			fmt.Printf("Invalid metric #%d: %v\n", i, err)
+			http.Error(w, fmt.Sprintf("metric #%d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	fmt.Printf("Received %d metrics\n", len(metrics))
+
+	tenantService, done := h.writeService(r)
+	defer done()
+	updated, err := tenantService.SaveMetrics(metrics)
+	if err != nil {
+		fmt.Printf("Error updating metrics: %v\n", err)
+		writeUpdateError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *Handlers) GetValueJSONHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("method=%s uri=%s\n", r.Method, r.RequestURI)
+	if r.Method != http.MethodPost {
+		fmt.Printf("Method %s not allowed\n", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request model.Metrics
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		fmt.Printf("Error decoding metric: %v\n", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if request.ID == "" || service.ValidateMetricType(request.MType) != nil {
+		fmt.Printf("Invalid metric request: %s\n", request.String())
+		http.Error(w, "Invalid metric request", http.StatusBadRequest)
+		return
+	}
+
+	metric, err := h.tenantService(r).GetMetric(request.MType, request.ID, request.Labels)
+	if metric == nil || err != nil {
+		fmt.Printf("Error getting metric: %v\n", err)
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, metric)
+}
+
+// writeUpdateError отвечает 400 на ошибки, вызванные содержимым обновления,
+// 429 при превышении лимитов кардинальности и 500 на остальные.
+func writeUpdateError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrBucketsMismatch), errors.Is(err, service.ErrInvalidMetricName):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repository.ErrCardinalityLimit):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Printf("Error encoding response: %v\n", err)
+	}
+}
+
+// labelsFromQuery превращает параметры запроса в метки метрики: ?host=a&service=b.
+func labelsFromQuery(query url.Values) map[string]string {
+	if len(query) == 0 {
+		return nil
+	}
+	labels := make(map[string]string, len(query))
+	for name, values := range query {
+		if name == "" || len(values) == 0 {
+			continue
+		}
+		labels[name] = values[len(values)-1]
+	}
+	return labels
+}
+
+// matchersFromQuery разбирает фильтры по меткам из параметров match: ?match=host=a&match=service!~"db.*".
+func matchersFromQuery(query url.Values) ([]*model.LabelMatcher, error) {
+	matchers := make([]*model.LabelMatcher, 0, len(query["match"]))
+	for _, raw := range query["match"] {
+		matcher, err := model.ParseLabelMatcher(raw)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matcher)
+	}
+	return matchers, nil
+}
@@ -1,25 +1,55 @@
 package handler
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/golang/snappy"
+	"github.com/prbllm/go-metrics/internal/alerting"
+	"github.com/prbllm/go-metrics/internal/audit"
+	"github.com/prbllm/go-metrics/internal/auth"
 	"github.com/prbllm/go-metrics/internal/config"
+	"github.com/prbllm/go-metrics/internal/influx"
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/otlp"
+	"github.com/prbllm/go-metrics/internal/remotewrite"
+	"github.com/prbllm/go-metrics/internal/repository"
 	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/prbllm/go-metrics/internal/tenant"
+	"github.com/prbllm/go-metrics/internal/tsdb"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
 )
 
 func setupTestRouter(handlers *Handlers) *chi.Mux {
 	router := chi.NewRouter()
 	router.Route(config.CommonPath, func(r chi.Router) {
 		r.Get("/", handlers.GetAllMetricsHandler)
+		r.Get(config.MetricsPath, handlers.GetPrometheusMetricsHandler)
+		r.Get(config.PingPath, handlers.ReadinessHandler)
+		r.Get(config.LivenessPath, handlers.LivenessHandler)
+		r.Get(config.ReadinessPath, handlers.ReadinessHandler)
 		r.Route(config.UpdatePath, func(r chi.Router) {
+			r.Post("/", handlers.UpdateMetricJSONHandler)
 			r.Post("/{metricType}/{metricName}/{metricValue}", handlers.UpdateMetricHandler)
 		})
+		r.Route(config.UpdatesPath, func(r chi.Router) {
+			r.Post("/", handlers.UpdateMetricsBatchHandler)
+		})
 		r.Route(config.ValuePath, func(r chi.Router) {
+			r.Post("/", handlers.GetValueJSONHandler)
 			r.Get("/{metricType}/{metricName}", handlers.GetValueHandler)
+			r.Delete("/{metricType}/{metricName}", handlers.DeleteMetricHandler)
 		})
 	})
 	return router
@@ -74,6 +104,18 @@ func TestUpdateHandler(t *testing.T) {
 			path:               "/update/gauge/test/invalid",
 			expectedStatusCode: http.StatusBadRequest,
 		},
+		{
+			name:               "valid histogram observation",
+			method:             http.MethodPost,
+			path:               "/update/histogram/latency/0.25",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "summary observation via text API",
+			method:             http.MethodPost,
+			path:               "/update/summary/latency/0.25",
+			expectedStatusCode: http.StatusBadRequest,
+		},
 	}
 
 	for _, test := range tests {
@@ -202,3 +244,710 @@ func TestGetValueHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateMetricJSONHandler(t *testing.T) {
+	tests := []struct {
+		name               string
+		body               string
+		expectedStatusCode int
+	}{
+		{
+			name:               "valid counter",
+			body:               `{"id":"requests","type":"counter","delta":1,"labels":{"host":"a"}}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "valid gauge",
+			body:               `{"id":"temperature","type":"gauge","value":36.6}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "valid histogram",
+			body:               `{"id":"latency","type":"histogram","count":2,"sum":0.6,"buckets":[{"le":0.5,"count":1},{"le":1,"count":2}]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "valid summary",
+			body:               `{"id":"latency","type":"summary","count":2,"sum":0.6,"quantiles":[{"quantile":0.5,"value":0.3}]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "histogram without count",
+			body:               `{"id":"latency","type":"histogram","sum":0.6}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "counter without delta",
+			body:               `{"id":"requests","type":"counter"}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "invalid type",
+			body:               `{"id":"requests","type":"invalid","delta":1}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "invalid json",
+			body:               `{"id":`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handlers := NewHandlers(&service.MockMetricsService{})
+			router := setupTestRouter(handlers)
+
+			req := httptest.NewRequest(http.MethodPost, "/update/", strings.NewReader(test.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+			require.Equal(t, test.expectedStatusCode, rr.Code, "Expected status code %d, got %d", test.expectedStatusCode, rr.Code)
+		})
+	}
+}
+
+func TestFormatPrometheus(t *testing.T) {
+	delta := int64(3)
+	otherDelta := int64(5)
+	value := 1.5
+	metrics := []*model.Metrics{
+		{ID: "requests", MType: model.Counter, Delta: &otherDelta, Labels: map[string]string{"host": "b"}},
+		{ID: "temperature", MType: model.Gauge, Value: &value},
+		{ID: "requests", MType: model.Counter, Delta: &delta, Labels: map[string]string{"host": "a"}},
+		{ID: "broken", MType: model.Gauge},
+		{ID: "requests", MType: model.Gauge, Value: &value},
+		{ID: "path", MType: model.Gauge, Value: &value, Labels: map[string]string{"dir": "c:\\tmp\n\"x\""}},
+	}
+
+	expected := "# TYPE path gauge\n" +
+		"path{dir=\"c:\\\\tmp\\n\\\"x\\\"\"} 1.5\n" +
+		"# TYPE requests counter\n" +
+		"requests{host=\"a\"} 3\n" +
+		"requests{host=\"b\"} 5\n" +
+		"# TYPE temperature gauge\n" +
+		"temperature 1.5\n"
+	require.Equal(t, expected, formatPrometheus(metrics))
+}
+
+func TestFormatPrometheusDistributions(t *testing.T) {
+	count := uint64(3)
+	sum := 1.5
+	metrics := []*model.Metrics{
+		{
+			ID: "latency", MType: model.Histogram, Count: &count, Sum: &sum, Labels: map[string]string{"host": "a"},
+			Buckets: []model.Bucket{{UpperBound: 0.5, Count: 1}, {UpperBound: 1, Count: 2}},
+		},
+		{
+			ID: "rpc", MType: model.Summary, Count: &count, Sum: &sum,
+			Quantiles: []model.Quantile{{Quantile: 0.5, Value: 0.4}},
+		},
+	}
+
+	expected := "# TYPE latency histogram\n" +
+		"latency_bucket{host=\"a\",le=\"0.5\"} 1\n" +
+		"latency_bucket{host=\"a\",le=\"1\"} 2\n" +
+		"latency_bucket{host=\"a\",le=\"+Inf\"} 3\n" +
+		"latency_sum{host=\"a\"} 1.5\n" +
+		"latency_count{host=\"a\"} 3\n" +
+		"# TYPE rpc summary\n" +
+		"rpc{quantile=\"0.5\"} 0.4\n" +
+		"rpc_sum 1.5\n" +
+		"rpc_count 3\n"
+	require.Equal(t, expected, formatPrometheus(metrics))
+}
+
+func TestUpdateHandlerCardinalityLimit(t *testing.T) {
+	handlers := NewHandlers(&service.MockMetricsService{Error: fmt.Errorf("%w: total series limit 1 reached", repository.ErrCardinalityLimit)})
+	router := setupTestRouter(handlers)
+
+	req := httptest.NewRequest(http.MethodPost, "/update/counter/test_counter/42", nil)
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusTooManyRequests, rr.Code, "Expected status code %d, got %d", http.StatusTooManyRequests, rr.Code)
+	require.Contains(t, rr.Body.String(), "total series limit 1 reached")
+}
+
+type staticMetricsService struct {
+	service.MockMetricsService
+	metrics []*model.Metrics
+}
+
+func (s *staticMetricsService) GetAllMetrics(matchers ...*model.LabelMatcher) ([]*model.Metrics, error) {
+	return s.metrics, nil
+}
+
+func (s *staticMetricsService) ForTenant(tenant string) service.Service {
+	return s
+}
+
+func TestGetAllMetricsHandlerDashboard(t *testing.T) {
+	delta := int64(7)
+	value := 2.5
+	handlers := NewHandlers(&staticMetricsService{metrics: []*model.Metrics{
+		{ID: "zeta", MType: model.Gauge, Value: &value},
+		{ID: "<script>alert(1)</script>", MType: model.Counter, Delta: &delta},
+		{ID: "alpha", MType: model.Gauge, Value: &value, Labels: map[string]string{"host": "a b"}},
+		{ID: "requests", MType: model.Counter, Delta: &delta},
+	}})
+	router := setupTestRouter(handlers)
+
+	req := httptest.NewRequest(http.MethodGet, "/?refresh=10&filter=req", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	body := rr.Body.String()
+	require.NotContains(t, body, "<script>alert(1)</script>", "Metric names must be escaped")
+	require.Contains(t, body, "&lt;script&gt;alert(1)&lt;/script&gt;")
+	require.Contains(t, body, `<meta http-equiv="refresh" content="10">`)
+	require.Contains(t, body, `value="req"`)
+	require.Contains(t, body, `href="/value/gauge/alpha?host=a&#43;b"`)
+
+	counters := strings.Index(body, "<h2>Counters</h2>")
+	gauges := strings.Index(body, "<h2>Gauges</h2>")
+	require.True(t, counters >= 0 && gauges > counters, "Counters section must precede gauges")
+	require.Less(t, strings.Index(body, ">alpha</a>"), strings.Index(body, ">zeta</a>"), "Rows must be sorted by name")
+	require.Less(t, gauges, strings.Index(body, ">alpha</a>"), "Gauges must be rendered in their own section")
+}
+
+func TestHealthHandlers(t *testing.T) {
+	tests := []struct {
+		name               string
+		path               string
+		serviceError       error
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "liveness",
+			path:               "/healthz",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"status":"ok"}`,
+		},
+		{
+			name:               "liveness ignores storage",
+			path:               "/healthz",
+			serviceError:       fmt.Errorf("storage is down"),
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"status":"ok"}`,
+		},
+		{
+			name:               "readiness",
+			path:               "/readyz",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"status":"ok"}`,
+		},
+		{
+			name:               "readiness with unavailable storage",
+			path:               "/readyz",
+			serviceError:       fmt.Errorf("storage is down"),
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedBody:       `{"status":"unavailable","reason":"storage is down"}`,
+		},
+		{
+			name:               "ping with unavailable storage",
+			path:               "/ping",
+			serviceError:       fmt.Errorf("storage is down"),
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedBody:       `{"status":"unavailable","reason":"storage is down"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handlers := NewHandlers(&service.MockMetricsService{Error: test.serviceError})
+			router := setupTestRouter(handlers)
+
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+			require.Equal(t, test.expectedStatusCode, rr.Code, "Expected status code %d, got %d", test.expectedStatusCode, rr.Code)
+			require.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			require.JSONEq(t, test.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestDeleteMetricHandler(t *testing.T) {
+	tests := []struct {
+		name               string
+		serviceError       error
+		expectedStatusCode int
+	}{
+		{name: "deleted", expectedStatusCode: http.StatusOK},
+		{name: "not found", serviceError: fmt.Errorf("%w: gauge:test", repository.ErrMetricNotFound), expectedStatusCode: http.StatusNotFound},
+		{name: "reserved", serviceError: fmt.Errorf("%w: reserved prefix", service.ErrInvalidMetricName), expectedStatusCode: http.StatusBadRequest},
+		{name: "storage error", serviceError: fmt.Errorf("storage is down"), expectedStatusCode: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handlers := NewHandlers(&service.MockMetricsService{Error: test.serviceError})
+			router := setupTestRouter(handlers)
+
+			req := httptest.NewRequest(http.MethodDelete, "/value/gauge/test?host=a", nil)
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+			require.Equal(t, test.expectedStatusCode, rr.Code, "Expected status code %d, got %d", test.expectedStatusCode, rr.Code)
+		})
+	}
+}
+
+func TestInfluxWriteHandler(t *testing.T) {
+	tests := []struct {
+		name               string
+		body               string
+		serviceError       error
+		expectedStatusCode int
+		expectedLines      []int
+	}{
+		{
+			name:               "all lines written",
+			body:               "cpu,host=a usage=0.5\nmem free=10i 1700000000000000000\n",
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "partial write",
+			body:               "cpu usage=0.5\ncpu usage=\nevent message=\"x\"\n",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedLines:      []int{2, 3},
+		},
+		{
+			name:               "rejected by limits",
+			body:               "cpu usage=0.5\n",
+			serviceError:       fmt.Errorf("%w: total series limit 1 reached", repository.ErrCardinalityLimit),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedLines:      []int{1},
+		},
+		{
+			name:               "storage error",
+			body:               "cpu usage=0.5\n",
+			serviceError:       fmt.Errorf("storage is down"),
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			influxHandler := NewInfluxHandler(&service.MockMetricsService{Error: test.serviceError}, influx.NewReceiver(influx.Rule{}))
+			router := chi.NewRouter()
+			router.Post(config.WritePath, influxHandler.WriteHandler)
+
+			req := httptest.NewRequest(http.MethodPost, config.WritePath, strings.NewReader(test.body))
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+			require.Equal(t, test.expectedStatusCode, rr.Code, "Expected status code %d, got %d", test.expectedStatusCode, rr.Code)
+			if test.expectedLines == nil {
+				return
+			}
+
+			var response influxWriteResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+			lines := make([]int, 0, len(response.Lines))
+			for _, lineError := range response.Lines {
+				lines = append(lines, lineError.Line)
+			}
+			require.Equal(t, test.expectedLines, lines)
+		})
+	}
+}
+
+func TestOTLPMetricsHandler(t *testing.T) {
+	const jsonBody = `{"resourceMetrics":[{"scopeMetrics":[{"metrics":[{"name":"temperature","gauge":{"dataPoints":[{"asDouble":21.5}]}},{"name":"latency","summary":{"dataPoints":[{}]}}]}]}]}`
+
+	gzipped := func(body string) string {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write([]byte(body))
+		gz.Close()
+		return buf.String()
+	}
+
+	tests := []struct {
+		name                string
+		contentType         string
+		contentEncoding     string
+		body                string
+		expectedStatusCode  int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "json with partial success",
+			contentType:         "application/json",
+			body:                jsonBody,
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        `{"partialSuccess":{"rejectedDataPoints":"1","errorMessage":"metric \"latency\": only gauge and sum are supported"}}`,
+		},
+		{
+			name:                "gzipped json",
+			contentType:         "application/json",
+			contentEncoding:     "gzip",
+			body:                gzipped(`{"resourceMetrics":[]}`),
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        `{}`,
+		},
+		{
+			name:                "empty protobuf",
+			contentType:         "application/x-protobuf",
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "application/x-protobuf",
+		},
+		{name: "invalid protobuf", contentType: "application/x-protobuf", body: "\x0a\xff", expectedStatusCode: http.StatusBadRequest},
+		{name: "invalid json", contentType: "application/json", body: "{", expectedStatusCode: http.StatusBadRequest},
+		{name: "unsupported content type", contentType: "text/plain", body: "x", expectedStatusCode: http.StatusUnsupportedMediaType},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			otlpHandler := NewOTLPHandler(&service.MockMetricsService{}, otlp.NewReceiver(nil))
+			router := chi.NewRouter()
+			router.Post(config.OTLPMetricsPath, otlpHandler.MetricsHandler)
+
+			req := httptest.NewRequest(http.MethodPost, config.OTLPMetricsPath, strings.NewReader(test.body))
+			req.Header.Set("Content-Type", test.contentType)
+			if test.contentEncoding != "" {
+				req.Header.Set("Content-Encoding", test.contentEncoding)
+			}
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+			require.Equal(t, test.expectedStatusCode, rr.Code, "Expected status code %d, got %d", test.expectedStatusCode, rr.Code)
+			if test.expectedContentType == "" {
+				return
+			}
+			require.Equal(t, test.expectedContentType, rr.Header().Get("Content-Type"))
+			if test.expectedContentType == "application/json" {
+				require.JSONEq(t, test.expectedBody, rr.Body.String())
+			} else {
+				require.Empty(t, rr.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestRemoteWriteHandler(t *testing.T) {
+	encodeSeries := func(labels ...string) []byte {
+		var ts []byte
+		for i := 0; i < len(labels); i += 2 {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, labels[i])
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, labels[i+1])
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, label)
+		}
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(1))
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, sample)
+		request := protowire.AppendTag(nil, 1, protowire.BytesType)
+		return snappy.Encode(nil, protowire.AppendBytes(request, ts))
+	}
+
+	tests := []struct {
+		name               string
+		contentEncoding    string
+		body               []byte
+		serviceError       error
+		expectedStatusCode int
+	}{
+		{name: "stored", contentEncoding: "snappy", body: encodeSeries("__name__", "up", "job", "node"), expectedStatusCode: http.StatusNoContent},
+		{name: "missing metric name", contentEncoding: "snappy", body: encodeSeries("job", "node"), expectedStatusCode: http.StatusBadRequest},
+		{name: "invalid snappy", contentEncoding: "snappy", body: []byte("plain"), expectedStatusCode: http.StatusBadRequest},
+		{name: "decoded size too large", contentEncoding: "snappy", body: append(protowire.AppendVarint(nil, 1<<32-1), 0x00), expectedStatusCode: http.StatusBadRequest},
+		{name: "unsupported encoding", contentEncoding: "gzip", body: []byte("x"), expectedStatusCode: http.StatusUnsupportedMediaType},
+		{name: "storage error", contentEncoding: "snappy", body: encodeSeries("__name__", "up"), serviceError: fmt.Errorf("storage is down"), expectedStatusCode: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			remoteWriteHandler := NewRemoteWriteHandler(&service.MockMetricsService{Error: test.serviceError}, remotewrite.NewReceiver())
+			router := chi.NewRouter()
+			router.Post(config.RemoteWritePath, remoteWriteHandler.WriteHandler)
+
+			req := httptest.NewRequest(http.MethodPost, config.RemoteWritePath, bytes.NewReader(test.body))
+			req.Header.Set("Content-Type", "application/x-protobuf")
+			req.Header.Set("Content-Encoding", test.contentEncoding)
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+			require.Equal(t, test.expectedStatusCode, rr.Code, "Expected status code %d, got %d", test.expectedStatusCode, rr.Code)
+		})
+	}
+}
+
+func TestHandlersTenantIsolation(t *testing.T) {
+	handlers := NewHandlers(service.NewMetricsService(repository.NewMemStorage()))
+	resolver, err := tenant.NewResolver(nil)
+	require.NoError(t, err)
+	router := resolver.Middleware(setupTestRouter(handlers))
+
+	serve := func(method, path, tenantName string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Accept", "application/json")
+		if tenantName != "" {
+			req.Header.Set(tenant.Header, tenantName)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/update/counter/requests/5", "team-a").Code)
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/update/counter/requests/1", "").Code)
+
+	rr := serve(http.MethodGet, "/value/counter/requests", "team-a")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "5", rr.Body.String())
+	require.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/value/counter/requests", "team-b").Code)
+
+	var metrics []model.Metrics
+	rr = serve(http.MethodGet, "/", "team-b")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &metrics))
+	require.Empty(t, metrics)
+
+	rr = serve(http.MethodGet, "/", "")
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &metrics))
+	require.Len(t, metrics, 1)
+	require.Equal(t, int64(1), *metrics[0].Delta)
+}
+
+type memoryAuditSink struct {
+	events []audit.Event
+}
+
+func (s *memoryAuditSink) Write(events []audit.Event) error {
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *memoryAuditSink) Close() error {
+	return nil
+}
+
+func TestHandlersAuditLog(t *testing.T) {
+	sink := &memoryAuditSink{}
+	auditLog := audit.NewLogger(sink, 10, nil)
+	handlers := NewHandlers(service.NewMetricsService(repository.NewMemStorage()), WithAuditLog(auditLog))
+	router := setupTestRouter(handlers)
+
+	serve := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.RemoteAddr = "10.0.0.7:5000"
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/update/counter/requests/5?host=a", ""))
+	require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/update/gauge/"+model.SelfMetricPrefix+"x/1", ""))
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/updates/", `[{"id":"temp","type":"gauge","value":1.5},{"id":"requests","type":"counter","delta":2,"labels":{"host":"a"}}]`))
+	require.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/value/gauge/missing", ""))
+	require.Equal(t, http.StatusOK, serve(http.MethodDelete, "/value/gauge/temp", ""))
+	require.NoError(t, auditLog.Close())
+
+	require.Len(t, sink.events, 3, "rejected updates and deletes are not audited")
+	require.Equal(t, "10.0.0.7", sink.events[0].ClientIP)
+	require.Equal(t, "/update/{metricType}/{metricName}/{metricValue}", sink.events[0].Handler)
+	require.Equal(t, []audit.Change{{ID: "requests", Type: model.Counter, Labels: map[string]string{"host": "a"}, Value: "5"}}, sink.events[0].Changes)
+	require.Equal(t, audit.ActionUpdate, sink.events[1].Action)
+	require.Equal(t, []audit.Change{
+		{ID: "temp", Type: model.Gauge, Value: "1.5"},
+		{ID: "requests", Type: model.Counter, Labels: map[string]string{"host": "a"}, Value: "2"},
+	}, sink.events[1].Changes)
+	require.Equal(t, audit.ActionDelete, sink.events[2].Action)
+	require.Equal(t, []audit.Change{{ID: "temp", Type: model.Gauge}}, sink.events[2].Changes)
+}
+
+func TestHandlersAuditLogNormalizedNames(t *testing.T) {
+	sink := &memoryAuditSink{}
+	auditLog := audit.NewLogger(sink, 10, nil)
+	policy, err := service.NewNamingPolicy(service.DefaultAllowedNameChars, service.DefaultMaxNameLength, nil, true)
+	require.NoError(t, err)
+	handlers := NewHandlers(service.NewMetricsService(repository.NewMemStorage(), service.WithNamingPolicy(policy)), WithAuditLog(auditLog))
+	router := setupTestRouter(handlers)
+
+	for _, method := range []string{http.MethodPost, http.MethodDelete} {
+		path := "/update/counter/http.requests/1?status-code=200"
+		if method == http.MethodDelete {
+			path = "/value/counter/http.requests?status-code=200"
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+	}
+	require.NoError(t, auditLog.Close())
+
+	require.Len(t, sink.events, 2)
+	expected := audit.Change{ID: "http_requests", Type: model.Counter, Labels: map[string]string{"status_code": "200"}}
+	require.Equal(t, expected.ID, sink.events[0].Changes[0].ID)
+	require.Equal(t, expected.Labels, sink.events[0].Changes[0].Labels)
+	require.Equal(t, []audit.Change{expected}, sink.events[1].Changes)
+}
+
+func TestAlertsHandler(t *testing.T) {
+	metricsService := service.NewMetricsService(repository.NewMemStorage())
+	rule := &alerting.Rule{Name: "HighHeap", Metric: "HeapAlloc", Op: ">", Threshold: 100}
+	require.NoError(t, rule.Validate())
+	engine := alerting.NewEngine(metricsService, []*alerting.Rule{rule}, alerting.NewWebhookNotifier(http.DefaultClient, nil))
+	require.NoError(t, metricsService.UpdateMetric(model.Gauge, "HeapAlloc", "150", nil))
+	engine.Evaluate(context.Background())
+
+	router := chi.NewRouter()
+	router.Get(config.AlertsPath, NewAlertsHandler(engine).ListHandler)
+	list := func(tenantName string) []alerting.Alert {
+		req := httptest.NewRequest(http.MethodGet, config.AlertsPath, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req.WithContext(tenant.NewContext(req.Context(), tenantName)))
+		require.Equal(t, http.StatusOK, rr.Code)
+		var alerts []alerting.Alert
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &alerts))
+		return alerts
+	}
+
+	require.Len(t, list(""), 1)
+	require.Empty(t, list("team-a"), "tenants must not see default tenant alerts")
+}
+
+func TestHistoryHandler(t *testing.T) {
+	history, err := tsdb.NewHistory(time.Hour, nil)
+	require.NoError(t, err)
+	storage := repository.NewHistoryStorage(repository.NewMemStorage(), history)
+	naming, err := service.NewNamingPolicy(service.DefaultAllowedNameChars, service.DefaultMaxNameLength, nil, true)
+	require.NoError(t, err)
+	router := setupTestRouter(NewHandlers(service.NewMetricsService(storage, service.WithNamingPolicy(naming))))
+	router.Get(config.HistoryPath+"/{metricType}/{metricName}", NewHistoryHandler(storage, naming).QueryHandler)
+
+	serve := func(method, path, tenantName string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req = req.WithContext(tenant.NewContext(req.Context(), tenantName))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/update/gauge/temp/20.5?room=a", "").Code)
+	time.Sleep(2 * time.Millisecond)
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/update/gauge/temp/21.5?room=a", "").Code)
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/update/gauge/temp/30?room=a", "team-a").Code)
+
+	rr := serve(http.MethodGet, "/history/gauge/temp?room=a", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var response historyResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.Equal(t, "temp", response.ID)
+	require.Equal(t, map[string]string{"room": "a"}, response.Labels)
+	require.Len(t, response.Points, 2)
+	require.Equal(t, 21.5, response.Points[1].Avg)
+	require.Equal(t, uint64(1), response.Points[1].Count)
+	require.Zero(t, response.Points[1].Resolution)
+
+	rr = serve(http.MethodGet, "/history/gauge/temp?room=a", "team-a")
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.Len(t, response.Points, 1, "tenants see only their own history")
+
+	to := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	rr = serve(http.MethodGet, "/history/gauge/temp?room=a&to="+to, "")
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.Empty(t, response.Points)
+
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/update/gauge/disk.used/10?mount.point=root", "").Code)
+	rr = serve(http.MethodGet, "/history/gauge/disk.used?mount.point=root", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var sanitized historyResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &sanitized))
+	require.Equal(t, "disk_used", sanitized.ID)
+	require.Equal(t, map[string]string{"mount_point": "root"}, sanitized.Labels)
+	require.Len(t, sanitized.Points, 1, "query names are sanitized like written names")
+
+	require.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/history/histogram/latency", "").Code)
+	require.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/history/gauge/temp?from=yesterday", "").Code)
+	require.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/history/gauge/temp?from=200&to=100", "").Code)
+}
+
+func TestTransferHandler(t *testing.T) {
+	source := repository.NewMemStorage()
+	sourceRouter := setupTestRouter(NewHandlers(service.NewMetricsService(source)))
+	sourceRouter.Get(config.AdminExportPath, NewTransferHandler(source, service.DefaultNamingPolicy()).ExportHandler)
+
+	// serve передает запрос с API-токеном; без токена запрос анонимный и ограничен
+	// арендатором tenantName, как при выключенной аутентификации.
+	serve := func(router http.Handler, method, path, body string, token *auth.Token, tenantName string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		ctx := tenant.NewContext(req.Context(), tenantName)
+		if token != nil {
+			ctx = tenant.NewContext(auth.NewContext(ctx, token), token.Tenant)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req.WithContext(ctx))
+		return rr
+	}
+	admin := &auth.Token{Name: "ops", Scopes: []string{auth.ScopeAdmin}}
+	teamA := &auth.Token{Name: "a", Tenant: "team-a"}
+
+	require.Equal(t, http.StatusOK, serve(sourceRouter, http.MethodPost, "/update/counter/requests/5", "", nil, "").Code)
+	require.Equal(t, http.StatusOK, serve(sourceRouter, http.MethodPost, "/update/counter/requests/7", "", teamA, "").Code)
+
+	rr := serve(sourceRouter, http.MethodGet, config.AdminExportPath+"?compress=gzip", "", admin, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "application/gzip", rr.Header().Get("Content-Type"))
+	require.Contains(t, rr.Header().Get("Content-Disposition"), ".jsonl.gz")
+	archive := rr.Body.String()
+
+	rr = serve(sourceRouter, http.MethodGet, config.AdminExportPath, "", teamA, "")
+	require.Equal(t, "{\"tenant\":\"team-a\",\"id\":\"requests\",\"type\":\"counter\",\"delta\":7}\n", rr.Body.String(), "tenant tokens export only their tenant")
+	rr = serve(sourceRouter, http.MethodGet, config.AdminExportPath, "", nil, "team-a")
+	require.Equal(t, "{\"tenant\":\"team-a\",\"id\":\"requests\",\"type\":\"counter\",\"delta\":7}\n", rr.Body.String(), "anonymous requests export only their tenant")
+	rr = serve(sourceRouter, http.MethodGet, config.AdminExportPath, "", nil, "")
+	require.Equal(t, "{\"id\":\"requests\",\"type\":\"counter\",\"delta\":5}\n", rr.Body.String())
+
+	sink := &memoryAuditSink{}
+	auditLog := audit.NewLogger(sink, 10, nil)
+	target := repository.NewMemStorage()
+	existing := int64(10)
+	require.NoError(t, target.UpdateMetric(&model.Metrics{ID: "requests", MType: model.Counter, Delta: &existing}))
+	targetRouter := chi.NewRouter()
+	targetRouter.Post(config.AdminImportPath, NewTransferHandler(target, service.DefaultNamingPolicy(), WithAuditLog(auditLog)).ImportHandler)
+
+	rr = serve(targetRouter, http.MethodPost, config.AdminImportPath, archive, admin, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"records":2,"skipped":0}`, rr.Body.String())
+	stored, err := target.GetMetric(&model.Metrics{ID: "requests", MType: model.Counter})
+	require.NoError(t, err)
+	require.Equal(t, int64(15), *stored.Delta, "merge adds counters")
+
+	rr = serve(targetRouter, http.MethodPost, config.AdminImportPath+"?mode=replace", archive, admin, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	stored, err = target.GetMetric(&model.Metrics{ID: "requests", MType: model.Counter})
+	require.NoError(t, err)
+	require.Equal(t, int64(5), *stored.Delta, "replace overwrites counters")
+	stored, err = target.GetMetric(&model.Metrics{ID: "requests", MType: model.Counter, Tenant: "team-a"})
+	require.NoError(t, err)
+	require.Equal(t, int64(7), *stored.Delta)
+
+	require.Equal(t, http.StatusBadRequest, serve(targetRouter, http.MethodPost, config.AdminImportPath+"?mode=overwrite", archive, admin, "").Code)
+	require.Equal(t, http.StatusBadRequest, serve(targetRouter, http.MethodPost, config.AdminImportPath, `{"id":"requests"`, admin, "").Code)
+	require.Equal(t, http.StatusForbidden, serve(targetRouter, http.MethodPost, config.AdminImportPath, archive, teamA, "").Code)
+	require.Equal(t, http.StatusForbidden, serve(targetRouter, http.MethodPost, config.AdminImportPath, archive, nil, "team-a").Code)
+	require.Equal(t, http.StatusForbidden, serve(targetRouter, http.MethodPost, config.AdminImportPath, archive, nil, "").Code,
+		"anonymous requests must not import into other tenants")
+
+	require.NoError(t, auditLog.Close())
+	require.Len(t, sink.events, 4, "one event per tenant and import")
+	require.Equal(t, audit.ActionImport, sink.events[0].Action)
+	require.Equal(t, "", sink.events[0].Tenant)
+	require.Equal(t, []audit.Change{{ID: "requests", Type: model.Counter, Value: "5"}}, sink.events[0].Changes)
+	require.Equal(t, "team-a", sink.events[1].Tenant)
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout ограничивает время проверки хранилища, чтобы оркестратор получил ответ
+// раньше, чем истечет его собственный таймаут.
+const readinessTimeout = 2 * time.Second
+
+type healthStatus struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// LivenessHandler сообщает, что процесс жив и обрабатывает запросы. Хранилище не проверяется.
+func (h *Handlers) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+// ReadinessHandler проверяет, что хранилище доступно и сервер готов принимать метрики.
+func (h *Handlers) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	if h.service == nil {
+		writeJSON(w, http.StatusServiceUnavailable, healthStatus{Status: "unavailable", Reason: "service is not initialized"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	if err := h.service.Ping(ctx); err != nil {
+		fmt.Printf("Readiness check failed: %v\n", err)
+		writeJSON(w, http.StatusServiceUnavailable, healthStatus{Status: "unavailable", Reason: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, healthStatus{Status: "ok"})
+}
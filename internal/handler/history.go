@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/repository"
+	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/prbllm/go-metrics/internal/tenant"
+)
+
+// defaultHistoryRange - диапазон запроса истории, если from не задан.
+const defaultHistoryRange = time.Hour
+
+type HistoryHandler struct {
+	history *repository.HistoryStorage
+	naming  *service.NamingPolicy
+	now     func() time.Time
+}
+
+// NewHistoryHandler создает обработчик. Имя и метки из запроса приводятся по naming
+// так же, как при записи, иначе история ряда, имя которого исправлено при записи,
+// не нашлась бы.
+func NewHistoryHandler(history *repository.HistoryStorage, naming *service.NamingPolicy) *HistoryHandler {
+	return &HistoryHandler{history: history, naming: naming, now: time.Now}
+}
+
+type historyPoint struct {
+	Time time.Time `json:"time"`
+	// Resolution - длина интервала агрегата в секундах, 0 для исходных значений.
+	Resolution int64   `json:"resolution"`
+	Min        float64 `json:"min"`
+	Max        float64 `json:"max"`
+	Avg        float64 `json:"avg"`
+	Count      uint64  `json:"count"`
+}
+
+type historyResponse struct {
+	ID     string            `json:"id"`
+	MType  string            `json:"type"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Points []historyPoint    `json:"points"`
+}
+
+// QueryHandler возвращает историю ряда за ?from=...&to=... (RFC 3339 или Unix-время
+// в секундах). Остальные параметры запроса - метки ряда.
+func (h *HistoryHandler) QueryHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("method=%s uri=%s\n", r.Method, r.RequestURI)
+	metricType := chi.URLParam(r, "metricType")
+	metricName := chi.URLParam(r, "metricName")
+	if metricType != model.Counter && metricType != model.Gauge {
+		http.Error(w, "History is kept only for counters and gauges", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	to, err := parseHistoryTime(query.Get("to"), h.now())
+	if err != nil {
+		http.Error(w, "Invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := parseHistoryTime(query.Get("from"), to.Add(-defaultHistoryRange))
+	if err != nil {
+		http.Error(w, "Invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if from.After(to) {
+		http.Error(w, "from is after to", http.StatusBadRequest)
+		return
+	}
+	query.Del("from")
+	query.Del("to")
+
+	name, err := h.naming.NormalizeName(metricName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	labels, err := h.naming.NormalizeLabels(metricType, labelsFromQuery(query))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	metric := &model.Metrics{ID: name, MType: metricType, Labels: labels, Tenant: tenant.FromContext(r.Context())}
+	points, err := h.history.History(metric, from, to)
+	if err != nil {
+		fmt.Printf("Error querying history: %v\n", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := historyResponse{ID: metric.ID, MType: metric.MType, Labels: metric.Labels, Points: make([]historyPoint, 0, len(points))}
+	for _, p := range points {
+		response.Points = append(response.Points, historyPoint{
+			Time:       time.UnixMilli(p.T).UTC(),
+			Resolution: int64(p.Resolution.Seconds()),
+			Min:        p.Min,
+			Max:        p.Max,
+			Avg:        p.Avg(),
+			Count:      p.Count,
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func parseHistoryTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
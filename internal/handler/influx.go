@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/prbllm/go-metrics/internal/influx"
+	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/prbllm/go-metrics/internal/tenant"
+)
+
+// maxInfluxBodySize ограничивает размер одного запроса /write.
+const maxInfluxBodySize = 10 << 20
+
+type InfluxHandler struct {
+	requestScope
+	receiver *influx.Receiver
+}
+
+func NewInfluxHandler(service service.Service, receiver *influx.Receiver, opts ...Option) *InfluxHandler {
+	return &InfluxHandler{requestScope: newRequestScope(service, opts), receiver: receiver}
+}
+
+type influxWriteResponse struct {
+	Error   string              `json:"error"`
+	Written int                 `json:"written"`
+	Lines   []*influx.LineError `json:"lines"`
+}
+
+// WriteHandler принимает данные в формате InfluxDB line protocol. Корректные строки
+// сохраняются, даже если в запросе есть ошибочные: в этом случае возвращается 400
+// со списком ошибок по строкам. 500 возвращается, только если ни одна строка не
+// записана, поэтому повтор запроса клиентом не записывает строки дважды.
+func (h *InfluxHandler) WriteHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("method=%s uri=%s\n", r.Method, r.RequestURI)
+	if r.Method != http.MethodPost {
+		fmt.Printf("Method %s not allowed\n", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	points, lines, lineErrors, err := influx.Parse(http.MaxBytesReader(w, r.Body, maxInfluxBodySize))
+	if err != nil {
+		fmt.Printf("Error reading line protocol: %v\n", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tenantService, done := h.writeService(r)
+	defer done()
+	result, err := h.receiver.Write(tenantService, tenant.FromContext(r.Context()), points, lines)
+	if err != nil {
+		fmt.Printf("Error saving line protocol: %v\n", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	written := result.Written
+	lineErrors = append(lineErrors, result.Lines...)
+
+	if len(lineErrors) > 0 {
+		sort.Slice(lineErrors, func(i, j int) bool { return lineErrors[i].Line < lineErrors[j].Line })
+		fmt.Printf("Partial write: %d lines written, %d rejected\n", written, len(lineErrors))
+		writeJSON(w, http.StatusBadRequest, influxWriteResponse{
+			Error:   fmt.Sprintf("partial write: %d lines rejected", len(lineErrors)),
+			Written: written,
+			Lines:   lineErrors,
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
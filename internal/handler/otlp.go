@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/prbllm/go-metrics/internal/otlp"
+	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/prbllm/go-metrics/internal/tenant"
+)
+
+// maxOTLPBodySize ограничивает размер одного запроса OTLP после распаковки.
+const maxOTLPBodySize = 10 << 20
+
+const (
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+)
+
+type OTLPHandler struct {
+	requestScope
+	receiver *otlp.Receiver
+}
+
+func NewOTLPHandler(service service.Service, receiver *otlp.Receiver, opts ...Option) *OTLPHandler {
+	return &OTLPHandler{requestScope: newRequestScope(service, opts), receiver: receiver}
+}
+
+// MetricsHandler принимает ExportMetricsServiceRequest по OTLP/HTTP в protobuf или JSON.
+// Ответ кодируется так же, как запрос.
+func (h *OTLPHandler) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("method=%s uri=%s\n", r.Method, r.RequestURI)
+	if r.Method != http.MethodPost {
+		fmt.Printf("Method %s not allowed\n", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != contentTypeProtobuf && contentType != contentTypeJSON {
+		fmt.Printf("Unsupported content type: %s\n", contentType)
+		http.Error(w, "Unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var body io.Reader = r.Body
+	switch r.Header.Get("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			fmt.Printf("Error reading gzip body: %v\n", err)
+			http.Error(w, "Invalid gzip body", http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		fmt.Printf("Unsupported content encoding: %s\n", r.Header.Get("Content-Encoding"))
+		http.Error(w, "Unsupported content encoding", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxOTLPBodySize+1))
+	if err != nil {
+		fmt.Printf("Error reading request body: %v\n", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxOTLPBodySize {
+		fmt.Printf("Request body exceeds %d bytes\n", maxOTLPBodySize)
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var request *otlp.ExportRequest
+	if contentType == contentTypeJSON {
+		request, err = otlp.UnmarshalJSON(data)
+	} else {
+		request, err = otlp.UnmarshalProto(data)
+	}
+	if err != nil {
+		fmt.Printf("Error decoding OTLP request: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantService, done := h.writeService(r)
+	defer done()
+	response, err := h.receiver.Export(tenantService, tenant.FromContext(r.Context()), request)
+	if err != nil {
+		fmt.Printf("Error saving OTLP metrics: %v\n", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if response.PartialSuccess != nil {
+		fmt.Printf("OTLP partial success: %d data points rejected: %s\n", response.PartialSuccess.RejectedDataPoints, response.PartialSuccess.ErrorMessage)
+	}
+
+	if contentType == contentTypeJSON {
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeProtobuf)
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.MarshalProto())
+}
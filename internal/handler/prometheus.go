@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prbllm/go-metrics/internal/model"
+)
+
+// GetPrometheusMetricsHandler отдает метрики в текстовом формате экспозиции Prometheus.
+func (h *Handlers) GetPrometheusMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("method=%s uri=%s\n", r.Method, r.RequestURI)
+	if r.Method != http.MethodGet {
+		fmt.Printf("Method %s not allowed\n", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	matchers, err := matchersFromQuery(r.URL.Query())
+	if err != nil {
+		fmt.Printf("Invalid label matcher: %v\n", err)
+		http.Error(w, "Invalid label matcher", http.StatusBadRequest)
+		return
+	}
+
+	metrics, err := h.tenantService(r).GetAllMetrics(matchers...)
+	if err != nil {
+		fmt.Printf("Error getting metrics: %v\n", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(formatPrometheus(metrics)))
+}
+
+// formatPrometheus пишет ряды семействами. Имя семейства в Prometheus задает и тип,
+// поэтому если под одним именем хранятся ряды разных типов, выдается только первый
+// тип по алфавиту, а остальные пропускаются с записью в лог.
+func formatPrometheus(metrics []*model.Metrics) string {
+	sorted := make([]*model.Metrics, len(metrics))
+	copy(sorted, metrics)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].ID != sorted[j].ID {
+			return sorted[i].ID < sorted[j].ID
+		}
+		if sorted[i].MType != sorted[j].MType {
+			return sorted[i].MType < sorted[j].MType
+		}
+		return sorted[i].FullID() < sorted[j].FullID()
+	})
+
+	var sb strings.Builder
+	familyType := make(map[string]string)
+	skipped := make(map[string]bool)
+	for _, metric := range sorted {
+		value, ok := prometheusValue(metric)
+		if !ok {
+			continue
+		}
+		mtype, exists := familyType[metric.ID]
+		if !exists {
+			fmt.Fprintf(&sb, "# TYPE %s %s\n", metric.ID, metric.MType)
+			familyType[metric.ID] = metric.MType
+		} else if mtype != metric.MType {
+			if family := metric.ID + " " + metric.MType; !skipped[family] {
+				fmt.Printf("Skipping %s %s in Prometheus output: family is already exposed as %s\n", metric.MType, metric.ID, mtype)
+				skipped[family] = true
+			}
+			continue
+		}
+		sb.WriteString(value)
+	}
+	return sb.String()
+}
+
+func withLabel(labels map[string]string, name, value string) map[string]string {
+	result := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		result[k] = v
+	}
+	result[name] = value
+	return result
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// prometheusValue возвращает строки сэмплов метрики. Гистограмма и сводка
+// раскладываются на _bucket/quantile, _sum и _count, как это делает клиент Prometheus.
+func prometheusValue(metric *model.Metrics) (string, bool) {
+	switch {
+	case metric.MType == model.Counter && metric.Delta != nil:
+		return fmt.Sprintf("%s %d\n", metric.FullID(), *metric.Delta), true
+	case metric.MType == model.Gauge && metric.Value != nil:
+		return fmt.Sprintf("%s %s\n", metric.FullID(), formatFloat(*metric.Value)), true
+	case metric.MType == model.Histogram && metric.Count != nil && metric.Sum != nil:
+		var sb strings.Builder
+		for _, bucket := range metric.Buckets {
+			labels := model.FormatLabels(withLabel(metric.Labels, "le", formatFloat(bucket.UpperBound)))
+			fmt.Fprintf(&sb, "%s_bucket%s %d\n", metric.ID, labels, bucket.Count)
+		}
+		fmt.Fprintf(&sb, "%s_bucket%s %d\n", metric.ID, model.FormatLabels(withLabel(metric.Labels, "le", "+Inf")), *metric.Count)
+		writeSumAndCount(&sb, metric)
+		return sb.String(), true
+	case metric.MType == model.Summary && metric.Count != nil && metric.Sum != nil:
+		var sb strings.Builder
+		for _, quantile := range metric.Quantiles {
+			labels := model.FormatLabels(withLabel(metric.Labels, "quantile", formatFloat(quantile.Quantile)))
+			fmt.Fprintf(&sb, "%s%s %s\n", metric.ID, labels, formatFloat(quantile.Value))
+		}
+		writeSumAndCount(&sb, metric)
+		return sb.String(), true
+	}
+	return "", false
+}
+
+func writeSumAndCount(sb *strings.Builder, metric *model.Metrics) {
+	labels := model.FormatLabels(metric.Labels)
+	fmt.Fprintf(sb, "%s_sum%s %s\n", metric.ID, labels, formatFloat(*metric.Sum))
+	fmt.Fprintf(sb, "%s_count%s %d\n", metric.ID, labels, *metric.Count)
+}
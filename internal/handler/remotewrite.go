@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/prbllm/go-metrics/internal/remotewrite"
+	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/prbllm/go-metrics/internal/tenant"
+)
+
+// maxRemoteWriteBodySize ограничивает размер сжатого запроса remote_write.
+const maxRemoteWriteBodySize = 10 << 20
+
+type RemoteWriteHandler struct {
+	requestScope
+	receiver *remotewrite.Receiver
+}
+
+func NewRemoteWriteHandler(service service.Service, receiver *remotewrite.Receiver, opts ...Option) *RemoteWriteHandler {
+	return &RemoteWriteHandler{requestScope: newRequestScope(service, opts), receiver: receiver}
+}
+
+// WriteHandler принимает Prometheus remote_write. Prometheus повторяет запрос только
+// при ответе 5xx, поэтому ошибки в данных возвращаются как 400, а корректные ряды
+// из того же запроса все равно сохраняются.
+func (h *RemoteWriteHandler) WriteHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("method=%s uri=%s\n", r.Method, r.RequestURI)
+	if r.Method != http.MethodPost {
+		fmt.Printf("Method %s not allowed\n", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if encoding := r.Header.Get("Content-Encoding"); encoding != "" && encoding != "snappy" {
+		fmt.Printf("Unsupported content encoding: %s\n", encoding)
+		http.Error(w, "Unsupported content encoding", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRemoteWriteBodySize))
+	if err != nil {
+		fmt.Printf("Error reading request body: %v\n", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	request, err := remotewrite.Decode(data)
+	if err != nil {
+		fmt.Printf("Error decoding remote write request: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantService, done := h.writeService(r)
+	defer done()
+	result, err := h.receiver.Write(tenantService, tenant.FromContext(r.Context()), request)
+	if err != nil {
+		fmt.Printf("Error saving remote write samples: %v\n", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if result.Rejected > 0 {
+		fmt.Printf("Remote write: %d series stored, %d rejected: %v\n", result.Stored, result.Rejected, result.LastError)
+		http.Error(w, fmt.Sprintf("%d series rejected, last error: %v", result.Rejected, result.LastError), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/audit"
+	"github.com/prbllm/go-metrics/internal/auth"
+	"github.com/prbllm/go-metrics/internal/repository"
+	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/prbllm/go-metrics/internal/tenant"
+	"github.com/prbllm/go-metrics/internal/transfer"
+)
+
+// TransferHandler выгружает и загружает все ряды хранилища. Всеми арендаторами
+// управляет только API-токен без арендатора; остальные запросы, в том числе без
+// аутентификации, работают только с рядами арендатора запроса.
+type TransferHandler struct {
+	storage  repository.MetricsRepository
+	naming   *service.NamingPolicy
+	auditLog *audit.Logger
+}
+
+// NewTransferHandler создает обработчик. Имена и метки загружаемых рядов приводятся
+// по naming, как при записи через остальные API.
+func NewTransferHandler(storage repository.MetricsRepository, naming *service.NamingPolicy, opts ...Option) *TransferHandler {
+	scope := newRequestScope(nil, opts)
+	return &TransferHandler{storage: storage, naming: naming, auditLog: scope.auditLog}
+}
+
+// scopedTenant возвращает арендатора, которым ограничен запрос. Без ограничения
+// работает только API-токен, не привязанный к арендатору: маршруты переноса
+// требуют права admin, поэтому такой токен - администратор сервера.
+func scopedTenant(r *http.Request) (string, bool) {
+	if token := auth.FromContext(r.Context()); token != nil && token.Tenant == "" {
+		return "", false
+	}
+	return tenant.FromContext(r.Context()), true
+}
+
+// ExportHandler отдает ряды в JSON Lines, а с ?compress=gzip - файлом .jsonl.gz.
+func (h *TransferHandler) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("method=%s uri=%s\n", r.Method, r.RequestURI)
+	compress := r.URL.Query().Get("compress")
+	if compress != "" && compress != "gzip" {
+		http.Error(w, "Unsupported compression, expected gzip", http.StatusBadRequest)
+		return
+	}
+
+	var keep func(string) bool
+	if scoped, ok := scopedTenant(r); ok {
+		keep = func(tenantName string) bool { return tenantName == scoped }
+	}
+
+	filename := "metrics-" + time.Now().UTC().Format("20060102T150405Z") + ".jsonl"
+	var out io.Writer = w
+	if compress == "gzip" {
+		filename += ".gz"
+		w.Header().Set("Content-Type", "application/gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	// Ответ уже начат, поэтому ошибка записи только логируется.
+	result, err := transfer.Export(out, h.storage, keep)
+	if err != nil {
+		fmt.Printf("Error exporting metrics: %v\n", err)
+		return
+	}
+	fmt.Printf("Exported %d series, skipped %d\n", result.Records, result.Skipped)
+}
+
+// ImportHandler загружает выгрузку из тела запроса (JSON Lines, возможно сжатые gzip).
+// ?mode=merge (по умолчанию) складывает counter, гистограммы и сводки с существующими
+// рядами, ?mode=replace заменяет их.
+func (h *TransferHandler) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("method=%s uri=%s\n", r.Method, r.RequestURI)
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = transfer.ModeMerge
+	}
+	if err := transfer.ValidateMode(mode); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records, result, err := transfer.Read(r.Body, h.naming)
+	if err != nil {
+		fmt.Printf("Invalid import: %v\n", err)
+		http.Error(w, "Invalid import: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if scoped, ok := scopedTenant(r); ok {
+		for _, record := range records {
+			if record.Tenant != scoped {
+				http.Error(w, fmt.Sprintf("Request is limited to tenant %q, import contains tenant %q", scoped, record.Tenant), http.StatusForbidden)
+				return
+			}
+		}
+	}
+
+	applied, err := transfer.Apply(h.storage, records, mode)
+	if h.auditLog != nil {
+		h.audit(r, records[:applied])
+	}
+	if err != nil {
+		fmt.Printf("Error importing metrics: %v\n", err)
+		writeUpdateError(w, err)
+		return
+	}
+	fmt.Printf("Imported %d series in %s mode, skipped %d\n", result.Records, mode, result.Skipped)
+	writeJSON(w, http.StatusOK, result)
+}
+
+// audit записывает загруженные ряды одним событием на арендатора.
+func (h *TransferHandler) audit(r *http.Request, records []transfer.Record) {
+	byTenant := make(map[string][]audit.Change)
+	var tenants []string
+	for _, record := range records {
+		if _, ok := byTenant[record.Tenant]; !ok {
+			tenants = append(tenants, record.Tenant)
+		}
+		byTenant[record.Tenant] = append(byTenant[record.Tenant], audit.NewChange(record.Metrics))
+	}
+	source := audit.NewSource(r)
+	for _, tenantName := range tenants {
+		source.Tenant = tenantName
+		h.auditLog.Record(audit.Event{Source: source, Action: audit.ActionImport, Changes: byTenant[tenantName]})
+	}
+}
@@ -0,0 +1,86 @@
+package influx
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+
+	"github.com/prbllm/go-metrics/internal/model"
+)
+
+// Rule определяет, как поля точки превращаются в метрики.
+// Имя метрики - measurement_field, поле "value" дает просто measurement; теги становятся метками.
+// Дробные и логические поля сохраняются как gauge, строковые поля пропускаются.
+// Целые поля сохраняются как counter, если имя метрики соответствует CounterPattern,
+// иначе как gauge. Delta counter содержит накопленное значение поля, прирост
+// вычисляет Receiver.
+type Rule struct {
+	CounterPattern *regexp.Regexp
+}
+
+func (r Rule) ToMetrics(point Point) ([]*model.Metrics, error) {
+	names := make([]string, 0, len(point.Fields))
+	for name := range point.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	metrics := make([]*model.Metrics, 0, len(names))
+	for _, name := range names {
+		field := point.Fields[name]
+		id := point.Measurement + "_" + name
+		if name == "value" {
+			id = point.Measurement
+		}
+		metric := &model.Metrics{ID: id, Labels: point.Tags}
+
+		switch field.Type {
+		case FieldFloat:
+			r.setGauge(metric, field.Float)
+		case FieldBool:
+			value := 0.0
+			if field.Bool {
+				value = 1
+			}
+			r.setGauge(metric, value)
+		case FieldInteger:
+			if r.isCounter(id) {
+				r.setCounter(metric, field.Int)
+			} else {
+				r.setGauge(metric, float64(field.Int))
+			}
+		case FieldUnsigned:
+			if r.isCounter(id) {
+				if field.Uint > math.MaxInt64 {
+					return nil, fmt.Errorf("field %q: value %d overflows counter", name, field.Uint)
+				}
+				r.setCounter(metric, int64(field.Uint))
+			} else {
+				r.setGauge(metric, float64(field.Uint))
+			}
+		case FieldString:
+			continue
+		}
+		metrics = append(metrics, metric)
+	}
+
+	if len(metrics) == 0 {
+		return nil, fmt.Errorf("no numeric fields")
+	}
+	return metrics, nil
+}
+
+func (r Rule) isCounter(id string) bool {
+	return r.CounterPattern != nil && r.CounterPattern.MatchString(id)
+}
+
+func (r Rule) setGauge(metric *model.Metrics, value float64) {
+	metric.MType = model.Gauge
+	metric.Value = &value
+}
+
+func (r Rule) setCounter(metric *model.Metrics, delta int64) {
+	metric.MType = model.Counter
+	metric.Delta = &delta
+}
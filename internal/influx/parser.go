@@ -0,0 +1,272 @@
+// Package influx разбирает текстовый протокол InfluxDB (line protocol):
+//
+//	measurement[,tag=value...] field=value[,field=value...] [timestamp]
+package influx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	FieldFloat = iota
+	FieldInteger
+	FieldUnsigned
+	FieldBool
+	FieldString
+)
+
+type Field struct {
+	Type   int
+	Float  float64
+	Int    int64
+	Uint   uint64
+	Bool   bool
+	String string
+}
+
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]Field
+	Timestamp   *int64
+}
+
+// LineError описывает ошибку в конкретной строке входных данных. Номера строк начинаются с 1.
+type LineError struct {
+	Line int    `json:"line"`
+	Text string `json:"text,omitempty"`
+	Err  string `json:"error"`
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+// Parse разбирает все строки из r. Ошибочные строки не прерывают разбор: они возвращаются
+// в списке ошибок, а корректные точки - в списке точек вместе с номерами их строк.
+func Parse(r io.Reader) ([]Point, []int, []*LineError, error) {
+	var points []Point
+	var lines []int
+	var lineErrors []*LineError
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		point, err := ParseLine(line)
+		if err != nil {
+			lineErrors = append(lineErrors, &LineError{Line: lineNumber, Text: line, Err: err.Error()})
+			continue
+		}
+		points = append(points, point)
+		lines = append(lines, lineNumber)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+	return points, lines, lineErrors, nil
+}
+
+func ParseLine(line string) (Point, error) {
+	key, rest, err := splitUnescaped(line, ' ', false)
+	if err != nil {
+		return Point{}, err
+	}
+	if rest == "" {
+		return Point{}, fmt.Errorf("missing fields")
+	}
+
+	point := Point{Fields: make(map[string]Field)}
+	if err := parseKey(key, &point); err != nil {
+		return Point{}, err
+	}
+
+	fieldsPart, timestampPart, err := splitUnescaped(rest, ' ', true)
+	if err != nil {
+		return Point{}, err
+	}
+	if err := parseFields(fieldsPart, &point); err != nil {
+		return Point{}, err
+	}
+
+	timestampPart = strings.TrimSpace(timestampPart)
+	if timestampPart != "" {
+		ts, err := strconv.ParseInt(timestampPart, 10, 64)
+		if err != nil {
+			return Point{}, fmt.Errorf("invalid timestamp %q", timestampPart)
+		}
+		point.Timestamp = &ts
+	}
+	return point, nil
+}
+
+func parseKey(key string, point *Point) error {
+	parts := splitAllUnescaped(key, ',')
+	point.Measurement = unescape(parts[0])
+	if point.Measurement == "" {
+		return fmt.Errorf("missing measurement")
+	}
+	for _, part := range parts[1:] {
+		name, value, ok := cutUnescaped(part, '=')
+		if !ok {
+			return fmt.Errorf("invalid tag %q, expected key=value", part)
+		}
+		name, value = unescape(name), unescape(value)
+		if name == "" || value == "" {
+			return fmt.Errorf("invalid tag %q, key and value cannot be empty", part)
+		}
+		if point.Tags == nil {
+			point.Tags = make(map[string]string)
+		}
+		point.Tags[name] = value
+	}
+	return nil
+}
+
+func parseFields(fields string, point *Point) error {
+	for _, part := range splitFields(fields) {
+		name, raw, ok := cutUnescaped(part, '=')
+		if !ok {
+			return fmt.Errorf("invalid field %q, expected key=value", part)
+		}
+		name = unescape(name)
+		if name == "" {
+			return fmt.Errorf("invalid field %q, key cannot be empty", part)
+		}
+		field, err := parseFieldValue(raw)
+		if err != nil {
+			return fmt.Errorf("field %q: %w", name, err)
+		}
+		point.Fields[name] = field
+	}
+	if len(point.Fields) == 0 {
+		return fmt.Errorf("missing fields")
+	}
+	return nil
+}
+
+func parseFieldValue(raw string) (Field, error) {
+	switch {
+	case raw == "":
+		return Field{}, fmt.Errorf("missing value")
+	case strings.HasPrefix(raw, `"`):
+		if len(raw) < 2 || !strings.HasSuffix(raw, `"`) {
+			return Field{}, fmt.Errorf("unterminated string value")
+		}
+		value := strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(raw[1 : len(raw)-1])
+		return Field{Type: FieldString, String: value}, nil
+	case strings.HasSuffix(raw, "i"):
+		value, err := strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return Field{}, fmt.Errorf("invalid integer value %q", raw)
+		}
+		return Field{Type: FieldInteger, Int: value}, nil
+	case strings.HasSuffix(raw, "u"):
+		value, err := strconv.ParseUint(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return Field{}, fmt.Errorf("invalid unsigned value %q", raw)
+		}
+		return Field{Type: FieldUnsigned, Uint: value}, nil
+	}
+
+	switch raw {
+	case "t", "T", "true", "True", "TRUE":
+		return Field{Type: FieldBool, Bool: true}, nil
+	case "f", "F", "false", "False", "FALSE":
+		return Field{Type: FieldBool, Bool: false}, nil
+	}
+
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return Field{}, fmt.Errorf("invalid float value %q", raw)
+	}
+	return Field{Type: FieldFloat, Float: value}, nil
+}
+
+// splitUnescaped делит строку по первому неэкранированному разделителю вне кавычек.
+func splitUnescaped(s string, sep byte, quotes bool) (string, string, error) {
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quotes && s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == sep && !inQuotes:
+			return s[:i], s[i+1:], nil
+		}
+	}
+	if inQuotes {
+		return "", "", fmt.Errorf("unterminated string value")
+	}
+	return s, "", nil
+}
+
+func cutUnescaped(s string, sep byte) (string, string, bool) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+func splitAllUnescaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// splitFields делит набор полей по запятым, не учитывая запятые внутри строковых значений.
+func splitFields(s string) []string {
+	var parts []string
+	start := 0
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == ',' && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(` ,="\`, s[i+1]) >= 0 {
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,117 @@
+package influx
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name        string
+		line        string
+		measurement string
+		tags        map[string]string
+		fields      map[string]Field
+		timestamp   *int64
+		wantErr     bool
+	}{
+		{
+			name:        "float field",
+			line:        "cpu usage=0.5",
+			measurement: "cpu",
+			fields:      map[string]Field{"usage": {Type: FieldFloat, Float: 0.5}},
+		},
+		{
+			name:        "tags and timestamp",
+			line:        "cpu,host=a,region=eu usage=1 1700000000000000000",
+			measurement: "cpu",
+			tags:        map[string]string{"host": "a", "region": "eu"},
+			fields:      map[string]Field{"usage": {Type: FieldFloat, Float: 1}},
+			timestamp:   func() *int64 { v := int64(1700000000000000000); return &v }(),
+		},
+		{
+			name:        "typed fields",
+			line:        `app requests=10i,bytes=20u,up=true,version="1.0 beta"`,
+			measurement: "app",
+			fields: map[string]Field{
+				"requests": {Type: FieldInteger, Int: 10},
+				"bytes":    {Type: FieldUnsigned, Uint: 20},
+				"up":       {Type: FieldBool, Bool: true},
+				"version":  {Type: FieldString, String: "1.0 beta"},
+			},
+		},
+		{
+			name:        "escaped characters",
+			line:        `disk\ io,path=/var\,log free=1`,
+			measurement: "disk io",
+			tags:        map[string]string{"path": "/var,log"},
+			fields:      map[string]Field{"free": {Type: FieldFloat, Float: 1}},
+		},
+		{name: "missing fields", line: "cpu", wantErr: true},
+		{name: "invalid field value", line: "cpu usage=abc", wantErr: true},
+		{name: "invalid timestamp", line: "cpu usage=1 now", wantErr: true},
+		{name: "unterminated string", line: `cpu name="abc`, wantErr: true},
+		{name: "empty tag value", line: "cpu,host= usage=1", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			point, err := ParseLine(test.line)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.measurement, point.Measurement)
+			if test.tags != nil {
+				require.Equal(t, test.tags, point.Tags)
+			} else {
+				require.Empty(t, point.Tags)
+			}
+			require.Equal(t, test.fields, point.Fields)
+			require.Equal(t, test.timestamp, point.Timestamp)
+		})
+	}
+}
+
+func TestParseReportsLineErrors(t *testing.T) {
+	body := "# comment\ncpu usage=1\n\ncpu usage=\nmem free=2i\n"
+	points, lines, lineErrors, err := Parse(strings.NewReader(body))
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	require.Equal(t, []int{2, 5}, lines)
+	require.Len(t, lineErrors, 1)
+	require.Equal(t, 4, lineErrors[0].Line)
+	require.Equal(t, "cpu usage=", lineErrors[0].Text)
+}
+
+func TestRuleToMetrics(t *testing.T) {
+	point, err := ParseLine(`http,host=a requests_total=5i,latency=0.25,value=3i,path="/"`)
+	require.NoError(t, err)
+
+	rule := Rule{CounterPattern: regexp.MustCompile(`_total$`)}
+	metrics, err := rule.ToMetrics(point)
+	require.NoError(t, err)
+	require.Len(t, metrics, 3)
+
+	byID := map[string]*model.Metrics{}
+	for _, metric := range metrics {
+		require.Equal(t, map[string]string{"host": "a"}, metric.Labels)
+		byID[metric.ID] = metric
+	}
+	require.Equal(t, model.Counter, byID["http_requests_total"].MType)
+	require.Equal(t, int64(5), *byID["http_requests_total"].Delta)
+	require.Equal(t, model.Gauge, byID["http_latency"].MType)
+	require.Equal(t, 0.25, *byID["http_latency"].Value)
+	require.Equal(t, model.Gauge, byID["http"].MType)
+	require.Equal(t, 3.0, *byID["http"].Value)
+
+	point, err = ParseLine(`event message="started"`)
+	require.NoError(t, err)
+	_, err = Rule{}.ToMetrics(point)
+	require.Error(t, err)
+}
@@ -0,0 +1,134 @@
+package influx
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/service"
+)
+
+// SeriesTTL - время, после которого состояние counter без новых значений удаляется.
+const SeriesTTL = time.Hour
+
+// Receiver сохраняет точки line protocol через сервис. Целые поля counter в Telegraf
+// и InfluxDB накопительные, поэтому сохраняется прирост с предыдущего значения ряда:
+// первое значение ряда - точка отсчета с приростом 0, уменьшение значения считается
+// сбросом. Состояние рядов без новых значений в течение SeriesTTL удаляется.
+type Receiver struct {
+	rule Rule
+	now  func() time.Time
+
+	mu        sync.Mutex
+	counters  map[string]*counterState
+	lastPrune time.Time
+}
+
+type counterState struct {
+	value    int64
+	lastSeen time.Time
+}
+
+func NewReceiver(rule Rule) *Receiver {
+	return &Receiver{
+		rule:      rule,
+		now:       time.Now,
+		counters:  make(map[string]*counterState),
+		lastPrune: time.Now(),
+	}
+}
+
+// Result описывает результат записи: количество сохраненных строк и ошибки по строкам.
+type Result struct {
+	Written int
+	Lines   []*LineError
+}
+
+// Write сохраняет точки через tenantService - сервис арендатора tenant; lines - номера
+// строк точек. Каждая строка записывается атомарно, отклоненные строки не прерывают
+// обработку. Сбой хранилища до первой записанной строки возвращается ошибкой, и запрос
+// можно повторить целиком. После записанных строк сбой отклоняет оставшиеся строки:
+// повтор всего запроса прибавил бы уже записанные counter второй раз.
+func (r *Receiver) Write(tenantService service.Service, tenant string, points []Point, lines []int) (Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune()
+
+	var result Result
+	reject := func(line int, err error) {
+		result.Lines = append(result.Lines, &LineError{Line: line, Err: err.Error()})
+	}
+	for i, point := range points {
+		metrics, err := r.rule.ToMetrics(point)
+		if err != nil {
+			reject(lines[i], err)
+			continue
+		}
+		totals, err := r.deltas(tenant, metrics)
+		if err != nil {
+			reject(lines[i], err)
+			continue
+		}
+		if _, err := tenantService.SaveMetrics(metrics); err != nil {
+			if service.IsRejected(err) {
+				reject(lines[i], err)
+				continue
+			}
+			if result.Written == 0 {
+				return result, err
+			}
+			for _, line := range lines[i:] {
+				reject(line, fmt.Errorf("not written: %w", err))
+			}
+			return result, nil
+		}
+		now := r.now()
+		for key, total := range totals {
+			r.counters[key] = &counterState{value: total, lastSeen: now}
+		}
+		result.Written++
+	}
+	return result, nil
+}
+
+// deltas заменяет накопленные значения counter приростами и возвращает новые
+// накопленные значения, которые запоминаются после успешной записи.
+func (r *Receiver) deltas(tenant string, metrics []*model.Metrics) (map[string]int64, error) {
+	totals := make(map[string]int64)
+	for _, metric := range metrics {
+		if metric.MType != model.Counter {
+			continue
+		}
+		total := *metric.Delta
+		if total < 0 {
+			return nil, fmt.Errorf("counter %s cannot be negative", metric.ID)
+		}
+		key := tenant + "/" + metric.FullID()
+		delta := int64(0)
+		if state, ok := r.counters[key]; ok {
+			delta = total - state.value
+			if total < state.value {
+				delta = total
+			}
+		}
+		metric.Delta = &delta
+		totals[key] = total
+	}
+	return totals, nil
+}
+
+// prune удаляет состояние counter, которые не обновлялись SeriesTTL. Проверка идет
+// не чаще раза в SeriesTTL, чтобы не обходить все ряды на каждом запросе.
+func (r *Receiver) prune() {
+	now := r.now()
+	if now.Sub(r.lastPrune) < SeriesTTL {
+		return
+	}
+	r.lastPrune = now
+	for key, state := range r.counters {
+		if now.Sub(state.lastSeen) >= SeriesTTL {
+			delete(r.counters, key)
+		}
+	}
+}
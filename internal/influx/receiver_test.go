@@ -0,0 +1,118 @@
+package influx
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/repository"
+	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+// failingRepository отказывает после failAfter успешных записей.
+type failingRepository struct {
+	repository.MetricsRepository
+	failAfter int
+}
+
+func (r *failingRepository) UpdateMetrics(metrics []*model.Metrics) error {
+	if r.failAfter == 0 {
+		return fmt.Errorf("storage is down")
+	}
+	r.failAfter--
+	return r.MetricsRepository.UpdateMetrics(metrics)
+}
+
+func parse(t *testing.T, lines ...string) []Point {
+	t.Helper()
+	points := make([]Point, len(lines))
+	for i, line := range lines {
+		point, err := ParseLine(line)
+		require.NoError(t, err)
+		points[i] = point
+	}
+	return points
+}
+
+func TestReceiverCumulativeCounters(t *testing.T) {
+	storage := repository.NewMemStorage()
+	metricsService := service.NewMetricsService(storage)
+	receiver := NewReceiver(Rule{CounterPattern: regexp.MustCompile(`_total$`)})
+
+	counter := func(tenant string) int64 {
+		metric, err := storage.GetMetric(&model.Metrics{MType: model.Counter, ID: "http_requests_total", Tenant: tenant})
+		require.NoError(t, err)
+		return *metric.Delta
+	}
+	write := func(tenant string, lines ...string) Result {
+		points := parse(t, lines...)
+		result, err := receiver.Write(metricsService.ForTenant(tenant), tenant, points, make([]int, len(points)))
+		require.NoError(t, err)
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		tenant   string
+		value    string
+		expected int64
+	}{
+		{name: "first value is a baseline", value: "100i", expected: 0},
+		{name: "increase is added", value: "130i", expected: 30},
+		{name: "same value adds nothing", value: "130i", expected: 30},
+		{name: "decrease is a reset", value: "5i", expected: 35},
+		{name: "tenants are independent", tenant: "team-a", value: "7i", expected: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := write(test.tenant, "http requests_total="+test.value)
+			require.Equal(t, 1, result.Written)
+			require.Equal(t, test.expected, counter(test.tenant))
+		})
+	}
+
+	result := write("", "http requests_total=-1i")
+	require.Len(t, result.Lines, 1, "Negative cumulative counters must be rejected")
+}
+
+func TestReceiverExpiresIdleCounters(t *testing.T) {
+	metricsService := service.NewMetricsService(repository.NewMemStorage())
+	receiver := NewReceiver(Rule{CounterPattern: regexp.MustCompile(`_total$`)})
+	now := time.Now()
+	receiver.now = func() time.Time { return now }
+
+	write := func(line string) {
+		_, err := receiver.Write(metricsService, "", parse(t, line), []int{1})
+		require.NoError(t, err)
+	}
+	write("old requests_total=1i")
+	now = now.Add(SeriesTTL)
+	write("active requests_total=1i")
+
+	require.NotContains(t, receiver.counters, "/old_requests_total")
+	require.Contains(t, receiver.counters, "/active_requests_total")
+}
+
+func TestReceiverStorageFailure(t *testing.T) {
+	lines := []string{"http requests_total=1i", "http requests_total=2i", "cpu usage=0.5"}
+
+	t.Run("nothing written", func(t *testing.T) {
+		storage := &failingRepository{MetricsRepository: repository.NewMemStorage()}
+		receiver := NewReceiver(Rule{CounterPattern: regexp.MustCompile(`_total$`)})
+		_, err := receiver.Write(service.NewMetricsService(storage), "", parse(t, lines...), []int{1, 2, 3})
+		require.Error(t, err, "Request without written lines can be retried")
+	})
+
+	t.Run("after written lines", func(t *testing.T) {
+		storage := &failingRepository{MetricsRepository: repository.NewMemStorage(), failAfter: 1}
+		receiver := NewReceiver(Rule{CounterPattern: regexp.MustCompile(`_total$`)})
+		result, err := receiver.Write(service.NewMetricsService(storage), "", parse(t, lines...), []int{1, 2, 3})
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Written)
+		require.Len(t, result.Lines, 2, "Remaining lines must be reported as rejected")
+		require.Equal(t, 2, result.Lines[0].Line)
+	})
+}
@@ -0,0 +1,183 @@
+package metricsctl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/prbllm/go-metrics/internal/config"
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/tenant"
+	"github.com/prbllm/go-metrics/internal/transfer"
+)
+
+// apiClient обращается к HTTP API сервера метрик.
+type apiClient struct {
+	baseURL    string
+	tenant     string
+	token      string
+	httpClient *http.Client
+}
+
+func newAPIClient(address, tenant, token string, httpClient *http.Client) *apiClient {
+	baseURL := strings.TrimRight(address, "/")
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "http://" + baseURL
+	}
+	return &apiClient{baseURL: baseURL, tenant: tenant, token: token, httpClient: httpClient}
+}
+
+func (c *apiClient) list(ctx context.Context, matches []string) ([]*model.Metrics, error) {
+	query := url.Values{}
+	for _, match := range matches {
+		query.Add("match", match)
+	}
+	req, err := c.newRequest(ctx, http.MethodGet, config.CommonPath, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var metrics []*model.Metrics
+	if err := c.do(req, &metrics); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+func (c *apiClient) get(ctx context.Context, metricType, name string, labels map[string]string) (*model.Metrics, error) {
+	body, err := json.Marshal(model.Metrics{ID: name, MType: metricType, Labels: labels})
+	if err != nil {
+		return nil, err
+	}
+	req, err := c.newRequest(ctx, http.MethodPost, config.ValuePath+"/", nil, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var metric model.Metrics
+	if err := c.do(req, &metric); err != nil {
+		return nil, err
+	}
+	return &metric, nil
+}
+
+func (c *apiClient) push(ctx context.Context, metricType, name, value string, labels map[string]string) error {
+	path := fmt.Sprintf("%s/%s/%s/%s", config.UpdatePath, url.PathEscape(metricType), url.PathEscape(name), url.PathEscape(value))
+	req, err := c.newRequest(ctx, http.MethodPost, path, labelsQuery(labels), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	return c.do(req, nil)
+}
+
+func (c *apiClient) delete(ctx context.Context, metricType, name string, labels map[string]string) error {
+	path := fmt.Sprintf("%s/%s/%s", config.ValuePath, url.PathEscape(metricType), url.PathEscape(name))
+	req, err := c.newRequest(ctx, http.MethodDelete, path, labelsQuery(labels), nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req, nil)
+}
+
+func (c *apiClient) pushBatch(ctx context.Context, metrics []*model.Metrics) error {
+	body, err := json.Marshal(metrics)
+	if err != nil {
+		return err
+	}
+	req, err := c.newRequest(ctx, http.MethodPost, config.UpdatesPath+"/", nil, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, nil)
+}
+
+// export возвращает тело ответа с выгрузкой хранилища. Тело закрывает вызывающий.
+func (c *apiClient) export(ctx context.Context, compress bool) (io.ReadCloser, error) {
+	query := url.Values{}
+	if compress {
+		query.Set("compress", "gzip")
+	}
+	req, err := c.newRequest(ctx, http.MethodGet, config.AdminExportPath, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, responseError(req, resp)
+	}
+	return resp.Body, nil
+}
+
+func (c *apiClient) importDump(ctx context.Context, body io.Reader, mode string) (transfer.Result, error) {
+	var result transfer.Result
+	req, err := c.newRequest(ctx, http.MethodPost, config.AdminImportPath, url.Values{"mode": {mode}}, body)
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	err = c.do(req, &result)
+	return result, err
+}
+
+func (c *apiClient) newRequest(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Request, error) {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.tenant != "" {
+		req.Header.Set(tenant.Header, c.tenant)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return req, nil
+}
+
+func (c *apiClient) do(req *http.Request, result any) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(req, resp)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+func responseError(req *http.Request, resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(message)))
+}
+
+func labelsQuery(labels map[string]string) url.Values {
+	query := url.Values{}
+	for name, value := range labels {
+		query.Set(name, value)
+	}
+	return query
+}
@@ -0,0 +1,382 @@
+// Package metricsctl реализует утилиту командной строки для работы с сервером метрик.
+package metricsctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/transfer"
+)
+
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+)
+
+const usage = `Usage: metricsctl [flags] <command> [arguments]
+
+Commands:
+  list [-match name=value]...                  list all metrics
+  get <type> <name> [label=value]...           get a single metric
+  push <type> <name> <value> [label=value]...  push a metric value
+  delete <type> <name> [label=value]...        delete a metric
+  dump [-f file]                               write all metrics as JSON (stdout by default)
+  restore [-f file]                            push metrics from a dump (stdin by default)
+  export [-f file] [-gzip]                     export all series as JSON Lines (admin)
+  import [-f file] [-mode merge|replace]       import an export into the server (admin)
+
+Flags:
+`
+
+type cli struct {
+	api    *apiClient
+	output string
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+// Run выполняет команду и возвращает код завершения процесса.
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("metricsctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	address := fs.String("a", "localhost:8080", "Server address")
+	output := fs.String("o", OutputTable, "Output format: table or json")
+	timeout := fs.Duration("timeout", 10*time.Second, "Request timeout")
+	token := fs.String("token", os.Getenv("METRICSCTL_TOKEN"), "API token sent in the Authorization: Bearer header (default: $METRICSCTL_TOKEN)")
+	tenantName := fs.String("tenant", "", "Tenant sent in the X-Tenant-ID header, empty uses the default namespace")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *output != OutputTable && *output != OutputJSON {
+		fmt.Fprintf(stderr, "unknown output format %q\n", *output)
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	c := &cli{
+		api:    newAPIClient(*address, *tenantName, *token, &http.Client{Timeout: *timeout}),
+		output: *output,
+		stdin:  stdin,
+		stdout: stdout,
+	}
+
+	ctx := context.Background()
+	command, commandArgs := fs.Arg(0), fs.Args()[1:]
+	var err error
+	switch command {
+	case "list":
+		err = c.list(ctx, commandArgs)
+	case "get":
+		err = c.get(ctx, commandArgs)
+	case "push":
+		err = c.push(ctx, commandArgs)
+	case "delete":
+		err = c.delete(ctx, commandArgs)
+	case "dump":
+		err = c.dump(ctx, commandArgs)
+	case "restore":
+		err = c.restore(ctx, commandArgs)
+	case "export":
+		err = c.exportAll(ctx, commandArgs)
+	case "import":
+		err = c.importAll(ctx, commandArgs)
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n", command)
+		fs.Usage()
+		return 2
+	}
+
+	if err != nil {
+		var usageErr usageError
+		if errors.As(err, &usageErr) {
+			fmt.Fprintf(stderr, "%s: %v\n", command, err)
+			return 2
+		}
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+type usageError struct {
+	message string
+}
+
+func (e usageError) Error() string {
+	return e.message
+}
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func (c *cli) list(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var matches stringList
+	fs.Var(&matches, "match", "Label matcher, for example host=a or service=~\"api.*\"")
+	if err := fs.Parse(args); err != nil {
+		return usageError{err.Error()}
+	}
+
+	metrics, err := c.api.list(ctx, matches)
+	if err != nil {
+		return err
+	}
+	return c.print(metrics)
+}
+
+func (c *cli) get(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		return usageError{"expected <type> <name> [label=value]..."}
+	}
+	labels, err := parseLabels(args[2:])
+	if err != nil {
+		return err
+	}
+	metric, err := c.api.get(ctx, args[0], args[1], labels)
+	if err != nil {
+		return err
+	}
+	if c.output == OutputJSON {
+		return c.printJSON(metric)
+	}
+	return c.print([]*model.Metrics{metric})
+}
+
+func (c *cli) push(ctx context.Context, args []string) error {
+	if len(args) < 3 {
+		return usageError{"expected <type> <name> <value> [label=value]..."}
+	}
+	labels, err := parseLabels(args[3:])
+	if err != nil {
+		return err
+	}
+	return c.api.push(ctx, args[0], args[1], args[2], labels)
+}
+
+func (c *cli) delete(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		return usageError{"expected <type> <name> [label=value]..."}
+	}
+	labels, err := parseLabels(args[2:])
+	if err != nil {
+		return err
+	}
+	return c.api.delete(ctx, args[0], args[1], labels)
+}
+
+// dump всегда пишет JSON независимо от -o, чтобы результат можно было передать в restore.
+// Служебные метрики сервера не выгружаются: сервер не принимает их от клиентов.
+func (c *cli) dump(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	file := fs.String("f", "", "Output file")
+	if err := fs.Parse(args); err != nil {
+		return usageError{err.Error()}
+	}
+
+	metrics, err := c.api.list(ctx, nil)
+	if err != nil {
+		return err
+	}
+	clientMetrics := make([]*model.Metrics, 0, len(metrics))
+	for _, metric := range metrics {
+		if !strings.HasPrefix(metric.ID, model.SelfMetricPrefix) {
+			clientMetrics = append(clientMetrics, metric)
+		}
+	}
+	sortMetrics(clientMetrics)
+
+	out := c.stdout
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(clientMetrics)
+}
+
+// restore отправляет метрики из дампа одним пакетом. Значения счетчиков и гистограмм
+// прибавляются к уже существующим на сервере.
+func (c *cli) restore(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	file := fs.String("f", "", "Input file")
+	if err := fs.Parse(args); err != nil {
+		return usageError{err.Error()}
+	}
+
+	in := c.stdin
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var metrics []*model.Metrics
+	if err := json.NewDecoder(in).Decode(&metrics); err != nil {
+		return fmt.Errorf("decode dump: %w", err)
+	}
+	if len(metrics) == 0 {
+		return nil
+	}
+	if err := c.api.pushBatch(ctx, metrics); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "restored %d metrics\n", len(metrics))
+	return nil
+}
+
+// exportAll сохраняет выгрузку сервера как есть. В отличие от dump, в нее попадают
+// ряды всех арендаторов, и counter при загрузке можно заменить, а не сложить.
+func (c *cli) exportAll(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	file := fs.String("f", "", "Output file")
+	compress := fs.Bool("gzip", false, "Compress the export with gzip")
+	if err := fs.Parse(args); err != nil {
+		return usageError{err.Error()}
+	}
+
+	body, err := c.api.export(ctx, *compress)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	out := c.stdout
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	if _, err := io.Copy(out, body); err != nil {
+		return fmt.Errorf("read export: %w", err)
+	}
+	return nil
+}
+
+// importAll отправляет выгрузку на сервер. Сжатые выгрузки сервер распознает сам.
+func (c *cli) importAll(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	file := fs.String("f", "", "Input file")
+	mode := fs.String("mode", transfer.ModeMerge, "merge adds counters to existing series, replace overwrites them")
+	if err := fs.Parse(args); err != nil {
+		return usageError{err.Error()}
+	}
+	if err := transfer.ValidateMode(*mode); err != nil {
+		return usageError{err.Error()}
+	}
+
+	in := c.stdin
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	result, err := c.api.importDump(ctx, in, *mode)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "imported %d series, skipped %d\n", result.Records, result.Skipped)
+	return nil
+}
+
+func (c *cli) print(metrics []*model.Metrics) error {
+	sortMetrics(metrics)
+	if c.output == OutputJSON {
+		return c.printJSON(metrics)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tNAME\tLABELS\tVALUE")
+	for _, metric := range metrics {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", metric.MType, metric.ID, model.FormatLabels(metric.Labels), formatValue(metric))
+	}
+	return w.Flush()
+}
+
+func (c *cli) printJSON(v any) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func sortMetrics(metrics []*model.Metrics) {
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].MType != metrics[j].MType {
+			return metrics[i].MType < metrics[j].MType
+		}
+		return metrics[i].FullID() < metrics[j].FullID()
+	})
+}
+
+func formatValue(metric *model.Metrics) string {
+	switch {
+	case metric.MType == model.Counter && metric.Delta != nil:
+		return strconv.FormatInt(*metric.Delta, 10)
+	case metric.MType == model.Gauge && metric.Value != nil:
+		return strconv.FormatFloat(*metric.Value, 'g', -1, 64)
+	case metric.MType == model.Histogram || metric.MType == model.Summary:
+		return metric.DistributionString()
+	}
+	return "N/A"
+}
+
+func parseLabels(args []string) (map[string]string, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string, len(args))
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || name == "" {
+			return nil, usageError{fmt.Sprintf("invalid label %q, expected name=value", arg)}
+		}
+		labels[name] = value
+	}
+	return labels, nil
+}
@@ -0,0 +1,141 @@
+package metricsctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prbllm/go-metrics/internal/config"
+	"github.com/prbllm/go-metrics/internal/handler"
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/repository"
+	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer() *httptest.Server {
+	storage := repository.NewMemStorage()
+	handlers := handler.NewHandlers(service.NewMetricsService(storage))
+	transferHandler := handler.NewTransferHandler(storage, service.DefaultNamingPolicy())
+	router := chi.NewRouter()
+	router.Get(config.AdminExportPath, transferHandler.ExportHandler)
+	router.Post(config.AdminImportPath, transferHandler.ImportHandler)
+	router.Route(config.CommonPath, func(r chi.Router) {
+		r.Get("/", handlers.GetAllMetricsHandler)
+		r.Route(config.UpdatePath, func(r chi.Router) {
+			r.Post("/{metricType}/{metricName}/{metricValue}", handlers.UpdateMetricHandler)
+		})
+		r.Route(config.UpdatesPath, func(r chi.Router) {
+			r.Post("/", handlers.UpdateMetricsBatchHandler)
+		})
+		r.Route(config.ValuePath, func(r chi.Router) {
+			r.Post("/", handlers.GetValueJSONHandler)
+			r.Delete("/{metricType}/{metricName}", handlers.DeleteMetricHandler)
+		})
+	})
+	return httptest.NewServer(router)
+}
+
+func run(t *testing.T, server *httptest.Server, stdin string, args ...string) (string, string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := Run(append([]string{"-a", server.URL}, args...), strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+func TestMetricsctl(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	_, stderr, code := run(t, server, "", "push", "counter", "requests", "3", "host=a")
+	require.Equal(t, 0, code, stderr)
+	_, stderr, code = run(t, server, "", "push", "gauge", "temperature", "36.6")
+	require.Equal(t, 0, code, stderr)
+
+	stdout, stderr, code := run(t, server, "", "list")
+	require.Equal(t, 0, code, stderr)
+	require.Equal(t, "TYPE     NAME         LABELS      VALUE\n"+
+		"counter  requests     {host=\"a\"}  3\n"+
+		"gauge    temperature              36.6\n", stdout)
+
+	stdout, stderr, code = run(t, server, "", "-o", "json", "get", "counter", "requests", "host=a")
+	require.Equal(t, 0, code, stderr)
+	var metric model.Metrics
+	require.NoError(t, json.Unmarshal([]byte(stdout), &metric))
+	require.Equal(t, int64(3), *metric.Delta)
+
+	stdout, stderr, code = run(t, server, "", "list", "-match", "host=a")
+	require.Equal(t, 0, code, stderr)
+	require.NotContains(t, stdout, "temperature")
+
+	dump, stderr, code := run(t, server, "", "dump")
+	require.Equal(t, 0, code, stderr)
+
+	_, stderr, code = run(t, server, "", "delete", "counter", "requests", "host=a")
+	require.Equal(t, 0, code, stderr)
+	_, stderr, code = run(t, server, "", "get", "counter", "requests", "host=a")
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "404 Not Found")
+
+	stdout, stderr, code = run(t, server, dump, "restore")
+	require.Equal(t, 0, code, stderr)
+	require.Equal(t, "restored 2 metrics\n", stdout)
+	stdout, _, code = run(t, server, "", "get", "counter", "requests", "host=a")
+	require.Equal(t, 0, code)
+	require.Contains(t, stdout, "3")
+}
+
+func TestMetricsctlExportImport(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	_, stderr, code := run(t, server, "", "push", "counter", "requests", "3")
+	require.Equal(t, 0, code, stderr)
+	_, stderr, code = run(t, server, "", "push", "gauge", "temperature", "21.5")
+	require.Equal(t, 0, code, stderr)
+
+	export, stderr, code := run(t, server, "", "export")
+	require.Equal(t, 0, code, stderr)
+	require.Len(t, strings.Split(strings.TrimSpace(export), "\n"), 2)
+	compressed, stderr, code := run(t, server, "", "export", "-gzip")
+	require.Equal(t, 0, code, stderr)
+	require.True(t, strings.HasPrefix(compressed, "\x1f\x8b"))
+
+	stdout, stderr, code := run(t, server, export, "import")
+	require.Equal(t, 0, code, stderr)
+	require.Equal(t, "imported 2 series, skipped 0\n", stdout)
+	stdout, _, _ = run(t, server, "", "get", "counter", "requests")
+	require.Contains(t, stdout, "6")
+
+	_, stderr, code = run(t, server, compressed, "import", "-mode", "replace")
+	require.Equal(t, 0, code, stderr)
+	stdout, _, _ = run(t, server, "", "get", "counter", "requests")
+	require.Contains(t, stdout, "3")
+	require.NotContains(t, stdout, "6")
+
+	_, stderr, code = run(t, server, export, "import", "-mode", "overwrite")
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, "unknown import mode")
+}
+
+func TestMetricsctlUsageErrors(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	_, _, code := run(t, server, "")
+	require.Equal(t, 2, code)
+	_, stderr, code := run(t, server, "", "unknown")
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, `unknown command "unknown"`)
+	_, _, code = run(t, server, "", "get", "counter")
+	require.Equal(t, 2, code)
+	_, stderr, code = run(t, server, "", "push", "counter", "requests", "1", "host")
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, "expected name=value")
+	_, stderr, code = run(t, server, "", "push", "counter", "requests", "abc")
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "400 Bad Request")
+}
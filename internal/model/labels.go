@@ -0,0 +1,133 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	MatchEqual     = "="
+	MatchNotEqual  = "!="
+	MatchRegexp    = "=~"
+	MatchNotRegexp = "!~"
+)
+
+// LabelMatcher описывает условие на значение метки в стиле Prometheus:
+// host="a", host!="a", host=~"a.*", host!~"a.*".
+type LabelMatcher struct {
+	Name  string
+	Type  string
+	Value string
+	re    *regexp.Regexp
+}
+
+func NewLabelMatcher(name, matchType, value string) (*LabelMatcher, error) {
+	if name == "" {
+		return nil, fmt.Errorf("label matcher name cannot be empty")
+	}
+	matcher := &LabelMatcher{Name: name, Type: matchType, Value: value}
+	switch matchType {
+	case MatchEqual, MatchNotEqual:
+	case MatchRegexp, MatchNotRegexp:
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid label matcher regexp %q: %w", value, err)
+		}
+		matcher.re = re
+	default:
+		return nil, fmt.Errorf("unknown label match type %q", matchType)
+	}
+	return matcher, nil
+}
+
+// ParseLabelMatcher разбирает строку вида name=value, name!=value, name=~re или name!~re.
+// Значение может быть заключено в двойные кавычки.
+func ParseLabelMatcher(s string) (*LabelMatcher, error) {
+	idx := strings.IndexAny(s, "=!")
+	if idx <= 0 {
+		return nil, fmt.Errorf("invalid label matcher %q", s)
+	}
+	name := strings.TrimSpace(s[:idx])
+	rest := s[idx:]
+
+	var matchType string
+	for _, t := range []string{MatchNotEqual, MatchRegexp, MatchNotRegexp, MatchEqual} {
+		if strings.HasPrefix(rest, t) {
+			matchType = t
+			break
+		}
+	}
+	if matchType == "" {
+		return nil, fmt.Errorf("invalid label matcher %q", s)
+	}
+
+	value := strings.TrimSpace(rest[len(matchType):])
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+	return NewLabelMatcher(name, matchType, value)
+}
+
+func (m *LabelMatcher) Matches(labels map[string]string) bool {
+	value := labels[m.Name]
+	switch m.Type {
+	case MatchEqual:
+		return value == m.Value
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(value)
+	case MatchNotRegexp:
+		return !m.re.MatchString(value)
+	}
+	return false
+}
+
+func (m *LabelMatcher) String() string {
+	return fmt.Sprintf("%s%s%q", m.Name, m.Type, m.Value)
+}
+
+func MatchLabels(labels map[string]string, matchers []*LabelMatcher) bool {
+	for _, matcher := range matchers {
+		if !matcher.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func SortedLabelNames(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// labelValueEscaper экранирует значение метки по правилам текстового формата Prometheus.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// FormatLabels возвращает метки в формате Prometheus с сортировкой по имени: {a="1",b="2"}.
+// В значениях экранируются только \, " и перевод строки, остальные символы, в том
+// числе UTF-8, пишутся как есть. Для пустого набора меток возвращается пустая строка.
+func FormatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, name := range SortedLabelNames(labels) {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteString(`="`)
+		labelValueEscaper.WriteString(&sb, labels[name])
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatLabels(t *testing.T) {
+	require.Equal(t, "", FormatLabels(nil))
+	require.Equal(t, `{a="1",b="2"}`, FormatLabels(map[string]string{"b": "2", "a": "1"}))
+	require.Equal(t, `{a="x\"y"}`, FormatLabels(map[string]string{"a": `x"y`}))
+	require.Equal(t, "{a=\"c:\\\\tmp\\nнет\ttab\"}", FormatLabels(map[string]string{"a": "c:\\tmp\nнет\ttab"}))
+}
+
+func TestParseLabelMatcher(t *testing.T) {
+	tests := []struct {
+		input     string
+		labels    map[string]string
+		matches   bool
+		expectErr bool
+	}{
+		{input: "host=a", labels: map[string]string{"host": "a"}, matches: true},
+		{input: `host="a"`, labels: map[string]string{"host": "b"}, matches: false},
+		{input: "host!=a", labels: map[string]string{"host": "b"}, matches: true},
+		{input: "host!=a", labels: nil, matches: true},
+		{input: "host=~a.*", labels: map[string]string{"host": "abc"}, matches: true},
+		{input: "host=~a", labels: map[string]string{"host": "abc"}, matches: false},
+		{input: "host!~a.*", labels: map[string]string{"host": "abc"}, matches: false},
+		{input: "=a", expectErr: true},
+		{input: "host", expectErr: true},
+		{input: "host=~(", expectErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			matcher, err := ParseLabelMatcher(test.input)
+			if test.expectErr {
+				require.Error(t, err, "Expected error")
+				return
+			}
+			require.NoError(t, err, "Failed to parse matcher")
+			require.Equal(t, test.matches, matcher.Matches(test.labels))
+		})
+	}
+}
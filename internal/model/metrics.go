@@ -3,25 +3,54 @@ package model
 import "fmt"
 
 const (
-	Counter = "counter"
-	Gauge   = "gauge"
+	Counter   = "counter"
+	Gauge     = "gauge"
+	Histogram = "histogram"
+	Summary   = "summary"
 )
 
+// DefaultHistogramBuckets - границы бакетов гистограммы по умолчанию, совпадают с клиентом Prometheus.
+var DefaultHistogramBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Bucket - бакет гистограммы. Count накопительный: число наблюдений со значением <= UpperBound.
+type Bucket struct {
+	UpperBound float64 `json:"le"`
+	Count      uint64  `json:"count"`
+}
+
+// Quantile - значение квантиля сводки, например {0.99, 120}.
+type Quantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
 // NOTE: Не усложняем пример, вводя иерархическую вложенность структур.
 // Органичиваясь плоской моделью.
 // Delta и Value объявлены через указатели,
 // что бы отличать значение "0", от не заданного значения
 // и соответственно не кодировать в структуру.
+// Count, Sum, Buckets и Quantiles заполняются только для histogram и summary.
 type Metrics struct {
-	ID    string   `json:"id"`
-	MType string   `json:"type"`
-	Delta *int64   `json:"delta,omitempty"`
-	Value *float64 `json:"value,omitempty"`
-	Hash  string   `json:"hash,omitempty"`
+	ID        string            `json:"id"`
+	MType     string            `json:"type"`
+	Delta     *int64            `json:"delta,omitempty"`
+	Value     *float64          `json:"value,omitempty"`
+	Count     *uint64           `json:"count,omitempty"`
+	Sum       *float64          `json:"sum,omitempty"`
+	Buckets   []Bucket          `json:"buckets,omitempty"`
+	Quantiles []Quantile        `json:"quantiles,omitempty"`
+	Hash      string            `json:"hash,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	// Tenant - пространство имен владельца ряда. Пустое значение - пространство по умолчанию.
+	// Определяется сервером по запросу и не передается в JSON.
+	Tenant string `json:"-"`
 }
 
 func (m *Metrics) String() string {
 	metricString := fmt.Sprintf("Metric{ID: %s, MType: %s, ", m.ID, m.MType)
+	if len(m.Labels) > 0 {
+		metricString += fmt.Sprintf("Labels: %s, ", FormatLabels(m.Labels))
+	}
 	if m.Delta != nil {
 		metricString += fmt.Sprintf("Delta: %d, ", *m.Delta)
 	} else {
@@ -32,6 +61,9 @@ func (m *Metrics) String() string {
 	} else {
 		metricString += "Value: nil, "
 	}
+	if m.MType == Histogram || m.MType == Summary {
+		metricString += m.DistributionString() + ", "
+	}
 	if m.Hash != "" {
 		metricString += fmt.Sprintf("Hash: %s", m.Hash)
 	} else {
@@ -40,3 +72,55 @@ func (m *Metrics) String() string {
 	metricString += "}"
 	return metricString
 }
+
+// Clone возвращает копию метрики, не разделяющую с ней значения, метки и бакеты.
+func (m *Metrics) Clone() *Metrics {
+	clone := *m
+	if m.Delta != nil {
+		delta := *m.Delta
+		clone.Delta = &delta
+	}
+	if m.Value != nil {
+		value := *m.Value
+		clone.Value = &value
+	}
+	if m.Count != nil {
+		count := *m.Count
+		clone.Count = &count
+	}
+	if m.Sum != nil {
+		sum := *m.Sum
+		clone.Sum = &sum
+	}
+	if m.Buckets != nil {
+		clone.Buckets = append([]Bucket(nil), m.Buckets...)
+	}
+	if m.Quantiles != nil {
+		clone.Quantiles = append([]Quantile(nil), m.Quantiles...)
+	}
+	if m.Labels != nil {
+		clone.Labels = make(map[string]string, len(m.Labels))
+		for name, value := range m.Labels {
+			clone.Labels[name] = value
+		}
+	}
+	return &clone
+}
+
+// FullID возвращает имя метрики вместе с метками, например requests{host="a"}.
+func (m *Metrics) FullID() string {
+	return m.ID + FormatLabels(m.Labels)
+}
+
+// DistributionString возвращает краткое представление гистограммы или сводки: count=10 sum=2.5.
+func (m *Metrics) DistributionString() string {
+	var count uint64
+	var sum float64
+	if m.Count != nil {
+		count = *m.Count
+	}
+	if m.Sum != nil {
+		sum = *m.Sum
+	}
+	return fmt.Sprintf("count=%d sum=%g", count, sum)
+}
@@ -0,0 +1,6 @@
+package model
+
+// SelfMetricPrefix - зарезервированный префикс метрик, которые сервер сообщает о себе сам.
+const SelfMetricPrefix = "gometrics_"
+
+const SeriesCountMetric = SelfMetricPrefix + "series_count"
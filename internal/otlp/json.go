@@ -0,0 +1,27 @@
+package otlp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// UnmarshalJSON разбирает ExportMetricsServiceRequest в JSON-кодировке OTLP.
+func UnmarshalJSON(data []byte) (*ExportRequest, error) {
+	var request ExportRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return nil, fmt.Errorf("invalid OTLP JSON: %w", err)
+	}
+	for i := range request.ResourceMetrics {
+		for j := range request.ResourceMetrics[i].ScopeMetrics {
+			metrics := request.ResourceMetrics[i].ScopeMetrics[j].Metrics
+			for k := range metrics {
+				for _, data := range []*unsupportedData{metrics[k].Histogram, metrics[k].ExponentialHistogram, metrics[k].Summary} {
+					if data != nil {
+						metrics[k].UnsupportedPoints += len(data.DataPoints)
+					}
+				}
+			}
+		}
+	}
+	return &request, nil
+}
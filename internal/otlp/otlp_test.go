@@ -0,0 +1,279 @@
+package otlp
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/repository"
+	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func message(fields ...[]byte) []byte {
+	var b []byte
+	for _, f := range fields {
+		b = append(b, f...)
+	}
+	return b
+}
+
+func bytesField(number protowire.Number, value []byte) []byte {
+	b := protowire.AppendTag(nil, number, protowire.BytesType)
+	return protowire.AppendBytes(b, value)
+}
+
+func varintField(number protowire.Number, value uint64) []byte {
+	b := protowire.AppendTag(nil, number, protowire.VarintType)
+	return protowire.AppendVarint(b, value)
+}
+
+func fixed64Field(number protowire.Number, value uint64) []byte {
+	b := protowire.AppendTag(nil, number, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, value)
+}
+
+func stringAttribute(key, value string) []byte {
+	return message(bytesField(1, []byte(key)), bytesField(2, bytesField(1, []byte(value))))
+}
+
+func TestUnmarshalProto(t *testing.T) {
+	resource := bytesField(1, bytesField(1, stringAttribute("service.name", "api")))
+	gauge := message(
+		bytesField(1, []byte("memory.usage")),
+		bytesField(5, bytesField(1, message(
+			bytesField(7, stringAttribute("host", "a")),
+			fixed64Field(4, math.Float64bits(1.5)),
+		))),
+	)
+	sum := message(
+		bytesField(1, []byte("requests")),
+		bytesField(7, message(
+			bytesField(1, message(fixed64Field(2, 100), fixed64Field(6, 7))),
+			varintField(2, uint64(TemporalityCumulative)),
+			varintField(3, 1),
+		)),
+	)
+	histogram := message(bytesField(1, []byte("latency")), bytesField(9, message(bytesField(1, nil), bytesField(1, nil))))
+	data := bytesField(1, message(resource, bytesField(2, message(bytesField(2, gauge), bytesField(2, sum), bytesField(2, histogram)))))
+
+	request, err := UnmarshalProto(data)
+	require.NoError(t, err)
+	require.Len(t, request.ResourceMetrics, 1)
+	resourceMetrics := request.ResourceMetrics[0]
+	value, _ := resourceMetrics.Resource.Attributes[0].Value.String()
+	require.Equal(t, "api", value)
+
+	metrics := resourceMetrics.ScopeMetrics[0].Metrics
+	require.Len(t, metrics, 3)
+	require.Equal(t, "memory.usage", metrics[0].Name)
+	require.Equal(t, 1.5, *metrics[0].Gauge.DataPoints[0].AsDouble)
+	require.Equal(t, "host", metrics[0].Gauge.DataPoints[0].Attributes[0].Key)
+	require.True(t, metrics[1].Sum.IsMonotonic)
+	require.Equal(t, TemporalityCumulative, metrics[1].Sum.AggregationTemporality)
+	require.Equal(t, Uint64(100), metrics[1].Sum.DataPoints[0].StartTimeUnixNano)
+	require.Equal(t, Int64(7), *metrics[1].Sum.DataPoints[0].AsInt)
+	require.Equal(t, 2, metrics[2].UnsupportedPoints)
+
+	_, err = UnmarshalProto([]byte{0x0a, 0xff})
+	require.Error(t, err)
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	data := `{"resourceMetrics":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"api"}}]},
+		"scopeMetrics":[{"metrics":[
+			{"name":"requests","sum":{"aggregationTemporality":1,"isMonotonic":true,"dataPoints":[{"asInt":"3","attributes":[{"key":"code","value":{"intValue":"200"}}]}]}},
+			{"name":"latency","histogram":{"dataPoints":[{"count":"1"}]}}
+		]}]}]}`
+
+	request, err := UnmarshalJSON([]byte(data))
+	require.NoError(t, err)
+	metrics := request.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	require.Equal(t, TemporalityDelta, metrics[0].Sum.AggregationTemporality)
+	require.Equal(t, Int64(3), *metrics[0].Sum.DataPoints[0].AsInt)
+	code, _ := metrics[0].Sum.DataPoints[0].Attributes[0].Value.String()
+	require.Equal(t, "200", code)
+	require.Equal(t, 1, metrics[1].UnsupportedPoints)
+
+	_, err = UnmarshalJSON([]byte(`{"resourceMetrics":[{"scopeMetrics":[{"metrics":[{"name":"x","sum":{"aggregationTemporality":"BAD"}}]}]}]}`))
+	require.Error(t, err)
+}
+
+func sumRequest(name string, temporality Temporality, monotonic bool, start uint64, points ...NumberDataPoint) *ExportRequest {
+	for i := range points {
+		points[i].StartTimeUnixNano = Uint64(start)
+	}
+	return &ExportRequest{ResourceMetrics: []ResourceMetrics{{
+		Resource: Resource{Attributes: []KeyValue{
+			{Key: "service.name", Value: AnyValue{StringValue: ptr("api")}},
+			{Key: "host.name", Value: AnyValue{StringValue: ptr("a")}},
+		}},
+		ScopeMetrics: []ScopeMetrics{{Metrics: []Metric{{
+			Name: name,
+			Sum:  &Sum{DataPoints: points, AggregationTemporality: temporality, IsMonotonic: monotonic},
+		}}}},
+	}}}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestReceiverExport(t *testing.T) {
+	storage := repository.NewMemStorage()
+	metricsService := service.NewMetricsService(storage)
+	receiver := NewReceiver([]string{"service.name"})
+	labels := map[string]string{"host_name": "a"}
+
+	counter := func(id string) int64 {
+		metric, err := storage.GetMetric(&model.Metrics{MType: model.Counter, ID: id, Labels: labels})
+		require.NoError(t, err)
+		return *metric.Delta
+	}
+
+	export := func(request *ExportRequest) ExportResponse {
+		response, err := receiver.Export(metricsService, "", request)
+		require.NoError(t, err)
+		return response
+	}
+
+	t.Run("delta sum", func(t *testing.T) {
+		require.Nil(t, export(sumRequest("http.requests", TemporalityDelta, true, 0, NumberDataPoint{AsInt: ptr(Int64(3))})).PartialSuccess)
+		export(sumRequest("http.requests", TemporalityDelta, true, 0, NumberDataPoint{AsInt: ptr(Int64(2))}))
+		require.Equal(t, int64(5), counter("api_http_requests"))
+	})
+
+	t.Run("fractional delta sum", func(t *testing.T) {
+		for range 4 {
+			export(sumRequest("cpu.seconds", TemporalityDelta, true, 0, NumberDataPoint{AsDouble: ptr(0.5)}))
+		}
+		require.Equal(t, int64(2), counter("api_cpu_seconds"))
+	})
+
+	t.Run("cumulative sum with reset", func(t *testing.T) {
+		// Ряд начат до запуска сервера: первая точка - только точка отсчета.
+		export(sumRequest("bytes", TemporalityCumulative, true, 1, NumberDataPoint{AsInt: ptr(Int64(10))}))
+		_, err := storage.GetMetric(&model.Metrics{MType: model.Counter, ID: "api_bytes", Labels: labels})
+		require.ErrorIs(t, err, repository.ErrMetricNotFound)
+		export(sumRequest("bytes", TemporalityCumulative, true, 1, NumberDataPoint{AsInt: ptr(Int64(15))}))
+		require.Equal(t, int64(5), counter("api_bytes"))
+
+		export(sumRequest("bytes", TemporalityCumulative, true, 2, NumberDataPoint{AsInt: ptr(Int64(4))}))
+		require.Equal(t, int64(9), counter("api_bytes"))
+	})
+
+	t.Run("cumulative sum started after the receiver", func(t *testing.T) {
+		start := uint64(receiver.started.UnixNano()) + 1
+		export(sumRequest("jobs", TemporalityCumulative, true, start, NumberDataPoint{AsInt: ptr(Int64(3))}))
+		require.Equal(t, int64(3), counter("api_jobs"))
+	})
+
+	t.Run("non-monotonic sums", func(t *testing.T) {
+		export(sumRequest("queue.size", TemporalityCumulative, false, 0, NumberDataPoint{AsInt: ptr(Int64(-3))}))
+		metric, err := storage.GetMetric(&model.Metrics{MType: model.Gauge, ID: "api_queue_size", Labels: labels})
+		require.NoError(t, err)
+		require.Equal(t, -3.0, *metric.Value)
+
+		response := export(sumRequest("queue.changes", TemporalityDelta, false, 0, NumberDataPoint{AsInt: ptr(Int64(1))}))
+		require.NotNil(t, response.PartialSuccess)
+		require.Equal(t, int64(1), response.PartialSuccess.RejectedDataPoints)
+	})
+
+	t.Run("gauge and unsupported points", func(t *testing.T) {
+		request := &ExportRequest{ResourceMetrics: []ResourceMetrics{{ScopeMetrics: []ScopeMetrics{{Metrics: []Metric{
+			{Name: "temperature", Gauge: &Gauge{DataPoints: []NumberDataPoint{
+				{AsDouble: ptr(21.5), Attributes: []KeyValue{{Key: "room", Value: AnyValue{StringValue: ptr("kitchen")}}}},
+				{},
+			}}},
+			{Name: "latency", UnsupportedPoints: 2},
+		}}}}}}
+
+		response := export(request)
+		require.NotNil(t, response.PartialSuccess)
+		require.Equal(t, int64(3), response.PartialSuccess.RejectedDataPoints)
+
+		metric, err := storage.GetMetric(&model.Metrics{MType: model.Gauge, ID: "temperature", Labels: map[string]string{"room": "kitchen"}})
+		require.NoError(t, err)
+		require.Equal(t, 21.5, *metric.Value)
+	})
+
+	t.Run("storage error", func(t *testing.T) {
+		failing := &service.MockMetricsService{Error: fmt.Errorf("storage is down")}
+		_, err := NewReceiver(nil).Export(failing, "", sumRequest("requests", TemporalityDelta, true, 0, NumberDataPoint{AsInt: ptr(Int64(1))}))
+		require.Error(t, err)
+	})
+}
+
+func TestReceiverExpiresIdleSeries(t *testing.T) {
+	storage := repository.NewMemStorage()
+	metricsService := service.NewMetricsService(storage)
+	receiver := NewReceiver(nil)
+	now := receiver.started
+	receiver.now = func() time.Time { return now }
+
+	export := func(name string, value int64) {
+		_, err := receiver.Export(metricsService, "", sumRequest(name, TemporalityCumulative, true, 1, NumberDataPoint{AsInt: ptr(Int64(value))}))
+		require.NoError(t, err)
+	}
+	export("old", 10)
+	now = now.Add(SeriesTTL / 2)
+	export("active", 10)
+	now = now.Add(SeriesTTL / 2)
+	export("active", 12)
+
+	labels := `{host_name="a",service_name="api"}`
+	require.NotContains(t, receiver.series, "/old"+labels)
+	require.Contains(t, receiver.series, "/active"+labels)
+	require.Len(t, receiver.series, 1)
+}
+
+// failingRepository отказывает после failAfter успешных записей.
+type failingRepository struct {
+	repository.MetricsRepository
+	failAfter int
+}
+
+func (r *failingRepository) UpdateMetric(metric *model.Metrics) error {
+	if r.failAfter == 0 {
+		return fmt.Errorf("storage is down")
+	}
+	r.failAfter--
+	return r.MetricsRepository.UpdateMetric(metric)
+}
+
+func TestReceiverStorageFailure(t *testing.T) {
+	request := func() *ExportRequest {
+		return sumRequest("requests", TemporalityDelta, true, 1,
+			NumberDataPoint{AsInt: ptr(Int64(2))}, NumberDataPoint{AsInt: ptr(Int64(3))}, NumberDataPoint{AsInt: ptr(Int64(4))})
+	}
+
+	t.Run("before written points", func(t *testing.T) {
+		storage := &failingRepository{MetricsRepository: repository.NewMemStorage()}
+		_, err := NewReceiver(nil).Export(service.NewMetricsService(storage), "", request())
+		require.Error(t, err, "Request without written points can be retried")
+	})
+
+	t.Run("after written points", func(t *testing.T) {
+		storage := &failingRepository{MetricsRepository: repository.NewMemStorage(), failAfter: 1}
+		response, err := NewReceiver(nil).Export(service.NewMetricsService(storage), "", request())
+		require.NoError(t, err)
+		require.NotNil(t, response.PartialSuccess)
+		require.Equal(t, int64(2), response.PartialSuccess.RejectedDataPoints)
+		require.Contains(t, response.PartialSuccess.ErrorMessage, "storage is down")
+
+		metric, err := storage.GetMetric(&model.Metrics{ID: "requests", MType: model.Counter, Labels: map[string]string{"host_name": "a", "service_name": "api"}})
+		require.NoError(t, err)
+		require.Equal(t, int64(2), *metric.Delta)
+	})
+}
+
+func TestExportResponseMarshalProto(t *testing.T) {
+	require.Empty(t, ExportResponse{}.MarshalProto())
+
+	data := ExportResponse{PartialSuccess: &PartialSuccess{RejectedDataPoints: 2, ErrorMessage: "bad"}}.MarshalProto()
+	expected := bytesField(1, message(varintField(1, 2), bytesField(2, []byte("bad"))))
+	require.Equal(t, expected, data)
+}
@@ -0,0 +1,198 @@
+package otlp
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/prbllm/go-metrics/internal/protoutil"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// UnmarshalProto разбирает ExportMetricsServiceRequest в protobuf-кодировке.
+func UnmarshalProto(data []byte) (*ExportRequest, error) {
+	var request ExportRequest
+	err := protoutil.ParseFields(data, func(f protoutil.Field) error {
+		if !f.Is(1, protowire.BytesType) {
+			return nil
+		}
+		resourceMetrics, err := parseResourceMetrics(f.Bytes)
+		if err != nil {
+			return err
+		}
+		request.ResourceMetrics = append(request.ResourceMetrics, resourceMetrics)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP protobuf: %w", err)
+	}
+	return &request, nil
+}
+
+func parseResourceMetrics(b []byte) (ResourceMetrics, error) {
+	var resourceMetrics ResourceMetrics
+	err := protoutil.ParseFields(b, func(f protoutil.Field) error {
+		switch {
+		case f.Is(1, protowire.BytesType):
+			return protoutil.ParseFields(f.Bytes, func(f protoutil.Field) error {
+				if !f.Is(1, protowire.BytesType) {
+					return nil
+				}
+				attribute, err := parseKeyValue(f.Bytes)
+				resourceMetrics.Resource.Attributes = append(resourceMetrics.Resource.Attributes, attribute)
+				return err
+			})
+		case f.Is(2, protowire.BytesType):
+			var scopeMetrics ScopeMetrics
+			err := protoutil.ParseFields(f.Bytes, func(f protoutil.Field) error {
+				if !f.Is(2, protowire.BytesType) {
+					return nil
+				}
+				metric, err := parseMetric(f.Bytes)
+				scopeMetrics.Metrics = append(scopeMetrics.Metrics, metric)
+				return err
+			})
+			resourceMetrics.ScopeMetrics = append(resourceMetrics.ScopeMetrics, scopeMetrics)
+			return err
+		}
+		return nil
+	})
+	return resourceMetrics, err
+}
+
+func parseMetric(b []byte) (Metric, error) {
+	var metric Metric
+	err := protoutil.ParseFields(b, func(f protoutil.Field) error {
+		if f.Type != protowire.BytesType {
+			return nil
+		}
+		switch f.Number {
+		case 1:
+			metric.Name = string(f.Bytes)
+		case 5:
+			metric.Gauge = &Gauge{}
+			return protoutil.ParseFields(f.Bytes, func(f protoutil.Field) error {
+				if !f.Is(1, protowire.BytesType) {
+					return nil
+				}
+				point, err := parseNumberDataPoint(f.Bytes)
+				metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, point)
+				return err
+			})
+		case 7:
+			metric.Sum = &Sum{}
+			return protoutil.ParseFields(f.Bytes, func(f protoutil.Field) error {
+				switch {
+				case f.Is(1, protowire.BytesType):
+					point, err := parseNumberDataPoint(f.Bytes)
+					metric.Sum.DataPoints = append(metric.Sum.DataPoints, point)
+					return err
+				case f.Is(2, protowire.VarintType):
+					metric.Sum.AggregationTemporality = Temporality(f.Num)
+				case f.Is(3, protowire.VarintType):
+					metric.Sum.IsMonotonic = f.Num != 0
+				}
+				return nil
+			})
+		case 9, 10, 11:
+			// Histogram, ExponentialHistogram и Summary: считаем только число точек.
+			return protoutil.ParseFields(f.Bytes, func(f protoutil.Field) error {
+				if f.Is(1, protowire.BytesType) {
+					metric.UnsupportedPoints++
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	return metric, err
+}
+
+func parseNumberDataPoint(b []byte) (NumberDataPoint, error) {
+	var point NumberDataPoint
+	err := protoutil.ParseFields(b, func(f protoutil.Field) error {
+		switch {
+		case f.Is(7, protowire.BytesType):
+			attribute, err := parseKeyValue(f.Bytes)
+			point.Attributes = append(point.Attributes, attribute)
+			return err
+		case f.Is(2, protowire.Fixed64Type):
+			point.StartTimeUnixNano = Uint64(f.Num)
+		case f.Is(3, protowire.Fixed64Type):
+			point.TimeUnixNano = Uint64(f.Num)
+		case f.Is(4, protowire.Fixed64Type):
+			value := math.Float64frombits(f.Num)
+			point.AsDouble = &value
+		case f.Is(6, protowire.Fixed64Type):
+			value := Int64(f.Num)
+			point.AsInt = &value
+		}
+		return nil
+	})
+	return point, err
+}
+
+func parseKeyValue(b []byte) (KeyValue, error) {
+	var keyValue KeyValue
+	err := protoutil.ParseFields(b, func(f protoutil.Field) error {
+		switch {
+		case f.Is(1, protowire.BytesType):
+			keyValue.Key = string(f.Bytes)
+		case f.Is(2, protowire.BytesType):
+			value, err := parseAnyValue(f.Bytes)
+			keyValue.Value = value
+			return err
+		}
+		return nil
+	})
+	return keyValue, err
+}
+
+func parseAnyValue(b []byte) (AnyValue, error) {
+	var value AnyValue
+	err := protoutil.ParseFields(b, func(f protoutil.Field) error {
+		switch {
+		case f.Is(1, protowire.BytesType):
+			s := string(f.Bytes)
+			value.StringValue = &s
+		case f.Is(2, protowire.VarintType):
+			v := f.Num != 0
+			value.BoolValue = &v
+		case f.Is(3, protowire.VarintType):
+			v := Int64(f.Num)
+			value.IntValue = &v
+		case f.Is(4, protowire.Fixed64Type):
+			v := math.Float64frombits(f.Num)
+			value.DoubleValue = &v
+		case f.Is(5, protowire.BytesType):
+			value.ArrayValue = &ArrayValue{}
+			return protoutil.ParseFields(f.Bytes, func(f protoutil.Field) error {
+				if !f.Is(1, protowire.BytesType) {
+					return nil
+				}
+				item, err := parseAnyValue(f.Bytes)
+				value.ArrayValue.Values = append(value.ArrayValue.Values, item)
+				return err
+			})
+		}
+		return nil
+	})
+	return value, err
+}
+
+// MarshalProto кодирует ответ в protobuf.
+func (r ExportResponse) MarshalProto() []byte {
+	if r.PartialSuccess == nil {
+		return []byte{}
+	}
+	var partial []byte
+	if r.PartialSuccess.RejectedDataPoints != 0 {
+		partial = protowire.AppendTag(partial, 1, protowire.VarintType)
+		partial = protowire.AppendVarint(partial, uint64(r.PartialSuccess.RejectedDataPoints))
+	}
+	if r.PartialSuccess.ErrorMessage != "" {
+		partial = protowire.AppendTag(partial, 2, protowire.BytesType)
+		partial = protowire.AppendString(partial, r.PartialSuccess.ErrorMessage)
+	}
+	b := protowire.AppendTag(nil, 1, protowire.BytesType)
+	return protowire.AppendBytes(b, partial)
+}
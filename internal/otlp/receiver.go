@@ -0,0 +1,314 @@
+package otlp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/service"
+)
+
+// Receiver преобразует точки OTLP в метрики и сохраняет их через сервис:
+//   - Gauge сохраняется как gauge;
+//   - монотонный Sum сохраняется как counter: delta прибавляется как есть, для cumulative
+//     вычисляется прирост относительно предыдущего значения ряда (уменьшение значения или
+//     смена start time считаются сбросом); дробные значения накапливаются, в counter
+//     попадает целая часть;
+//   - первая точка cumulative ряда, начатого до запуска сервера, становится точкой
+//     отсчета и не прибавляется: ее накопленное значение уже могло быть учтено до
+//     перезапуска;
+//   - немонотонный cumulative Sum сохраняется как gauge, немонотонный delta Sum отклоняется.
+//
+// Атрибуты ресурса из prefixAttributes добавляются префиксом к ID метрики, остальные
+// атрибуты ресурса и атрибуты точки становятся метками. Точки в именах заменяются на "_".
+// Состояние рядов, которые не обновлялись SeriesTTL, удаляется.
+type Receiver struct {
+	prefixAttributes []string
+	started          time.Time
+	now              func() time.Time
+
+	mu        sync.Mutex
+	series    map[string]*seriesState
+	lastPrune time.Time
+}
+
+// SeriesTTL - время, после которого состояние ряда без новых точек удаляется.
+const SeriesTTL = time.Hour
+
+type seriesState struct {
+	start    uint64
+	total    float64
+	lastSeen time.Time
+}
+
+func NewReceiver(prefixAttributes []string) *Receiver {
+	now := time.Now()
+	return &Receiver{
+		prefixAttributes: prefixAttributes,
+		started:          now,
+		now:              time.Now,
+		series:           make(map[string]*seriesState),
+		lastPrune:        now,
+	}
+}
+
+// Export сохраняет все точки запроса через tenantService - сервис арендатора tenant.
+// Отклоненные точки не прерывают обработку и возвращаются в PartialSuccess. Ошибка
+// возвращается, только если хранилище отказало до записи первой точки: после этого
+// оставшиеся точки отклоняются, чтобы повтор запроса клиентом не учел записанные
+// приращения счетчиков дважды.
+func (r *Receiver) Export(tenantService service.Service, tenant string, request *ExportRequest) (ExportResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune()
+
+	var rejected, written int64
+	var lastErr, storageErr error
+	reject := func(err error) {
+		rejected++
+		lastErr = err
+	}
+	// save записывает точку и возвращает false, если запрос нужно прервать с ошибкой.
+	save := func(write func() error) bool {
+		if storageErr != nil {
+			reject(fmt.Errorf("not written: %w", storageErr))
+			return true
+		}
+		err := write()
+		switch {
+		case err == nil:
+			written++
+		case isRejected(err):
+			reject(err)
+		case written == 0:
+			storageErr = err
+			return false
+		default:
+			storageErr = err
+			reject(fmt.Errorf("not written: %w", err))
+		}
+		return true
+	}
+
+	for _, resourceMetrics := range request.ResourceMetrics {
+		prefix, resourceLabels := r.resourceLabels(resourceMetrics.Resource)
+		for _, scopeMetrics := range resourceMetrics.ScopeMetrics {
+			for _, metric := range scopeMetrics.Metrics {
+				if metric.UnsupportedPoints > 0 {
+					rejected += int64(metric.UnsupportedPoints)
+					lastErr = fmt.Errorf("metric %q: only gauge and sum are supported", metric.Name)
+				}
+				id := prefix + sanitize(metric.Name)
+				if metric.Name == "" {
+					if points := countPoints(metric); points > 0 {
+						rejected += int64(points)
+						lastErr = fmt.Errorf("metric name is empty")
+					}
+					continue
+				}
+
+				if metric.Gauge != nil {
+					for _, point := range metric.Gauge.DataPoints {
+						if !save(func() error { return r.saveGauge(tenantService, id, resourceLabels, point) }) {
+							return ExportResponse{}, storageErr
+						}
+					}
+				}
+				if metric.Sum != nil {
+					for _, point := range metric.Sum.DataPoints {
+						if !save(func() error { return r.saveSum(tenantService, tenant, id, resourceLabels, metric.Sum, point) }) {
+							return ExportResponse{}, storageErr
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if rejected == 0 {
+		return ExportResponse{}, nil
+	}
+	return ExportResponse{PartialSuccess: &PartialSuccess{RejectedDataPoints: rejected, ErrorMessage: lastErr.Error()}}, nil
+}
+
+func (r *Receiver) resourceLabels(resource Resource) (string, map[string]string) {
+	prefixes := make(map[string]string)
+	labels := make(map[string]string)
+	for _, attribute := range resource.Attributes {
+		value, ok := attribute.Value.String()
+		if !ok || value == "" {
+			continue
+		}
+		isPrefix := false
+		for _, name := range r.prefixAttributes {
+			if attribute.Key == name {
+				prefixes[name] = value
+				isPrefix = true
+			}
+		}
+		if !isPrefix {
+			labels[sanitize(attribute.Key)] = value
+		}
+	}
+
+	prefix := ""
+	for _, name := range r.prefixAttributes {
+		if value := prefixes[name]; value != "" {
+			prefix += sanitize(value) + "_"
+		}
+	}
+	return prefix, labels
+}
+
+func (r *Receiver) saveGauge(tenantService service.Service, id string, resourceLabels map[string]string, point NumberDataPoint) error {
+	value, err := pointValue(id, point)
+	if err != nil {
+		return err
+	}
+	_, err = tenantService.SaveMetric(&model.Metrics{ID: id, MType: model.Gauge, Value: &value, Labels: pointLabels(resourceLabels, point)})
+	return err
+}
+
+func (r *Receiver) saveSum(tenantService service.Service, tenant string, id string, resourceLabels map[string]string, sum *Sum, point NumberDataPoint) error {
+	value, err := pointValue(id, point)
+	if err != nil {
+		return err
+	}
+	metric := &model.Metrics{ID: id, Labels: pointLabels(resourceLabels, point)}
+
+	if !sum.IsMonotonic {
+		if sum.AggregationTemporality != TemporalityCumulative {
+			return pointErrorf("metric %q: non-monotonic delta sums are not supported", id)
+		}
+		metric.MType = model.Gauge
+		metric.Value = &value
+		_, err = tenantService.SaveMetric(metric)
+		return err
+	}
+	if value < 0 {
+		return pointErrorf("metric %q: monotonic sum cannot be negative", id)
+	}
+
+	key := tenant + "/" + metric.FullID()
+	state := r.series[key]
+	total := value
+	previous := 0.0
+	switch sum.AggregationTemporality {
+	case TemporalityDelta:
+		if point.AsInt != nil {
+			// Целые delta не требуют состояния.
+			delta := int64(*point.AsInt)
+			metric.MType = model.Counter
+			metric.Delta = &delta
+			_, err = tenantService.SaveMetric(metric)
+			return err
+		}
+		if state != nil {
+			previous = state.total
+			total = state.total + value
+		}
+	case TemporalityCumulative:
+		switch {
+		case state != nil && state.start == uint64(point.StartTimeUnixNano) && value >= state.total:
+			previous = state.total
+		case state == nil && uint64(point.StartTimeUnixNano) <= uint64(r.started.UnixNano()):
+			previous = total
+		}
+	default:
+		return pointErrorf("metric %q: aggregation temporality is not specified", id)
+	}
+
+	delta := int64(math.Floor(total) - math.Floor(previous))
+	if delta > 0 {
+		metric.MType = model.Counter
+		metric.Delta = &delta
+		if _, err := tenantService.SaveMetric(metric); err != nil {
+			return err
+		}
+	}
+	r.series[key] = &seriesState{start: uint64(point.StartTimeUnixNano), total: total, lastSeen: r.now()}
+	return nil
+}
+
+// prune удаляет состояние рядов, которые не обновлялись SeriesTTL. Проверка идет
+// не чаще раза в SeriesTTL, чтобы не обходить все ряды на каждом запросе.
+func (r *Receiver) prune() {
+	now := r.now()
+	if now.Sub(r.lastPrune) < SeriesTTL {
+		return
+	}
+	r.lastPrune = now
+	for key, state := range r.series {
+		if now.Sub(state.lastSeen) >= SeriesTTL {
+			delete(r.series, key)
+		}
+	}
+}
+
+func pointValue(id string, point NumberDataPoint) (float64, error) {
+	var value float64
+	switch {
+	case point.AsDouble != nil:
+		value = *point.AsDouble
+	case point.AsInt != nil:
+		value = float64(*point.AsInt)
+	default:
+		return 0, pointErrorf("metric %q: data point has no value", id)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, pointErrorf("metric %q: value %g is not finite", id, value)
+	}
+	return value, nil
+}
+
+func pointLabels(resourceLabels map[string]string, point NumberDataPoint) map[string]string {
+	labels := make(map[string]string, len(resourceLabels)+len(point.Attributes))
+	for name, value := range resourceLabels {
+		labels[name] = value
+	}
+	for _, attribute := range point.Attributes {
+		if value, ok := attribute.Value.String(); ok && value != "" {
+			labels[sanitize(attribute.Key)] = value
+		}
+	}
+	return labels
+}
+
+func countPoints(metric Metric) int {
+	points := 0
+	if metric.Gauge != nil {
+		points += len(metric.Gauge.DataPoints)
+	}
+	if metric.Sum != nil {
+		points += len(metric.Sum.DataPoints)
+	}
+	return points
+}
+
+// pointError - ошибка в содержимом точки, из-за которой она отклоняется.
+type pointError struct {
+	message string
+}
+
+func (e *pointError) Error() string {
+	return e.message
+}
+
+func pointErrorf(format string, args ...any) error {
+	return &pointError{message: fmt.Sprintf(format, args...)}
+}
+
+// sanitize приводит имена OpenTelemetry (http.server.duration) к виду, принятому для метрик.
+func sanitize(name string) string {
+	return strings.NewReplacer(".", "_", "-", "_", "/", "_").Replace(name)
+}
+
+// isRejected отделяет ошибки в содержимом конкретной точки от сбоев хранилища.
+func isRejected(err error) bool {
+	var pointErr *pointError
+	return errors.As(err, &pointErr) || service.IsRejected(err)
+}
@@ -0,0 +1,161 @@
+// Package otlp принимает метрики в формате OpenTelemetry (OTLP/HTTP, protobuf и JSON).
+// Поддерживается подмножество сообщений metrics/v1, нужное для Gauge и Sum;
+// точки остальных типов учитываются как отклоненные.
+package otlp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	TemporalityUnspecified Temporality = iota
+	TemporalityDelta
+	TemporalityCumulative
+)
+
+type Temporality int
+
+type ExportRequest struct {
+	ResourceMetrics []ResourceMetrics `json:"resourceMetrics"`
+}
+
+type ResourceMetrics struct {
+	Resource     Resource       `json:"resource"`
+	ScopeMetrics []ScopeMetrics `json:"scopeMetrics"`
+}
+
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+type ScopeMetrics struct {
+	Metrics []Metric `json:"metrics"`
+}
+
+type Metric struct {
+	Name  string `json:"name"`
+	Gauge *Gauge `json:"gauge"`
+	Sum   *Sum   `json:"sum"`
+
+	Histogram            *unsupportedData `json:"histogram"`
+	ExponentialHistogram *unsupportedData `json:"exponentialHistogram"`
+	Summary              *unsupportedData `json:"summary"`
+
+	// UnsupportedPoints - число точек в данных неподдерживаемых типов.
+	UnsupportedPoints int `json:"-"`
+}
+
+type Gauge struct {
+	DataPoints []NumberDataPoint `json:"dataPoints"`
+}
+
+type Sum struct {
+	DataPoints             []NumberDataPoint `json:"dataPoints"`
+	AggregationTemporality Temporality       `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type NumberDataPoint struct {
+	Attributes        []KeyValue `json:"attributes"`
+	StartTimeUnixNano Uint64     `json:"startTimeUnixNano"`
+	TimeUnixNano      Uint64     `json:"timeUnixNano"`
+	AsDouble          *float64   `json:"asDouble"`
+	AsInt             *Int64     `json:"asInt"`
+}
+
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+type AnyValue struct {
+	StringValue *string     `json:"stringValue"`
+	BoolValue   *bool       `json:"boolValue"`
+	IntValue    *Int64      `json:"intValue"`
+	DoubleValue *float64    `json:"doubleValue"`
+	ArrayValue  *ArrayValue `json:"arrayValue"`
+}
+
+type ArrayValue struct {
+	Values []AnyValue `json:"values"`
+}
+
+type unsupportedData struct {
+	DataPoints []json.RawMessage `json:"dataPoints"`
+}
+
+// String возвращает значение атрибута в виде значения метки. Для неподдерживаемых
+// типов (kvlist, bytes) второе значение равно false.
+func (v AnyValue) String() (string, bool) {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue, true
+	case v.BoolValue != nil:
+		return strconv.FormatBool(*v.BoolValue), true
+	case v.IntValue != nil:
+		return strconv.FormatInt(int64(*v.IntValue), 10), true
+	case v.DoubleValue != nil:
+		return strconv.FormatFloat(*v.DoubleValue, 'g', -1, 64), true
+	case v.ArrayValue != nil:
+		values := make([]string, 0, len(v.ArrayValue.Values))
+		for _, value := range v.ArrayValue.Values {
+			if s, ok := value.String(); ok {
+				values = append(values, s)
+			}
+		}
+		return "[" + strings.Join(values, ",") + "]", true
+	}
+	return "", false
+}
+
+// Int64 и Uint64 принимают в JSON как числа, так и строки: в OTLP JSON 64-битные
+// целые кодируются строками.
+type Int64 int64
+
+type Uint64 uint64
+
+func (i *Int64) UnmarshalJSON(data []byte) error {
+	v, err := strconv.ParseInt(strings.Trim(string(data), `"`), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid int64 %s", data)
+	}
+	*i = Int64(v)
+	return nil
+}
+
+func (u *Uint64) UnmarshalJSON(data []byte) error {
+	v, err := strconv.ParseUint(strings.Trim(string(data), `"`), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid uint64 %s", data)
+	}
+	*u = Uint64(v)
+	return nil
+}
+
+// UnmarshalJSON принимает temporality как числом, так и именем значения enum.
+func (t *Temporality) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "0", "AGGREGATION_TEMPORALITY_UNSPECIFIED":
+		*t = TemporalityUnspecified
+	case "1", "AGGREGATION_TEMPORALITY_DELTA":
+		*t = TemporalityDelta
+	case "2", "AGGREGATION_TEMPORALITY_CUMULATIVE":
+		*t = TemporalityCumulative
+	default:
+		return fmt.Errorf("invalid aggregation temporality %s", data)
+	}
+	return nil
+}
+
+// ExportResponse - ответ ExportMetricsServiceResponse. Пустой ответ означает полный успех.
+type ExportResponse struct {
+	PartialSuccess *PartialSuccess `json:"partialSuccess,omitempty"`
+}
+
+type PartialSuccess struct {
+	RejectedDataPoints int64  `json:"rejectedDataPoints,string"`
+	ErrorMessage       string `json:"errorMessage,omitempty"`
+}
@@ -0,0 +1,56 @@
+// Package protoutil помогает разбирать protobuf-сообщения без сгенерированного кода.
+package protoutil
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field - одно поле protobuf-сообщения. Для varint и fixed полей значение лежит в Num,
+// для length-delimited - в Bytes.
+type Field struct {
+	Number protowire.Number
+	Type   protowire.Type
+	Num    uint64
+	Bytes  []byte
+}
+
+// ParseFields вызывает fn для каждого поля сообщения. Поля с неожиданным типом
+// обработчикам следует пропускать так же, как неизвестные поля.
+func ParseFields(b []byte, fn func(f Field) error) error {
+	for len(b) > 0 {
+		number, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		f := Field{Number: number, Type: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.Num, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			f.Num, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			f.Num = uint64(v)
+		case protowire.BytesType:
+			f.Bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(number, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f Field) Is(number protowire.Number, typ protowire.Type) bool {
+	return f.Number == number && f.Type == typ
+}
@@ -0,0 +1,133 @@
+// Package ratelimit ограничивает частоту запросов каждого клиента алгоритмом token bucket.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/auth"
+)
+
+// sweepInterval - период, с которым удаляются корзины неактивных клиентов.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Limiter выдает каждому клиенту rate запросов в секунду с запасом burst. Лимитер
+// с rate <= 0 пропускает все запросы.
+type Limiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	return &Limiter{
+		rate:      rate,
+		burst:     float64(burst),
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Enabled сообщает, ограничивает ли лимитер запросы.
+func (l *Limiter) Enabled() bool {
+	return l.rate > 0
+}
+
+// Allow списывает токен из корзины клиента key. Если токенов нет, возвращает false
+// и время, через которое появится следующий токен.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if !l.Enabled() {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.updated = now
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	return math.Min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+}
+
+// sweep удаляет полные корзины: для таких клиентов новая корзина ничем не отличается.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Middleware отклоняет запросы сверх лимита с кодом 429 и заголовком Retry-After.
+// onThrottle вызывается для каждого отклоненного запроса.
+func (l *Limiter) Middleware(onThrottle func()) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !l.Enabled() {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := ClientKey(r)
+			allowed, wait := l.Allow(key)
+			if !allowed {
+				fmt.Printf("Rate limit exceeded for %s: %s %s\n", key, r.Method, r.URL.Path)
+				if onThrottle != nil {
+					onThrottle()
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientKey определяет клиента запроса: по имени API-токена, если запрос прошел
+// аутентификацию, иначе по IP-адресу.
+func ClientKey(r *http.Request) string {
+	if token := auth.FromContext(r.Context()); token != nil {
+		return "token:" + token.Name
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
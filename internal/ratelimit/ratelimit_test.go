@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/auth"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLimiter(rate float64, burst int) (*Limiter, *time.Time) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(rate, burst)
+	limiter.now = func() time.Time { return now }
+	limiter.lastSweep = now
+	return limiter, &now
+}
+
+func TestLimiterAllow(t *testing.T) {
+	limiter, now := newTestLimiter(2, 3)
+
+	for range 3 {
+		allowed, _ := limiter.Allow("a")
+		require.True(t, allowed)
+	}
+	allowed, wait := limiter.Allow("a")
+	require.False(t, allowed)
+	require.Equal(t, 500*time.Millisecond, wait)
+
+	allowed, _ = limiter.Allow("b")
+	require.True(t, allowed, "clients have separate buckets")
+
+	*now = now.Add(500 * time.Millisecond)
+	allowed, _ = limiter.Allow("a")
+	require.True(t, allowed)
+	allowed, _ = limiter.Allow("a")
+	require.False(t, allowed)
+
+	*now = now.Add(time.Hour)
+	for range 3 {
+		allowed, _ = limiter.Allow("a")
+		require.True(t, allowed, "burst is restored but not exceeded")
+	}
+	allowed, _ = limiter.Allow("a")
+	require.False(t, allowed)
+}
+
+func TestLimiterSweep(t *testing.T) {
+	limiter, now := newTestLimiter(1, 1)
+	limiter.Allow("a")
+	limiter.Allow("b")
+	require.Len(t, limiter.buckets, 2)
+
+	*now = now.Add(2 * sweepInterval)
+	limiter.Allow("c")
+	require.Len(t, limiter.buckets, 1)
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	limiter := NewLimiter(0, 0)
+	for range 100 {
+		allowed, _ := limiter.Allow("a")
+		require.True(t, allowed)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	limiter, _ := newTestLimiter(0.5, 1)
+	throttled := 0
+	handler := limiter.Middleware(func() { throttled++ })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(remoteAddr string, token *auth.Token) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/update/", nil)
+		request.RemoteAddr = remoteAddr
+		if token != nil {
+			request = request.WithContext(auth.NewContext(request.Context(), token))
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, request)
+		return rr
+	}
+
+	require.Equal(t, http.StatusOK, serve("10.0.0.1:1000", nil).Code)
+	rr := serve("10.0.0.1:2000", nil)
+	require.Equal(t, http.StatusTooManyRequests, rr.Code, "ports of one host share a bucket")
+	require.Equal(t, "2", rr.Header().Get("Retry-After"))
+	require.Equal(t, 1, throttled)
+
+	require.Equal(t, http.StatusOK, serve("10.0.0.2:1000", nil).Code)
+
+	agent := &auth.Token{Name: "agent"}
+	require.Equal(t, http.StatusOK, serve("10.0.0.1:3000", agent).Code, "authenticated clients are keyed by token")
+	require.Equal(t, http.StatusTooManyRequests, serve("10.0.0.3:1000", agent).Code)
+	require.Equal(t, 2, throttled)
+}
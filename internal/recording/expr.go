@@ -0,0 +1,405 @@
+package recording
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/prbllm/go-metrics/internal/model"
+)
+
+// Expr - разобранное выражение recording-правила.
+//
+// Грамматика:
+//
+//	expr     = term { ("+" | "-") term }
+//	term     = unary { ("*" | "/") unary }
+//	unary    = "-" unary | primary
+//	primary  = number | "(" expr ")" | func "(" selector ")" | selector
+//	selector = name [ "{" matcher { "," matcher } "}" ]
+//	func     = "sum" | "avg" | "min" | "max" | "count"
+//
+// Селектор выбирает ряды gauge и counter с именем name, подходящие под матчеры меток
+// (host="a", host!="a", host=~"a.*", host!~"a.*"). Вне агрегирующей функции селектор
+// должен выбирать ровно один ряд.
+type Expr struct {
+	source string
+	root   node
+}
+
+type node interface {
+	eval(series []*model.Metrics) (float64, error)
+}
+
+// Parse разбирает выражение.
+func Parse(source string) (*Expr, error) {
+	p := &parser{input: source}
+	p.next()
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, p.errorf("unexpected %q", p.tok.text)
+	}
+	return &Expr{source: source, root: root}, nil
+}
+
+// Eval вычисляет выражение над рядами series.
+func (e *Expr) Eval(series []*model.Metrics) (float64, error) {
+	return e.root.eval(series)
+}
+
+func (e *Expr) String() string {
+	return e.source
+}
+
+type numberNode float64
+
+func (n numberNode) eval([]*model.Metrics) (float64, error) {
+	return float64(n), nil
+}
+
+type negNode struct {
+	operand node
+}
+
+func (n negNode) eval(series []*model.Metrics) (float64, error) {
+	value, err := n.operand.eval(series)
+	return -value, err
+}
+
+type binaryNode struct {
+	op          byte
+	left, right node
+}
+
+func (n binaryNode) eval(series []*model.Metrics) (float64, error) {
+	left, err := n.left.eval(series)
+	if err != nil {
+		return 0, err
+	}
+	right, err := n.right.eval(series)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	default:
+		if right == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return left / right, nil
+	}
+}
+
+type selectorNode struct {
+	name     string
+	matchers []*model.LabelMatcher
+}
+
+func (n selectorNode) String() string {
+	parts := make([]string, 0, len(n.matchers))
+	for _, matcher := range n.matchers {
+		parts = append(parts, matcher.String())
+	}
+	if len(parts) == 0 {
+		return n.name
+	}
+	return n.name + "{" + strings.Join(parts, ",") + "}"
+}
+
+func (n selectorNode) values(series []*model.Metrics) []float64 {
+	values := []float64{}
+	for _, metric := range series {
+		if metric.ID != n.name || !model.MatchLabels(metric.Labels, n.matchers) {
+			continue
+		}
+		switch {
+		case metric.MType == model.Gauge && metric.Value != nil:
+			values = append(values, *metric.Value)
+		case metric.MType == model.Counter && metric.Delta != nil:
+			values = append(values, float64(*metric.Delta))
+		}
+	}
+	return values
+}
+
+func (n selectorNode) eval(series []*model.Metrics) (float64, error) {
+	values := n.values(series)
+	switch len(values) {
+	case 0:
+		return 0, fmt.Errorf("%s: no series found", n)
+	case 1:
+		return values[0], nil
+	default:
+		return 0, fmt.Errorf("%s: %d series found, use an aggregation function", n, len(values))
+	}
+}
+
+type aggregateNode struct {
+	function string
+	selector selectorNode
+}
+
+func (n aggregateNode) eval(series []*model.Metrics) (float64, error) {
+	values := n.selector.values(series)
+	if n.function == "count" {
+		return float64(len(values)), nil
+	}
+	if n.function == "sum" && len(values) == 0 {
+		return 0, nil
+	}
+	if len(values) == 0 {
+		return 0, fmt.Errorf("%s(%s): no series found", n.function, n.selector)
+	}
+
+	result := values[0]
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+		switch {
+		case n.function == "min" && value < result:
+			result = value
+		case n.function == "max" && value > result:
+			result = value
+		}
+	}
+	switch n.function {
+	case "sum":
+		return sum, nil
+	case "avg":
+		return sum / float64(len(values)), nil
+	}
+	return result, nil
+}
+
+var aggregateFunctions = map[string]bool{"sum": true, "avg": true, "min": true, "max": true, "count": true}
+
+const (
+	tokenEOF = iota
+	tokenNumber
+	tokenIdent
+	tokenOp
+	tokenMatchers
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+type parser struct {
+	input string
+	pos   int
+	tok   token
+	err   error
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("position %d: %s", p.tok.pos+1, fmt.Sprintf(format, args...))
+}
+
+// next читает следующий токен. Ошибки лексера откладываются до parsePrimary.
+func (p *parser) next() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.input) {
+		p.tok = token{kind: tokenEOF, pos: start}
+		return
+	}
+
+	c := p.input[p.pos]
+	switch {
+	case c >= '0' && c <= '9' || c == '.':
+		for p.pos < len(p.input) && (isDigit(p.input[p.pos]) || p.input[p.pos] == '.' || p.input[p.pos] == 'e' || p.input[p.pos] == 'E' ||
+			(p.input[p.pos] == '-' || p.input[p.pos] == '+') && (p.input[p.pos-1] == 'e' || p.input[p.pos-1] == 'E')) {
+			p.pos++
+		}
+		p.tok = token{kind: tokenNumber, text: p.input[start:p.pos], pos: start}
+	case isNameStart(c):
+		for p.pos < len(p.input) && (isNameStart(p.input[p.pos]) || isDigit(p.input[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokenIdent, text: p.input[start:p.pos], pos: start}
+	case c == '{':
+		inQuotes := false
+		for p.pos++; p.pos < len(p.input); p.pos++ {
+			switch {
+			case p.input[p.pos] == '\\' && inQuotes:
+				p.pos++
+			case p.input[p.pos] == '"':
+				inQuotes = !inQuotes
+			case p.input[p.pos] == '}' && !inQuotes:
+				p.pos++
+				p.tok = token{kind: tokenMatchers, text: p.input[start+1 : p.pos-1], pos: start}
+				return
+			}
+		}
+		p.tok = token{kind: tokenMatchers, pos: start}
+		p.err = fmt.Errorf("position %d: unterminated label matchers", start+1)
+	default:
+		p.pos++
+		p.tok = token{kind: tokenOp, text: string(c), pos: start}
+	}
+}
+
+func (p *parser) parseExpr() (node, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokenOp && (p.tok.text == "+" || p.tok.text == "-") {
+		op := p.tok.text[0]
+		p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseTerm() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokenOp && (p.tok.text == "*" || p.tok.text == "/") {
+		op := p.tok.text[0]
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.tok.kind == tokenOp && p.tok.text == "-" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return negNode{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	switch p.tok.kind {
+	case tokenNumber:
+		value, err := strconv.ParseFloat(p.tok.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", p.tok.text)
+		}
+		p.next()
+		return numberNode(value), nil
+	case tokenIdent:
+		name := p.tok.text
+		p.next()
+		if p.tok.kind == tokenOp && p.tok.text == "(" {
+			if !aggregateFunctions[name] {
+				return nil, p.errorf("unknown function %q", name)
+			}
+			p.next()
+			if p.tok.kind != tokenIdent {
+				return nil, p.errorf("%s() expects a metric selector", name)
+			}
+			selectorName := p.tok.text
+			p.next()
+			selector, err := p.parseSelector(selectorName)
+			if err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokenOp || p.tok.text != ")" {
+				return nil, p.errorf("expected \")\"")
+			}
+			p.next()
+			return aggregateNode{function: name, selector: selector}, nil
+		}
+		return p.parseSelector(name)
+	case tokenOp:
+		if p.tok.text == "(" {
+			p.next()
+			inner, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokenOp || p.tok.text != ")" {
+				return nil, p.errorf("expected \")\"")
+			}
+			p.next()
+			return inner, nil
+		}
+		return nil, p.errorf("unexpected %q", p.tok.text)
+	case tokenEOF:
+		return nil, p.errorf("unexpected end of expression")
+	}
+	return nil, p.errorf("unexpected label matchers")
+}
+
+func (p *parser) parseSelector(name string) (selectorNode, error) {
+	selector := selectorNode{name: name}
+	if p.err != nil {
+		return selector, p.err
+	}
+	if p.tok.kind != tokenMatchers {
+		return selector, nil
+	}
+	for _, part := range splitMatchers(p.tok.text) {
+		matcher, err := model.ParseLabelMatcher(part)
+		if err != nil {
+			return selector, p.errorf("%v", err)
+		}
+		selector.matchers = append(selector.matchers, matcher)
+	}
+	p.next()
+	return selector, nil
+}
+
+// splitMatchers делит содержимое {...} по запятым вне кавычек.
+func splitMatchers(s string) []string {
+	var parts []string
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && inQuotes:
+			i++
+		case s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == ',' && !inQuotes:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		parts = append(parts, last)
+	}
+	return parts
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':'
+}
@@ -0,0 +1,165 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/repository"
+	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func gauge(id string, value float64, labels map[string]string) *model.Metrics {
+	return &model.Metrics{ID: id, MType: model.Gauge, Value: &value, Labels: labels}
+}
+
+func counter(id string, delta int64, labels map[string]string) *model.Metrics {
+	return &model.Metrics{ID: id, MType: model.Counter, Delta: &delta, Labels: labels}
+}
+
+func TestExprEval(t *testing.T) {
+	series := []*model.Metrics{
+		gauge("HeapAlloc", 25, nil),
+		gauge("HeapSys", 100, nil),
+		counter("requests", 10, map[string]string{"host": "a", "code": "200"}),
+		counter("requests", 30, map[string]string{"host": "b", "code": "200"}),
+		counter("requests", 5, map[string]string{"host": "b", "code": "500"}),
+		gauge("zero", 0, nil),
+	}
+
+	tests := []struct {
+		expr     string
+		expected float64
+		wantErr  bool
+	}{
+		{expr: "HeapAlloc / HeapSys", expected: 0.25},
+		{expr: "1 + 2 * 3", expected: 7},
+		{expr: "(1 + 2) * 3", expected: 9},
+		{expr: "-HeapAlloc + 1e2", expected: 75},
+		{expr: "sum(requests)", expected: 45},
+		{expr: `sum(requests{code="500"}) / sum(requests) * 100`, expected: 100.0 / 9},
+		{expr: `avg(requests{code!="500"})`, expected: 20},
+		{expr: "min(requests) + max(requests)", expected: 35},
+		{expr: `count(requests{host=~"a|b"})`, expected: 3},
+		{expr: "sum(missing)", expected: 0},
+		{expr: `requests{host="a"}`, expected: 10},
+		{expr: "requests", wantErr: true},
+		{expr: "missing", wantErr: true},
+		{expr: "HeapAlloc / zero", wantErr: true},
+		{expr: "avg(missing)", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			expr, err := Parse(test.expr)
+			require.NoError(t, err)
+			value, err := expr.Eval(series)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.InDelta(t, test.expected, value, 1e-9)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, source := range []string{"", "1 +", "(1 + 2", "HeapAlloc HeapSys", "rate(requests)", "sum(1)", `requests{host="a"`, "requests{host}", "2 $ 3"} {
+		_, err := Parse(source)
+		require.Error(t, err, "expression %q", source)
+	}
+}
+
+// countingRepository считает чтения всех рядов.
+type countingRepository struct {
+	repository.MetricsRepository
+	reads int
+}
+
+func (r *countingRepository) GetAllMetrics() []*model.Metrics {
+	r.reads++
+	return r.MetricsRepository.GetAllMetrics()
+}
+
+func TestEvaluator(t *testing.T) {
+	storage := &countingRepository{MetricsRepository: repository.NewMemStorage()}
+	require.NoError(t, storage.UpdateMetric(gauge("HeapAlloc", 25, nil)))
+	require.NoError(t, storage.UpdateMetric(gauge("HeapSys", 100, nil)))
+
+	config := &Config{Rules: []*Rule{
+		{Name: "heap_usage_ratio", Expr: "HeapAlloc / HeapSys", Labels: map[string]string{"source": "recording"}},
+		{Name: "heap_usage_percent", Expr: `heap_usage_ratio{source="recording"} * 100`},
+		{Name: "broken", Expr: "HeapAlloc / missing"},
+	}}
+	require.NoError(t, config.Validate(service.DefaultNamingPolicy()))
+
+	evaluator := NewEvaluator(service.NewMetricsService(storage).ForTenant("team-a"), config.Rules)
+	evaluator.Evaluate()
+	require.Equal(t, 1, storage.reads)
+
+	metric, err := storage.GetMetric(&model.Metrics{ID: "heap_usage_ratio", MType: model.Gauge, Labels: map[string]string{"source": "recording"}})
+	require.NoError(t, err)
+	require.Equal(t, 0.25, *metric.Value)
+
+	metric, err = storage.GetMetric(&model.Metrics{ID: "heap_usage_percent", MType: model.Gauge})
+	require.NoError(t, err)
+	require.Equal(t, 25.0, *metric.Value)
+
+	_, err = storage.GetMetric(&model.Metrics{ID: "broken", MType: model.Gauge})
+	require.Error(t, err)
+
+	require.NoError(t, storage.UpdateMetric(gauge("HeapAlloc", 50, nil)))
+	evaluator.Evaluate()
+	metric, err = storage.GetMetric(&model.Metrics{ID: "heap_usage_percent", MType: model.Gauge})
+	require.NoError(t, err)
+	require.Equal(t, 50.0, *metric.Value)
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.json")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"rules":[{"name":"heap_usage_ratio","expr":"HeapAlloc / HeapSys"}]}`), 0o600))
+	config, err := LoadConfig(path, service.DefaultNamingPolicy())
+	require.NoError(t, err)
+	require.Len(t, config.Rules, 1)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"rules":[{"name":"x","expr":"1 +"}]}`), 0o600))
+	_, err = LoadConfig(path, service.DefaultNamingPolicy())
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"rules":[{"name":"x","expr":"1"},{"name":"x","expr":"2"}]}`), 0o600))
+	_, err = LoadConfig(path, service.DefaultNamingPolicy())
+	require.Error(t, err)
+}
+
+func TestRuleValidateNaming(t *testing.T) {
+	sanitizing, err := service.NewNamingPolicy(service.DefaultAllowedNameChars, service.DefaultMaxNameLength, nil, true)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		rule    *Rule
+		policy  *service.NamingPolicy
+		wantErr bool
+	}{
+		{name: "valid", rule: &Rule{Name: "heap_usage_ratio", Expr: "1", Labels: map[string]string{"source": "recording"}}, policy: service.DefaultNamingPolicy()},
+		{name: "invalid name", rule: &Rule{Name: "heap.usage", Expr: "1"}, policy: service.DefaultNamingPolicy(), wantErr: true},
+		{name: "name renamed by sanitizing", rule: &Rule{Name: "heap.usage", Expr: "1"}, policy: sanitizing, wantErr: true},
+		{name: "reserved prefix", rule: &Rule{Name: model.SelfMetricPrefix + "ratio", Expr: "1"}, policy: service.DefaultNamingPolicy(), wantErr: true},
+		{name: "invalid label", rule: &Rule{Name: "ratio", Expr: "1", Labels: map[string]string{"host.name": "a"}}, policy: sanitizing, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.rule.Validate(test.policy)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
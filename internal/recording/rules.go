@@ -0,0 +1,159 @@
+// Package recording периодически вычисляет recording-правила - выражения над
+// сохраненными метриками - и сохраняет результат как gauge под заданным именем.
+package recording
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/service"
+)
+
+// Config - содержимое файла recording-правил.
+type Config struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// Rule сохраняет значение Expr как gauge Name с метками Labels.
+type Rule struct {
+	Name   string            `json:"name"`
+	Expr   string            `json:"expr"`
+	Labels map[string]string `json:"labels"`
+
+	expr *Expr
+}
+
+// LoadConfig читает файл правил и проверяет его по политике имен policy.
+func LoadConfig(path string, policy *service.NamingPolicy) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid recording rules file %s: %w", path, err)
+	}
+	if err := config.Validate(policy); err != nil {
+		return nil, fmt.Errorf("invalid recording rules file %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+// Validate проверяет правила. Имена и метки результатов должны уже удовлетворять
+// policy: правило, которое сервер переименовал бы при записи, отклоняется с подсказкой.
+func (c *Config) Validate(policy *service.NamingPolicy) error {
+	seen := make(map[string]bool, len(c.Rules))
+	for i, rule := range c.Rules {
+		if err := rule.Validate(policy); err != nil {
+			return fmt.Errorf("rule #%d: %w", i+1, err)
+		}
+		key := rule.Name + model.FormatLabels(rule.Labels)
+		if seen[key] {
+			return fmt.Errorf("rule #%d: duplicate series %s", i+1, key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// Validate проверяет имя и метки правила по policy и разбирает его выражение.
+func (r *Rule) Validate(policy *service.NamingPolicy) error {
+	if r.Name == "" {
+		return fmt.Errorf("name cannot be empty")
+	}
+	name, err := policy.NormalizeName(r.Name)
+	if err != nil {
+		return err
+	}
+	if name != r.Name {
+		return fmt.Errorf("metric name %q is not valid, use %q", r.Name, name)
+	}
+	if err := policy.CheckReserved(r.Name); err != nil {
+		return err
+	}
+	labels, err := policy.NormalizeLabels(model.Gauge, r.Labels)
+	if err != nil {
+		return fmt.Errorf("rule %s: %w", r.Name, err)
+	}
+	if model.FormatLabels(labels) != model.FormatLabels(r.Labels) {
+		return fmt.Errorf("labels %s of %s are not valid, use %s", model.FormatLabels(r.Labels), r.Name, model.FormatLabels(labels))
+	}
+
+	expr, err := Parse(r.Expr)
+	if err != nil {
+		return fmt.Errorf("rule %s: invalid expression: %w", r.Name, err)
+	}
+	r.expr = expr
+	return nil
+}
+
+// Evaluator вычисляет правила по порядку, поэтому правило может ссылаться на
+// результат предыдущего. Правила читают и пишут только пространство по умолчанию.
+type Evaluator struct {
+	service service.Service
+	rules   []*Rule
+}
+
+// NewEvaluator создает вычислитель. Правила должны быть проверены через Validate.
+func NewEvaluator(metricsService service.Service, rules []*Rule) *Evaluator {
+	return &Evaluator{service: metricsService.ForTenant(""), rules: rules}
+}
+
+// Run вычисляет правила каждые interval, пока не будет отменен ctx.
+func (e *Evaluator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.Evaluate()
+		}
+	}
+}
+
+// Evaluate один раз вычисляет все правила. Ряды читаются один раз, а результаты
+// правил добавляются к ним, чтобы следующие правила видели новые значения. Правило,
+// которое не удалось вычислить (нет рядов, деление на ноль), пропускается, а его
+// прежнее значение остается.
+func (e *Evaluator) Evaluate() {
+	series, err := e.service.GetAllMetrics()
+	if err != nil {
+		fmt.Printf("Error reading metrics for recording rules: %v\n", err)
+		return
+	}
+	for _, rule := range e.rules {
+		value, err := rule.expr.Eval(series)
+		if err == nil && (math.IsNaN(value) || math.IsInf(value, 0)) {
+			err = fmt.Errorf("result %g is not finite", value)
+		}
+		if err != nil {
+			fmt.Printf("Error evaluating recording rule %s (%s): %v\n", rule.Name, rule.expr, err)
+			continue
+		}
+
+		saved, err := e.service.SaveMetric(&model.Metrics{ID: rule.Name, MType: model.Gauge, Value: &value, Labels: rule.Labels})
+		if err != nil {
+			fmt.Printf("Error saving recording rule %s: %v\n", rule.Name, err)
+			continue
+		}
+		series = replaceSeries(series, saved)
+	}
+}
+
+// replaceSeries заменяет в series ряд с тем же ключом, что у metric, или добавляет его.
+func replaceSeries(series []*model.Metrics, metric *model.Metrics) []*model.Metrics {
+	for i, existing := range series {
+		if existing.MType == metric.MType && existing.FullID() == metric.FullID() {
+			series[i] = metric
+			return series
+		}
+	}
+	return append(series, metric)
+}
@@ -0,0 +1,105 @@
+// Package remotewrite принимает данные по протоколу Prometheus remote_write:
+// сжатые snappy сообщения WriteRequest в protobuf-кодировке.
+package remotewrite
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/prbllm/go-metrics/internal/protoutil"
+)
+
+// MetricNameLabel - метка, в которой Prometheus передает имя метрики.
+const MetricNameLabel = "__name__"
+
+// MaxDecodedSize ограничивает размер распакованного запроса. snappy выделяет память
+// по длине из заголовка блока, поэтому ее нужно проверить до распаковки.
+const MaxDecodedSize = 32 << 20
+
+type WriteRequest struct {
+	Timeseries []TimeSeries
+}
+
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+// Decode распаковывает snappy (блочный формат) и разбирает WriteRequest.
+// Exemplars, native histograms и metadata пропускаются.
+func Decode(compressed []byte) (*WriteRequest, error) {
+	size, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snappy data: %w", err)
+	}
+	if size > MaxDecodedSize {
+		return nil, fmt.Errorf("decoded request size %d exceeds %d bytes", size, MaxDecodedSize)
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snappy data: %w", err)
+	}
+
+	var request WriteRequest
+	err = protoutil.ParseFields(data, func(f protoutil.Field) error {
+		if !f.Is(1, protowire.BytesType) {
+			return nil
+		}
+		series, err := parseTimeSeries(f.Bytes)
+		request.Timeseries = append(request.Timeseries, series)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid WriteRequest: %w", err)
+	}
+	return &request, nil
+}
+
+func parseTimeSeries(b []byte) (TimeSeries, error) {
+	var series TimeSeries
+	err := protoutil.ParseFields(b, func(f protoutil.Field) error {
+		switch {
+		case f.Is(1, protowire.BytesType):
+			var label Label
+			err := protoutil.ParseFields(f.Bytes, func(f protoutil.Field) error {
+				switch {
+				case f.Is(1, protowire.BytesType):
+					label.Name = string(f.Bytes)
+				case f.Is(2, protowire.BytesType):
+					label.Value = string(f.Bytes)
+				}
+				return nil
+			})
+			series.Labels = append(series.Labels, label)
+			return err
+		case f.Is(2, protowire.BytesType):
+			var sample Sample
+			err := protoutil.ParseFields(f.Bytes, func(f protoutil.Field) error {
+				switch {
+				case f.Is(1, protowire.Fixed64Type):
+					sample.Value = math.Float64frombits(f.Num)
+				case f.Is(2, protowire.VarintType):
+					sample.Timestamp = int64(f.Num)
+				}
+				return nil
+			})
+			series.Samples = append(series.Samples, sample)
+			return err
+		}
+		return nil
+	})
+	return series, err
+}
@@ -0,0 +1,136 @@
+package remotewrite
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/service"
+)
+
+// Receiver сохраняет последний по времени sample каждого ряда как gauge. Метка __name__
+// становится ID метрики, остальные метки сохраняются как есть. Sample старше уже
+// сохраненного для ряда игнорируется. Stale-маркеры (NaN) пропускаются. Время
+// последнего sample рядов, которые не обновлялись SeriesTTL, забывается.
+type Receiver struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	latest    map[string]*seriesState
+	lastPrune time.Time
+}
+
+// SeriesTTL - время, после которого состояние ряда без новых sample удаляется.
+const SeriesTTL = time.Hour
+
+type seriesState struct {
+	timestamp int64
+	lastSeen  time.Time
+}
+
+func NewReceiver() *Receiver {
+	return &Receiver{
+		now:       time.Now,
+		latest:    make(map[string]*seriesState),
+		lastPrune: time.Now(),
+	}
+}
+
+// Result описывает результат обработки WriteRequest.
+type Result struct {
+	Stored    int
+	Rejected  int
+	LastError error
+}
+
+// Write сохраняет ряды запроса через tenantService - сервис арендатора tenant. Ряды
+// с ошибками не прерывают обработку и учитываются в Result; ошибка возвращается только
+// при сбое хранилища.
+func (r *Receiver) Write(tenantService service.Service, tenant string, request *WriteRequest) (Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune()
+
+	var result Result
+	for _, series := range request.Timeseries {
+		metric, timestamp, ok, err := r.latestSample(series)
+		if err != nil {
+			result.Rejected++
+			result.LastError = err
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		key := tenant + "/" + metric.FullID()
+		if last, seen := r.latest[key]; seen && timestamp < last.timestamp {
+			continue
+		}
+		if _, err := tenantService.SaveMetric(metric); err != nil {
+			if !service.IsRejected(err) {
+				return result, err
+			}
+			result.Rejected++
+			result.LastError = fmt.Errorf("series %s: %w", key, err)
+			continue
+		}
+		r.latest[key] = &seriesState{timestamp: timestamp, lastSeen: r.now()}
+		result.Stored++
+	}
+	return result, nil
+}
+
+// prune удаляет состояние рядов, которые не обновлялись SeriesTTL. Проверка идет
+// не чаще раза в SeriesTTL, чтобы не обходить все ряды на каждом запросе.
+func (r *Receiver) prune() {
+	now := r.now()
+	if now.Sub(r.lastPrune) < SeriesTTL {
+		return
+	}
+	r.lastPrune = now
+	for key, state := range r.latest {
+		if now.Sub(state.lastSeen) >= SeriesTTL {
+			delete(r.latest, key)
+		}
+	}
+}
+
+// latestSample выбирает sample с наибольшим timestamp. ok равно false, если в ряду
+// нет пригодных значений.
+func (r *Receiver) latestSample(series TimeSeries) (*model.Metrics, int64, bool, error) {
+	metric := &model.Metrics{MType: model.Gauge}
+	for _, label := range series.Labels {
+		if label.Name == MetricNameLabel {
+			metric.ID = label.Value
+			continue
+		}
+		if label.Value == "" {
+			continue
+		}
+		if metric.Labels == nil {
+			metric.Labels = make(map[string]string)
+		}
+		metric.Labels[label.Name] = label.Value
+	}
+	if metric.ID == "" {
+		return nil, 0, false, fmt.Errorf("series %s: missing %s label", model.FormatLabels(metric.Labels), MetricNameLabel)
+	}
+
+	found := false
+	var timestamp int64
+	for _, sample := range series.Samples {
+		if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			continue
+		}
+		if !found || sample.Timestamp >= timestamp {
+			value := sample.Value
+			metric.Value = &value
+			timestamp = sample.Timestamp
+			found = true
+		}
+	}
+	return metric, timestamp, found, nil
+}
@@ -0,0 +1,132 @@
+package remotewrite
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/repository"
+	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// encode собирает сжатый WriteRequest так же, как это делает Prometheus.
+func encode(series ...TimeSeries) []byte {
+	var request []byte
+	for _, s := range series {
+		var ts []byte
+		for _, label := range s.Labels {
+			var l []byte
+			l = protowire.AppendTag(l, 1, protowire.BytesType)
+			l = protowire.AppendString(l, label.Name)
+			l = protowire.AppendTag(l, 2, protowire.BytesType)
+			l = protowire.AppendString(l, label.Value)
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, l)
+		}
+		for _, sample := range s.Samples {
+			var b []byte
+			b = protowire.AppendTag(b, 1, protowire.Fixed64Type)
+			b = protowire.AppendFixed64(b, math.Float64bits(sample.Value))
+			b = protowire.AppendTag(b, 2, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(sample.Timestamp))
+			ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, b)
+		}
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, ts)
+	}
+	return snappy.Encode(nil, request)
+}
+
+func series(name string, host string, samples ...Sample) TimeSeries {
+	return TimeSeries{
+		Labels:  []Label{{Name: MetricNameLabel, Value: name}, {Name: "host", Value: host}},
+		Samples: samples,
+	}
+}
+
+func TestDecode(t *testing.T) {
+	request, err := Decode(encode(series("up", "a", Sample{Value: 1, Timestamp: 1000}, Sample{Value: 0, Timestamp: 2000})))
+	require.NoError(t, err)
+	require.Equal(t, []TimeSeries{series("up", "a", Sample{Value: 1, Timestamp: 1000}, Sample{Value: 0, Timestamp: 2000})}, request.Timeseries)
+
+	_, err = Decode([]byte("not snappy"))
+	require.Error(t, err)
+
+	// Заголовок объявляет 4 GiB данных при теле из нескольких байт.
+	_, err = Decode(append(protowire.AppendVarint(nil, 1<<32-1), 0x00))
+	require.ErrorContains(t, err, "exceeds")
+
+	_, err = Decode(snappy.Encode(nil, []byte{0x0a, 0xff}))
+	require.Error(t, err)
+}
+
+func TestReceiverWrite(t *testing.T) {
+	storage := repository.NewMemStorage()
+	metricsService := service.NewMetricsService(storage)
+	receiver := NewReceiver()
+
+	value := func(name, host string) float64 {
+		metric, err := storage.GetMetric(&model.Metrics{MType: model.Gauge, ID: name, Labels: map[string]string{"host": host}})
+		require.NoError(t, err)
+		return *metric.Value
+	}
+
+	write := func(series ...TimeSeries) Result {
+		request, err := Decode(encode(series...))
+		require.NoError(t, err)
+		result, err := receiver.Write(metricsService, "", request)
+		require.NoError(t, err)
+		return result
+	}
+
+	result := write(
+		series("cpu_usage", "a", Sample{Value: 0.7, Timestamp: 2000}, Sample{Value: 0.5, Timestamp: 1000}),
+		series("cpu_usage", "b", Sample{Value: 0.2, Timestamp: 1000}),
+	)
+	require.Equal(t, Result{Stored: 2}, result)
+	require.Equal(t, 0.7, value("cpu_usage", "a"))
+	require.Equal(t, 0.2, value("cpu_usage", "b"))
+
+	write(series("cpu_usage", "a", Sample{Value: 0.1, Timestamp: 1500}))
+	require.Equal(t, 0.7, value("cpu_usage", "a"), "Older sample must not overwrite the latest one")
+
+	write(series("cpu_usage", "a", Sample{Value: math.Float64frombits(0x7ff0000000000002), Timestamp: 3000}))
+	require.Equal(t, 0.7, value("cpu_usage", "a"), "Stale marker must be skipped")
+
+	result = write(
+		TimeSeries{Labels: []Label{{Name: "host", Value: "a"}}, Samples: []Sample{{Value: 1}}},
+		series("mem_free", "a", Sample{Value: 42, Timestamp: 1000}),
+	)
+	require.Equal(t, 1, result.Stored)
+	require.Equal(t, 1, result.Rejected)
+	require.Error(t, result.LastError)
+	require.Equal(t, 42.0, value("mem_free", "a"))
+}
+
+func TestReceiverExpiresIdleSeries(t *testing.T) {
+	metricsService := service.NewMetricsService(repository.NewMemStorage())
+	receiver := NewReceiver()
+	now := receiver.lastPrune
+	receiver.now = func() time.Time { return now }
+
+	write := func(name string, timestamp int64) {
+		request, err := Decode(encode(series(name, "a", Sample{Value: 1, Timestamp: timestamp})))
+		require.NoError(t, err)
+		_, err = receiver.Write(metricsService, "", request)
+		require.NoError(t, err)
+	}
+	write("old", 1000)
+	now = now.Add(SeriesTTL / 2)
+	write("active", 1000)
+	now = now.Add(SeriesTTL / 2)
+	write("active", 2000)
+
+	require.NotContains(t, receiver.latest, `/old{host="a"}`)
+	require.Contains(t, receiver.latest, `/active{host="a"}`)
+	require.Len(t, receiver.latest, 1)
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/prbllm/go-metrics/internal/model"
+)
+
+// MetricsSource отдает метрики, которые не хранятся в репозитории, но должны
+// попадать в выдачу вместе с клиентскими, например метрики самого сервера.
+type MetricsSource interface {
+	Collect() []*model.Metrics
+}
+
+// CompositeStorage добавляет к репозиторию метрики из дополнительных источников.
+// Запись по-прежнему идет только в основной репозиторий. Источники опрашиваются при чтении
+// отдельной метрики только для имен с префиксом model.SelfMetricPrefix. Метрики источников
+// принадлежат пространству арендатора по умолчанию.
+type CompositeStorage struct {
+	MetricsRepository
+
+	sources []MetricsSource
+}
+
+func NewCompositeStorage(repository MetricsRepository, sources ...MetricsSource) *CompositeStorage {
+	return &CompositeStorage{MetricsRepository: repository, sources: sources}
+}
+
+func (s *CompositeStorage) GetMetric(metric *model.Metrics) (*model.Metrics, error) {
+	if metric != nil && metric.Tenant == "" && strings.HasPrefix(metric.ID, model.SelfMetricPrefix) {
+		fullID := metric.FullID()
+		for _, source := range s.sources {
+			for _, m := range source.Collect() {
+				if m.MType == metric.MType && m.FullID() == fullID {
+					return m, nil
+				}
+			}
+		}
+	}
+	return s.MetricsRepository.GetMetric(metric)
+}
+
+func (s *CompositeStorage) GetAllMetrics() []*model.Metrics {
+	metrics := s.MetricsRepository.GetAllMetrics()
+	for _, source := range s.sources {
+		metrics = append(metrics, source.Collect()...)
+	}
+	return metrics
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+type staticSource []*model.Metrics
+
+func (s staticSource) Collect() []*model.Metrics {
+	return s
+}
+
+func TestCompositeStorage(t *testing.T) {
+	mem := NewMemStorage()
+	require.NoError(t, mem.UpdateMetric(gauge("client", nil)))
+	storage := NewCompositeStorage(mem, staticSource{gauge(model.SelfMetricPrefix+"uptime_seconds", nil)})
+
+	require.Len(t, storage.GetAllMetrics(), 2)
+
+	metric, err := storage.GetMetric(&model.Metrics{ID: model.SelfMetricPrefix + "uptime_seconds", MType: model.Gauge})
+	require.NoError(t, err)
+	require.Equal(t, 1.0, *metric.Value)
+
+	_, err = storage.GetMetric(&model.Metrics{ID: "client", MType: model.Gauge})
+	require.NoError(t, err)
+
+	_, err = storage.GetMetric(&model.Metrics{ID: model.SelfMetricPrefix + "missing", MType: model.Gauge})
+	require.ErrorIs(t, err, ErrMetricNotFound)
+}
@@ -0,0 +1,291 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+)
+
+const (
+	snapshotFile = "snapshot.json"
+	walFile      = "wal.log"
+)
+
+// snapshot - содержимое файла снимка. Seq - номер последней записи журнала, вошедшей
+// в снимок: при восстановлении более ранние записи пропускаются.
+type snapshot struct {
+	Seq     uint64           `json:"seq"`
+	Metrics []snapshotMetric `json:"metrics"`
+}
+
+type snapshotMetric struct {
+	Tenant string         `json:"tenant,omitempty"`
+	Metric *model.Metrics `json:"metric"`
+}
+
+// DurableStorage сохраняет изменения репозитория на диск. Каждое обновление и удаление
+// дописывается в журнал упреждающей записи до того, как применяется и подтверждается
+// клиенту. Snapshot записывает полное состояние и очищает журнал. При создании
+// состояние восстанавливается из последнего снимка и журнала поверх него, поэтому
+// после падения теряются только оборванные записи в конце журнала.
+type DurableStorage struct {
+	MetricsRepository
+
+	dir string
+
+	mu  sync.Mutex
+	wal *wal
+	seq uint64
+	// walErr и snapshotErr - ошибки последней записи в журнал и последнего снимка.
+	// Пока они не сброшены успешной записью, Ping сообщает, что хранилище не готово.
+	walErr      error
+	snapshotErr error
+}
+
+// NewDurableStorage восстанавливает repository из каталога dir и начинает журналировать
+// изменения. repository должен быть пустым. При sync каждая запись журнала сбрасывается
+// на диск, иначе журнал переживает падение процесса, но не операционной системы.
+func NewDurableStorage(repository MetricsRepository, dir string, sync bool) (*DurableStorage, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	s := &DurableStorage{MetricsRepository: repository, dir: dir}
+
+	restored, err := s.loadSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	wal, records, err := openWAL(filepath.Join(dir, walFile), sync)
+	if err != nil {
+		return nil, err
+	}
+	replayed := 0
+	for _, record := range records {
+		if record.Seq <= s.seq {
+			continue
+		}
+		s.seq = record.Seq
+		if err := s.apply(record); err != nil {
+			fmt.Printf("Skipping write-ahead log record %d: %v\n", record.Seq, err)
+			continue
+		}
+		replayed++
+	}
+	s.wal = wal
+	fmt.Printf("Restored %d series from snapshot and %d write-ahead log records from %s\n", restored, replayed, dir)
+	return s, nil
+}
+
+func (s *DurableStorage) loadSnapshot() (int, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshotFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return 0, fmt.Errorf("invalid snapshot %s: %w", filepath.Join(s.dir, snapshotFile), err)
+	}
+	for _, entry := range snap.Metrics {
+		entry.Metric.Tenant = entry.Tenant
+		if err := s.MetricsRepository.UpdateMetric(entry.Metric); err != nil {
+			return 0, fmt.Errorf("restore %s: %w", entry.Metric.FullID(), err)
+		}
+	}
+	s.seq = snap.Seq
+	return len(snap.Metrics), nil
+}
+
+func (s *DurableStorage) apply(record walRecord) error {
+	switch record.Op {
+	case walOpBatch:
+		metrics := make([]*model.Metrics, len(record.Batch))
+		for i, entry := range record.Batch {
+			entry.Metric.Tenant = entry.Tenant
+			metrics[i] = entry.Metric
+		}
+		return s.MetricsRepository.UpdateMetrics(metrics)
+	case walOpDelete:
+		record.Metric.Tenant = record.Tenant
+		return s.MetricsRepository.DeleteMetric(record.Metric)
+	default:
+		record.Metric.Tenant = record.Tenant
+		return s.MetricsRepository.UpdateMetric(record.Metric)
+	}
+}
+
+func (s *DurableStorage) UpdateMetric(metric *model.Metrics) error {
+	return s.log(walOpUpdate, metric)
+}
+
+// UpdateMetrics записывает пакет в журнал одной записью и применяет его целиком.
+func (s *DurableStorage) UpdateMetrics(metrics []*model.Metrics) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+	batch := make([]snapshotMetric, len(metrics))
+	for i, metric := range metrics {
+		batch[i] = snapshotMetric{Tenant: metric.Tenant, Metric: metric}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.append(walRecord{Seq: s.seq + 1, Op: walOpBatch, Batch: batch})
+}
+
+func (s *DurableStorage) DeleteMetric(metric *model.Metrics) error {
+	if metric == nil {
+		return fmt.Errorf("metric is nil")
+	}
+	return s.log(walOpDelete, metric)
+}
+
+// log дописывает операцию в журнал и применяет ее. Удаление несуществующего ряда
+// не журналируется.
+func (s *DurableStorage) log(op string, metric *model.Metrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if op == walOpDelete {
+		if _, err := s.MetricsRepository.GetMetric(metric); err != nil {
+			return err
+		}
+	}
+	return s.append(walRecord{Seq: s.seq + 1, Op: op, Tenant: metric.Tenant, Metric: metric})
+}
+
+// append пишет запись в журнал и применяет ее. Готовность сервера (walErr) меняют
+// только ошибки записи в файл: запись, которую не удалось закодировать, отклоняется
+// без последствий для остальных.
+func (s *DurableStorage) append(record walRecord) error {
+	line, err := encodeWALRecord(record)
+	if err != nil {
+		return fmt.Errorf("encode write-ahead log record: %w", err)
+	}
+	if err := s.wal.append(line); err != nil {
+		s.walErr = fmt.Errorf("write-ahead log: %w", err)
+		return s.walErr
+	}
+	s.walErr = nil
+	s.seq = record.Seq
+	return s.apply(record)
+}
+
+// Ping сообщает об ошибке последней записи в журнал или снимка и проверяет, что в
+// каталог хранилища можно записать файл.
+func (s *DurableStorage) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	walErr, snapshotErr := s.walErr, s.snapshotErr
+	s.mu.Unlock()
+	if walErr != nil {
+		return walErr
+	}
+	if snapshotErr != nil {
+		return fmt.Errorf("snapshot: %w", snapshotErr)
+	}
+
+	probe, err := os.CreateTemp(s.dir, ".ping.*")
+	if err != nil {
+		return fmt.Errorf("storage directory is not writable: %w", err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return s.MetricsRepository.Ping(ctx)
+}
+
+// Snapshot атомарно записывает текущее состояние в файл снимка и очищает журнал.
+// Изменения на время записи снимка приостанавливаются.
+func (s *DurableStorage) Snapshot() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.snapshotErr = s.snapshot()
+	return s.snapshotErr
+}
+
+func (s *DurableStorage) snapshot() error {
+	metrics := s.MetricsRepository.GetAllMetrics()
+	snap := snapshot{Seq: s.seq, Metrics: make([]snapshotMetric, 0, len(metrics))}
+	for _, metric := range metrics {
+		snap.Metrics = append(snap.Metrics, snapshotMetric{Tenant: metric.Tenant, Metric: metric})
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, snapshotFile+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, snapshotFile)); err != nil {
+		return err
+	}
+	// Переименование сохраняется на диске только после синхронизации каталога.
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+	// Если процесс упадет до очистки журнала, записи с номерами из снимка будут
+	// пропущены при восстановлении.
+	return s.wal.reset()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Run периодически записывает снимки до отмены ctx и записывает последний снимок
+// при остановке.
+func (s *DurableStorage) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.Snapshot(); err != nil {
+				fmt.Printf("Error writing snapshot: %v\n", err)
+			}
+			return
+		case <-ticker.C:
+			if err := s.Snapshot(); err != nil {
+				fmt.Printf("Error writing snapshot: %v\n", err)
+			}
+		}
+	}
+}
+
+// Close закрывает журнал. После Close изменения не принимаются.
+func (s *DurableStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.wal.close()
+}
@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func counter(id string, delta int64) *model.Metrics {
+	return &model.Metrics{ID: id, MType: model.Counter, Delta: &delta}
+}
+
+func openDurable(t *testing.T, dir string) *DurableStorage {
+	t.Helper()
+	storage, err := NewDurableStorage(NewMemStorage(), dir, false)
+	require.NoError(t, err)
+	t.Cleanup(func() { storage.Close() })
+	return storage
+}
+
+func counterValue(t *testing.T, storage MetricsRepository, id, tenant string) int64 {
+	t.Helper()
+	metric, err := storage.GetMetric(&model.Metrics{ID: id, MType: model.Counter, Tenant: tenant})
+	require.NoError(t, err)
+	return *metric.Delta
+}
+
+func walSize(t *testing.T, dir string) int64 {
+	t.Helper()
+	info, err := os.Stat(filepath.Join(dir, walFile))
+	require.NoError(t, err)
+	return info.Size()
+}
+
+func TestDurableStorage_ReplaysLog(t *testing.T) {
+	dir := t.TempDir()
+	storage := openDurable(t, dir)
+
+	require.NoError(t, storage.UpdateMetric(counter("requests", 2)))
+	require.NoError(t, storage.UpdateMetric(counter("requests", 3)))
+	tenantCounter := counter("requests", 7)
+	tenantCounter.Tenant = "team-a"
+	require.NoError(t, storage.UpdateMetric(tenantCounter))
+	require.NoError(t, storage.UpdateMetric(gauge("temp", map[string]string{"room": "a"})))
+	require.NoError(t, storage.UpdateMetric(gauge("removed", nil)))
+	require.NoError(t, storage.DeleteMetric(gauge("removed", nil)))
+	require.ErrorIs(t, storage.DeleteMetric(gauge("missing", nil)), ErrMetricNotFound)
+
+	// Процесс "падает" без снимка: восстановление идет только по журналу.
+	restored := openDurable(t, dir)
+	require.Equal(t, int64(5), counterValue(t, restored, "requests", ""))
+	require.Equal(t, int64(7), counterValue(t, restored, "requests", "team-a"))
+	_, err := restored.GetMetric(gauge("temp", map[string]string{"room": "a"}))
+	require.NoError(t, err)
+	_, err = restored.GetMetric(gauge("removed", nil))
+	require.ErrorIs(t, err, ErrMetricNotFound)
+	require.Len(t, restored.GetAllMetrics(), 3)
+}
+
+func TestDurableStorage_SnapshotTruncatesLog(t *testing.T) {
+	dir := t.TempDir()
+	storage := openDurable(t, dir)
+
+	require.NoError(t, storage.UpdateMetric(counter("requests", 2)))
+	require.NoError(t, storage.Snapshot())
+	require.Zero(t, walSize(t, dir))
+
+	require.NoError(t, storage.UpdateMetric(counter("requests", 3)))
+	require.NotZero(t, walSize(t, dir))
+
+	restored := openDurable(t, dir)
+	require.Equal(t, int64(5), counterValue(t, restored, "requests", ""))
+}
+
+func TestDurableStorage_SkipsRecordsCoveredBySnapshot(t *testing.T) {
+	dir := t.TempDir()
+	storage := openDurable(t, dir)
+
+	require.NoError(t, storage.UpdateMetric(counter("requests", 2)))
+	log, err := os.ReadFile(filepath.Join(dir, walFile))
+	require.NoError(t, err)
+	require.NoError(t, storage.Snapshot())
+	require.NoError(t, storage.Close())
+
+	// Падение между записью снимка и очисткой журнала: журнал сохранил записи,
+	// которые уже вошли в снимок.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, walFile), log, 0o640))
+
+	restored := openDurable(t, dir)
+	require.Equal(t, int64(2), counterValue(t, restored, "requests", ""), "counter must not be applied twice")
+	require.NoError(t, restored.UpdateMetric(counter("requests", 1)))
+
+	restored = openDurable(t, dir)
+	require.Equal(t, int64(3), counterValue(t, restored, "requests", ""))
+}
+
+func TestDurableStorage_DamagedTail(t *testing.T) {
+	tests := []struct {
+		name     string
+		damage   func(data []byte) []byte
+		expected int64
+	}{
+		{
+			name:     "torn last record",
+			damage:   func(data []byte) []byte { return data[:len(data)-5] },
+			expected: 3,
+		},
+		{
+			name:     "missing final newline",
+			damage:   func(data []byte) []byte { return data[:len(data)-1] },
+			expected: 3,
+		},
+		{
+			name: "corrupted last record",
+			damage: func(data []byte) []byte {
+				data[len(data)-3] ^= 0xff
+				return data
+			},
+			expected: 3,
+		},
+		{
+			name:     "garbage after last record",
+			damage:   func(data []byte) []byte { return append(data, []byte("\x00\x00garbage")...) },
+			expected: 7,
+		},
+		{
+			name: "corrupted first record drops the rest",
+			damage: func(data []byte) []byte {
+				data[20] ^= 0xff
+				return data
+			},
+			expected: 0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			storage := openDurable(t, dir)
+			require.NoError(t, storage.UpdateMetric(counter("requests", 1)))
+			require.NoError(t, storage.UpdateMetric(counter("requests", 2)))
+			require.NoError(t, storage.UpdateMetric(counter("requests", 4)))
+			require.NoError(t, storage.Close())
+
+			path := filepath.Join(dir, walFile)
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(path, tc.damage(data), 0o640))
+
+			restored := openDurable(t, dir)
+			if tc.expected == 0 {
+				// Первая запись повреждена, поэтому журнал считается пустым.
+				_, err := restored.GetMetric(counter("requests", 0))
+				require.ErrorIs(t, err, ErrMetricNotFound)
+			} else {
+				require.Equal(t, tc.expected, counterValue(t, restored, "requests", ""))
+			}
+
+			// Поврежденный хвост отрезан, и новые записи восстанавливаются.
+			require.NoError(t, restored.UpdateMetric(counter("requests", 10)))
+			require.NoError(t, restored.Close())
+			restored = openDurable(t, dir)
+			require.Equal(t, tc.expected+10, counterValue(t, restored, "requests", ""))
+		})
+	}
+}
+
+func TestDurableStorage_ReplaysBatch(t *testing.T) {
+	dir := t.TempDir()
+	storage := openDurable(t, dir)
+
+	tenantCounter := counter("requests", 7)
+	tenantCounter.Tenant = "team-a"
+	require.NoError(t, storage.UpdateMetrics([]*model.Metrics{counter("requests", 2), counter("requests", 3), tenantCounter}))
+	require.Equal(t, int64(5), counterValue(t, storage, "requests", ""))
+
+	restored := openDurable(t, dir)
+	require.Equal(t, int64(5), counterValue(t, restored, "requests", ""))
+	require.Equal(t, int64(7), counterValue(t, restored, "requests", "team-a"))
+}
+
+func TestDurableStorage_PingReportsUnwritableDirectory(t *testing.T) {
+	dir := t.TempDir()
+	storage := openDurable(t, dir)
+	require.NoError(t, storage.Ping(context.Background()))
+
+	require.NoError(t, os.RemoveAll(dir))
+	require.Error(t, storage.Ping(context.Background()))
+	require.Error(t, storage.Snapshot())
+
+	require.NoError(t, os.MkdirAll(dir, 0o750))
+	require.Error(t, storage.Ping(context.Background()), "Failed snapshot must keep storage unready")
+	require.NoError(t, storage.Snapshot())
+	require.NoError(t, storage.Ping(context.Background()))
+}
+
+func TestDurableStorage_NonFiniteValues(t *testing.T) {
+	dir := t.TempDir()
+	storage := openDurable(t, dir)
+
+	nan, inf := math.NaN(), math.Inf(1)
+	require.NoError(t, storage.UpdateMetric(&model.Metrics{ID: "nan", MType: model.Gauge, Value: &nan}))
+	require.NoError(t, storage.UpdateMetrics([]*model.Metrics{{ID: "inf", MType: model.Gauge, Value: &inf}}))
+	count := uint64(1)
+	require.NoError(t, storage.UpdateMetric(&model.Metrics{ID: "latency", MType: model.Summary, Count: &count, Sum: &inf, Quantiles: []model.Quantile{{Quantile: 0.99, Value: nan}}}))
+	require.NoError(t, storage.Ping(context.Background()))
+
+	gaugeValue := func(storage MetricsRepository, id string) float64 {
+		metric, err := storage.GetMetric(&model.Metrics{ID: id, MType: model.Gauge})
+		require.NoError(t, err)
+		return *metric.Value
+	}
+	restored := openDurable(t, dir)
+	require.True(t, math.IsNaN(gaugeValue(restored, "nan")))
+	require.True(t, math.IsInf(gaugeValue(restored, "inf"), 1))
+
+	require.NoError(t, restored.Snapshot())
+	fromSnapshot := openDurable(t, dir)
+	require.True(t, math.IsNaN(gaugeValue(fromSnapshot, "nan")))
+	summary, err := fromSnapshot.GetMetric(&model.Metrics{ID: "latency", MType: model.Summary})
+	require.NoError(t, err)
+	require.True(t, math.IsInf(*summary.Sum, 1))
+	require.True(t, math.IsNaN(summary.Quantiles[0].Value))
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+
+	"github.com/prbllm/go-metrics/internal/model"
+)
+
+// jsonFloat - число в журнале и снимке. JSON не умеет NaN и ±Inf, а gauge и сводки
+// могут их содержать, поэтому такие значения записываются строками "NaN", "+Inf"
+// и "-Inf". Конечные значения записываются обычными числами, как в model.Metrics.
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	value := float64(f)
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return []byte(strconv.Quote(strconv.FormatFloat(value, 'g', -1, 64))), nil
+	}
+	return json.Marshal(value)
+}
+
+func (f *jsonFloat) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		text, err := strconv.Unquote(string(data))
+		if err != nil {
+			return err
+		}
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return err
+		}
+		*f = jsonFloat(value)
+		return nil
+	}
+	var value float64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*f = jsonFloat(value)
+	return nil
+}
+
+// storedMetric повторяет JSON-представление model.Metrics с числами jsonFloat.
+type storedMetric struct {
+	ID        string            `json:"id"`
+	MType     string            `json:"type"`
+	Delta     *int64            `json:"delta,omitempty"`
+	Value     *jsonFloat        `json:"value,omitempty"`
+	Count     *uint64           `json:"count,omitempty"`
+	Sum       *jsonFloat        `json:"sum,omitempty"`
+	Buckets   []storedBucket    `json:"buckets,omitempty"`
+	Quantiles []storedQuantile  `json:"quantiles,omitempty"`
+	Hash      string            `json:"hash,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+type storedBucket struct {
+	UpperBound jsonFloat `json:"le"`
+	Count      uint64    `json:"count"`
+}
+
+type storedQuantile struct {
+	Quantile jsonFloat `json:"quantile"`
+	Value    jsonFloat `json:"value"`
+}
+
+func newStoredMetric(metric *model.Metrics) *storedMetric {
+	if metric == nil {
+		return nil
+	}
+	stored := &storedMetric{
+		ID:     metric.ID,
+		MType:  metric.MType,
+		Delta:  metric.Delta,
+		Value:  (*jsonFloat)(metric.Value),
+		Count:  metric.Count,
+		Sum:    (*jsonFloat)(metric.Sum),
+		Hash:   metric.Hash,
+		Labels: metric.Labels,
+	}
+	for _, bucket := range metric.Buckets {
+		stored.Buckets = append(stored.Buckets, storedBucket{UpperBound: jsonFloat(bucket.UpperBound), Count: bucket.Count})
+	}
+	for _, quantile := range metric.Quantiles {
+		stored.Quantiles = append(stored.Quantiles, storedQuantile{Quantile: jsonFloat(quantile.Quantile), Value: jsonFloat(quantile.Value)})
+	}
+	return stored
+}
+
+func (s *storedMetric) metric() *model.Metrics {
+	if s == nil {
+		return nil
+	}
+	metric := &model.Metrics{
+		ID:     s.ID,
+		MType:  s.MType,
+		Delta:  s.Delta,
+		Value:  (*float64)(s.Value),
+		Count:  s.Count,
+		Sum:    (*float64)(s.Sum),
+		Hash:   s.Hash,
+		Labels: s.Labels,
+	}
+	for _, bucket := range s.Buckets {
+		metric.Buckets = append(metric.Buckets, model.Bucket{UpperBound: float64(bucket.UpperBound), Count: bucket.Count})
+	}
+	for _, quantile := range s.Quantiles {
+		metric.Quantiles = append(metric.Quantiles, model.Quantile{Quantile: float64(quantile.Quantile), Value: float64(quantile.Value)})
+	}
+	return metric
+}
+
+type storedSnapshotMetric struct {
+	Tenant string        `json:"tenant,omitempty"`
+	Metric *storedMetric `json:"metric"`
+}
+
+func (m snapshotMetric) MarshalJSON() ([]byte, error) {
+	return json.Marshal(storedSnapshotMetric{Tenant: m.Tenant, Metric: newStoredMetric(m.Metric)})
+}
+
+func (m *snapshotMetric) UnmarshalJSON(data []byte) error {
+	var stored storedSnapshotMetric
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+	*m = snapshotMetric{Tenant: stored.Tenant, Metric: stored.Metric.metric()}
+	return nil
+}
+
+type storedWALRecord struct {
+	Seq    uint64           `json:"seq"`
+	Op     string           `json:"op"`
+	Tenant string           `json:"tenant,omitempty"`
+	Metric *storedMetric    `json:"metric,omitempty"`
+	Batch  []snapshotMetric `json:"batch,omitempty"`
+}
+
+func (r walRecord) MarshalJSON() ([]byte, error) {
+	return json.Marshal(storedWALRecord{Seq: r.Seq, Op: r.Op, Tenant: r.Tenant, Metric: newStoredMetric(r.Metric), Batch: r.Batch})
+}
+
+func (r *walRecord) UnmarshalJSON(data []byte) error {
+	var stored storedWALRecord
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+	*r = walRecord{Seq: stored.Seq, Op: stored.Op, Tenant: stored.Tenant, Metric: stored.Metric.metric(), Batch: stored.Batch}
+	return nil
+}
@@ -0,0 +1,9 @@
+package repository
+
+import "errors"
+
+var (
+	ErrMetricNotFound   = errors.New("metric not found")
+	ErrBucketsMismatch  = errors.New("histogram buckets mismatch")
+	ErrCardinalityLimit = errors.New("cardinality limit exceeded")
+)
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/tsdb"
+)
+
+// HistoryStorage записывает в tsdb.History значение ряда после каждого обновления:
+// накопленное значение для counter и текущее для gauge. Гистограммы, сводки и
+// значения NaN и ±Inf в историю не попадают. История хранится только в памяти.
+type HistoryStorage struct {
+	MetricsRepository
+
+	history *tsdb.History
+	now     func() time.Time
+
+	// locks упорядочивают обновление ряда и запись в историю, чтобы значения
+	// счетчика попадали в историю в том же порядке, в котором применялись. Ряд
+	// защищает одна из historyLockStripes блокировок по хешу ключа, поэтому
+	// обновления разных рядов, как правило, не ждут друг друга.
+	locks [historyLockStripes]sync.Mutex
+}
+
+const historyLockStripes = 64
+
+func NewHistoryStorage(repository MetricsRepository, history *tsdb.History) *HistoryStorage {
+	return &HistoryStorage{MetricsRepository: repository, history: history, now: time.Now}
+}
+
+func (s *HistoryStorage) UpdateMetric(metric *model.Metrics) error {
+	defer s.lock(metric)()
+
+	if err := s.MetricsRepository.UpdateMetric(metric); err != nil {
+		return err
+	}
+	// Ошибка записи истории не отменяет уже примененное обновление.
+	if err := s.record(metric); err != nil {
+		fmt.Printf("Error recording history of %s: %v\n", metric.FullID(), err)
+	}
+	return nil
+}
+
+func (s *HistoryStorage) UpdateMetrics(metrics []*model.Metrics) error {
+	defer s.lock(metrics...)()
+
+	if err := s.MetricsRepository.UpdateMetrics(metrics); err != nil {
+		return err
+	}
+	for _, metric := range metrics {
+		if err := s.record(metric); err != nil {
+			fmt.Printf("Error recording history of %s: %v\n", metric.FullID(), err)
+		}
+	}
+	return nil
+}
+
+// lock захватывает блокировки рядов metrics по возрастанию номера, чтобы пакеты
+// с пересекающимися рядами не блокировали друг друга навсегда, и возвращает функцию,
+// которая их освобождает.
+func (s *HistoryStorage) lock(metrics ...*model.Metrics) func() {
+	var stripes []int
+	seen := make(map[int]bool, len(metrics))
+	for _, metric := range metrics {
+		hash := fnv.New32a()
+		hash.Write([]byte(seriesKey(metric)))
+		stripe := int(hash.Sum32() % historyLockStripes)
+		if !seen[stripe] {
+			seen[stripe] = true
+			stripes = append(stripes, stripe)
+		}
+	}
+	sort.Ints(stripes)
+	for _, stripe := range stripes {
+		s.locks[stripe].Lock()
+	}
+	return func() {
+		for _, stripe := range stripes {
+			s.locks[stripe].Unlock()
+		}
+	}
+}
+
+func (s *HistoryStorage) record(metric *model.Metrics) error {
+	if metric.MType != model.Counter && metric.MType != model.Gauge {
+		return nil
+	}
+	stored, err := s.MetricsRepository.GetMetric(metric)
+	if err != nil {
+		return err
+	}
+	value, ok := historyValue(stored)
+	if !ok {
+		return nil
+	}
+	// Из нескольких обновлений ряда за одну миллисекунду сохраняется последнее.
+	return s.history.Set(seriesKey(metric), s.now(), value)
+}
+
+func historyValue(metric *model.Metrics) (float64, bool) {
+	switch {
+	case metric.MType == model.Counter && metric.Delta != nil:
+		return float64(*metric.Delta), true
+	case metric.MType == model.Gauge && metric.Value != nil:
+		return *metric.Value, !math.IsNaN(*metric.Value) && !math.IsInf(*metric.Value, 0)
+	default:
+		return 0, false
+	}
+}
+
+func (s *HistoryStorage) DeleteMetric(metric *model.Metrics) error {
+	defer s.lock(metric)()
+
+	if err := s.MetricsRepository.DeleteMetric(metric); err != nil {
+		return err
+	}
+	s.history.Delete(seriesKey(metric))
+	return nil
+}
+
+// History возвращает историю ряда metric за [from, to].
+func (s *HistoryStorage) History(metric *model.Metrics, from, to time.Time) ([]tsdb.Point, error) {
+	return s.history.Query(seriesKey(metric), from, to)
+}
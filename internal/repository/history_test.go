@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/tsdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryStorage_RecordsValues(t *testing.T) {
+	history, err := tsdb.NewHistory(time.Hour, nil)
+	require.NoError(t, err)
+	storage := NewHistoryStorage(NewMemStorage(), history)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	storage.now = func() time.Time { return now }
+	start := now
+
+	for _, delta := range []int64{2, 3, 5} {
+		require.NoError(t, storage.UpdateMetric(counter("requests", delta)))
+		now = now.Add(time.Second)
+	}
+	// Из обновлений в одну миллисекунду в истории остается последнее.
+	require.NoError(t, storage.UpdateMetric(counter("requests", 1)))
+	require.NoError(t, storage.UpdateMetric(counter("requests", 1)))
+
+	tenantCounter := counter("requests", 7)
+	tenantCounter.Tenant = "team-a"
+	require.NoError(t, storage.UpdateMetric(tenantCounter))
+
+	value := math.NaN()
+	require.NoError(t, storage.UpdateMetric(&model.Metrics{ID: "temp", MType: model.Gauge, Value: &value}))
+
+	points, err := storage.History(counter("requests", 0), start, now)
+	require.NoError(t, err)
+	values := make([]float64, 0, len(points))
+	for _, p := range points {
+		values = append(values, p.Sum)
+	}
+	require.Equal(t, []float64{2, 5, 10, 12}, values, "counters are recorded as running totals")
+
+	points, err = storage.History(&model.Metrics{ID: "requests", MType: model.Counter, Tenant: "team-a"}, start, now)
+	require.NoError(t, err)
+	require.Len(t, points, 1)
+
+	points, err = storage.History(&model.Metrics{ID: "temp", MType: model.Gauge}, start, now)
+	require.NoError(t, err)
+	require.Empty(t, points, "NaN is not recorded")
+
+	require.NoError(t, storage.DeleteMetric(counter("requests", 0)))
+	points, err = storage.History(counter("requests", 0), start, now)
+	require.NoError(t, err)
+	require.Empty(t, points)
+}
+
+func TestHistoryStorage_ConcurrentUpdates(t *testing.T) {
+	history, err := tsdb.NewHistory(time.Hour, nil)
+	require.NoError(t, err)
+	storage := NewHistoryStorage(NewMemStorage(), history)
+	start := time.Now()
+
+	const workers, updates = 8, 50
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range updates {
+				// Пакеты пересекаются по рядам в разном порядке.
+				batch := []*model.Metrics{counter("a", 1), counter("b", 1)}
+				if i%2 == 1 {
+					batch[0], batch[1] = batch[1], batch[0]
+				}
+				require.NoError(t, storage.UpdateMetrics(batch))
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, id := range []string{"a", "b"} {
+		points, err := storage.History(counter(id, 0), start, time.Now())
+		require.NoError(t, err)
+		require.NotEmpty(t, points)
+		for i := 1; i < len(points); i++ {
+			require.Less(t, points[i-1].Sum, points[i].Sum, "history of %s follows the order of updates", id)
+		}
+		require.Equal(t, float64(workers*updates), points[len(points)-1].Sum)
+	}
+}
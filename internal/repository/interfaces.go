@@ -1,11 +1,19 @@
 package repository
 
 import (
+	"context"
+
 	"github.com/prbllm/go-metrics/internal/model"
 )
 
 type MetricsRepository interface {
 	UpdateMetric(metric *model.Metrics) error
+	// UpdateMetrics атомарно записывает пакет метрик: при ошибке ни одна метрика пакета не записывается.
+	UpdateMetrics(metrics []*model.Metrics) error
 	GetMetric(metric *model.Metrics) (*model.Metrics, error)
 	GetAllMetrics() []*model.Metrics
+	// DeleteMetric удаляет ряд с типом, именем и метками metric. Если ряда нет, возвращается ErrMetricNotFound.
+	DeleteMetric(metric *model.Metrics) error
+	// Ping проверяет доступность хранилища. Используется проверкой готовности сервера.
+	Ping(ctx context.Context) error
 }
@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+)
+
+// CardinalityLimits задает ограничения на количество рядов (уникальных сочетаний типа, имени и меток).
+// Нулевое значение означает отсутствие ограничения.
+type CardinalityLimits struct {
+	MaxSeries        int
+	MaxSeriesPerName int
+	MaxNewSeries     int
+	NewSeriesWindow  time.Duration
+}
+
+// LimitedStorage оборачивает репозиторий и отклоняет создание новых рядов сверх заданных лимитов.
+// Обновления уже существующих рядов проходят всегда.
+type LimitedStorage struct {
+	MetricsRepository
+
+	limits CardinalityLimits
+	now    func() time.Time
+
+	mu          sync.Mutex
+	series      int
+	perName     map[string]int
+	windowStart time.Time
+	windowNew   int
+}
+
+func NewLimitedStorage(repository MetricsRepository, limits CardinalityLimits) *LimitedStorage {
+	s := &LimitedStorage{
+		MetricsRepository: repository,
+		limits:            limits,
+		now:               time.Now,
+		perName:           make(map[string]int),
+	}
+	for _, metric := range repository.GetAllMetrics() {
+		s.series++
+		s.perName[nameKey(metric)]++
+	}
+	s.windowStart = s.now()
+	return s
+}
+
+func (s *LimitedStorage) UpdateMetric(metric *model.Metrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.MetricsRepository.GetMetric(metric)
+	if err == nil {
+		return s.MetricsRepository.UpdateMetric(metric)
+	}
+	if !errors.Is(err, ErrMetricNotFound) {
+		return err
+	}
+
+	if err := s.checkNewSeries(metric); err != nil {
+		return err
+	}
+	if err := s.MetricsRepository.UpdateMetric(metric); err != nil {
+		return err
+	}
+	s.series++
+	s.perName[nameKey(metric)]++
+	s.windowNew++
+	return nil
+}
+
+// UpdateMetrics проверяет лимиты для всех новых рядов пакета до записи: пакет, в
+// котором хотя бы один новый ряд превышает лимит, отклоняется целиком.
+func (s *LimitedStorage) UpdateMetrics(metrics []*model.Metrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var added []*model.Metrics
+	seen := make(map[string]bool, len(metrics))
+	for _, metric := range metrics {
+		key := seriesKey(metric)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		_, err := s.MetricsRepository.GetMetric(metric)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, ErrMetricNotFound) {
+			s.uncount(added)
+			return err
+		}
+		if err := s.checkNewSeries(metric); err != nil {
+			s.uncount(added)
+			return err
+		}
+		s.series++
+		s.perName[nameKey(metric)]++
+		s.windowNew++
+		added = append(added, metric)
+	}
+	if err := s.MetricsRepository.UpdateMetrics(metrics); err != nil {
+		s.uncount(added)
+		return err
+	}
+	return nil
+}
+
+// uncount отменяет учет рядов, которые не были записаны.
+func (s *LimitedStorage) uncount(metrics []*model.Metrics) {
+	for _, metric := range metrics {
+		s.series--
+		s.perName[nameKey(metric)]--
+		if s.perName[nameKey(metric)] <= 0 {
+			delete(s.perName, nameKey(metric))
+		}
+		s.windowNew = max(s.windowNew-1, 0)
+	}
+}
+
+func (s *LimitedStorage) DeleteMetric(metric *model.Metrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.MetricsRepository.DeleteMetric(metric); err != nil {
+		return err
+	}
+	s.series--
+	s.perName[nameKey(metric)]--
+	if s.perName[nameKey(metric)] <= 0 {
+		delete(s.perName, nameKey(metric))
+	}
+	return nil
+}
+
+// nameKey - ключ лимита рядов на имя: у каждого арендатора свой счетчик.
+func nameKey(metric *model.Metrics) string {
+	return metric.Tenant + "/" + metric.ID
+}
+
+func (s *LimitedStorage) checkNewSeries(metric *model.Metrics) error {
+	if s.limits.MaxSeries > 0 && s.series >= s.limits.MaxSeries {
+		return fmt.Errorf("%w: total series limit %d reached, metric %s rejected", ErrCardinalityLimit, s.limits.MaxSeries, metric.FullID())
+	}
+	if s.limits.MaxSeriesPerName > 0 && s.perName[nameKey(metric)] >= s.limits.MaxSeriesPerName {
+		return fmt.Errorf("%w: series limit %d for metric %s reached", ErrCardinalityLimit, s.limits.MaxSeriesPerName, metric.ID)
+	}
+	if s.limits.MaxNewSeries > 0 && s.limits.NewSeriesWindow > 0 {
+		now := s.now()
+		if now.Sub(s.windowStart) >= s.limits.NewSeriesWindow {
+			s.windowStart = now
+			s.windowNew = 0
+		}
+		if s.windowNew >= s.limits.MaxNewSeries {
+			return fmt.Errorf("%w: no more than %d new series per %v allowed, metric %s rejected", ErrCardinalityLimit, s.limits.MaxNewSeries, s.limits.NewSeriesWindow, metric.FullID())
+		}
+	}
+	return nil
+}
+
+func (s *LimitedStorage) SeriesCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.series
+}
+
+// GetMetric дополнительно отдает служебную метрику с текущим количеством рядов всех арендаторов.
+// Служебная метрика принадлежит пространству по умолчанию.
+func (s *LimitedStorage) GetMetric(metric *model.Metrics) (*model.Metrics, error) {
+	if metric != nil && metric.Tenant == "" && metric.MType == model.Gauge && metric.ID == model.SeriesCountMetric && len(metric.Labels) == 0 {
+		return s.seriesCountMetric(), nil
+	}
+	return s.MetricsRepository.GetMetric(metric)
+}
+
+func (s *LimitedStorage) GetAllMetrics() []*model.Metrics {
+	return append(s.MetricsRepository.GetAllMetrics(), s.seriesCountMetric())
+}
+
+func (s *LimitedStorage) seriesCountMetric() *model.Metrics {
+	value := float64(s.SeriesCount())
+	return &model.Metrics{ID: model.SeriesCountMetric, MType: model.Gauge, Value: &value}
+}
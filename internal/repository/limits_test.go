@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func gauge(id string, labels map[string]string) *model.Metrics {
+	value := 1.0
+	return &model.Metrics{ID: id, MType: model.Gauge, Value: &value, Labels: labels}
+}
+
+func TestLimitedStorage_MaxSeries(t *testing.T) {
+	storage := NewLimitedStorage(NewMemStorage(), CardinalityLimits{MaxSeries: 2})
+
+	require.NoError(t, storage.UpdateMetric(gauge("a", nil)))
+	require.NoError(t, storage.UpdateMetric(gauge("b", nil)))
+	require.ErrorIs(t, storage.UpdateMetric(gauge("c", nil)), ErrCardinalityLimit)
+	require.NoError(t, storage.UpdateMetric(gauge("a", nil)), "Existing series must be updated")
+	require.Equal(t, 2, storage.SeriesCount())
+
+	metric, err := storage.GetMetric(&model.Metrics{ID: model.SeriesCountMetric, MType: model.Gauge})
+	require.NoError(t, err)
+	require.Equal(t, 2.0, *metric.Value)
+	require.Len(t, storage.GetAllMetrics(), 3, "Series count self-metric must be listed")
+}
+
+func TestLimitedStorage_MaxSeriesPerName(t *testing.T) {
+	storage := NewLimitedStorage(NewMemStorage(), CardinalityLimits{MaxSeriesPerName: 1})
+
+	require.NoError(t, storage.UpdateMetric(gauge("requests", map[string]string{"host": "a"})))
+	require.ErrorIs(t, storage.UpdateMetric(gauge("requests", map[string]string{"host": "b"})), ErrCardinalityLimit)
+	require.NoError(t, storage.UpdateMetric(gauge("errors", map[string]string{"host": "b"})))
+}
+
+func TestLimitedStorage_NewSeriesWindow(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	storage := NewLimitedStorage(NewMemStorage(), CardinalityLimits{MaxNewSeries: 1, NewSeriesWindow: time.Minute})
+	storage.now = func() time.Time { return now }
+	storage.windowStart = now
+
+	require.NoError(t, storage.UpdateMetric(gauge("a", nil)))
+	require.ErrorIs(t, storage.UpdateMetric(gauge("b", nil)), ErrCardinalityLimit)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, storage.UpdateMetric(gauge("b", nil)), "New window must allow new series")
+}
+
+func TestLimitedStorage_CountsExistingSeries(t *testing.T) {
+	mem := NewMemStorage()
+	require.NoError(t, mem.UpdateMetric(gauge("a", nil)))
+
+	storage := NewLimitedStorage(mem, CardinalityLimits{MaxSeries: 1})
+	require.Equal(t, 1, storage.SeriesCount())
+	require.ErrorIs(t, storage.UpdateMetric(gauge("b", nil)), ErrCardinalityLimit)
+}
+
+func TestLimitedStorage_DeleteFreesSeries(t *testing.T) {
+	storage := NewLimitedStorage(NewMemStorage(), CardinalityLimits{MaxSeries: 1})
+
+	require.NoError(t, storage.UpdateMetric(gauge("a", nil)))
+	require.ErrorIs(t, storage.DeleteMetric(gauge("b", nil)), ErrMetricNotFound)
+	require.NoError(t, storage.DeleteMetric(gauge("a", nil)))
+	require.Equal(t, 0, storage.SeriesCount())
+	require.NoError(t, storage.UpdateMetric(gauge("b", nil)), "Deleted series must free the limit")
+}
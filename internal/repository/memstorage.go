@@ -1,12 +1,15 @@
 package repository
 
 import (
+	"context"
 	"fmt"
+	"sync"
 
 	"github.com/prbllm/go-metrics/internal/model"
 )
 
 type MemStorage struct {
+	mu      sync.RWMutex
 	metrics map[string]*model.Metrics
 }
 
@@ -16,21 +19,79 @@ func NewMemStorage() *MemStorage {
 	}
 }
 
-func (m *MemStorage) generateKey(metricType, name string) string {
-	return fmt.Sprintf("%s:%s", metricType, name)
+func (m *MemStorage) generateKey(metric *model.Metrics) string {
+	return seriesKey(metric)
 }
 
+// seriesKey строит ключ ряда. Ряды разных арендаторов хранятся под разными ключами.
+func seriesKey(metric *model.Metrics) string {
+	key := fmt.Sprintf("%s:%s%s", metric.MType, metric.ID, model.FormatLabels(metric.Labels))
+	if metric.Tenant != "" {
+		key = metric.Tenant + "/" + key
+	}
+	return key
+}
+
+// UpdateMetric записывает копию metric, объединенную с текущим значением ряда.
+// Переданная метрика не меняется и не сохраняется.
 func (m *MemStorage) UpdateMetric(metric *model.Metrics) error {
-	key := m.generateKey(metric.MType, metric.ID)
+	key := m.generateKey(metric)
+	stored := metric.Clone()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, exists := m.metrics[key]
+	if err := merge(existing, exists, stored); err != nil {
+		return err
+	}
+	fmt.Printf("Updating metric: %s\n", stored.String())
+	m.metrics[key] = stored
+	return nil
+}
+
+// UpdateMetrics сначала объединяет все метрики пакета с текущими значениями и только
+// потом записывает их, поэтому ошибка не оставляет пакет записанным частично.
+func (m *MemStorage) UpdateMetrics(metrics []*model.Metrics) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pending := make(map[string]*model.Metrics, len(metrics))
+	for _, metric := range metrics {
+		key := m.generateKey(metric)
+		stored := metric.Clone()
+		existing, exists := pending[key]
+		if !exists {
+			existing, exists = m.metrics[key]
+		}
+		if err := merge(existing, exists, stored); err != nil {
+			return err
+		}
+		pending[key] = stored
+	}
+	for key, metric := range pending {
+		fmt.Printf("Updating metric: %s\n", metric.String())
+		m.metrics[key] = metric
+	}
+	return nil
+}
 
-	if metric.MType == model.Counter {
-		if existing, exists := m.metrics[key]; exists && existing.Delta != nil {
+// merge добавляет к обновлению накопленное значение существующего ряда.
+func merge(existing *model.Metrics, exists bool, metric *model.Metrics) error {
+	if !exists {
+		return nil
+	}
+	switch metric.MType {
+	case model.Counter:
+		if existing.Delta != nil {
 			newDelta := *existing.Delta + *metric.Delta
 			metric.Delta = &newDelta
 		}
+	case model.Histogram:
+		return mergeHistogram(existing, metric)
+	case model.Summary:
+		mergeSummary(existing, metric)
 	}
-	fmt.Printf("Updating metric: %s\n", metric.String())
-	m.metrics[key] = metric
 	return nil
 }
 
@@ -39,18 +100,46 @@ func (m *MemStorage) GetMetric(metric *model.Metrics) (*model.Metrics, error) {
 		return nil, fmt.Errorf("metric is nil")
 	}
 
-	key := m.generateKey(metric.MType, metric.ID)
+	key := m.generateKey(metric)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	val, ok := m.metrics[key]
 	if !ok {
-		return nil, fmt.Errorf("metric %s not found", key)
+		return nil, fmt.Errorf("%w: %s", ErrMetricNotFound, key)
 	}
 	return val, nil
 }
 
 func (m *MemStorage) GetAllMetrics() []*model.Metrics {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	metrics := make([]*model.Metrics, 0, len(m.metrics))
 	for _, metric := range m.metrics {
 		metrics = append(metrics, metric)
 	}
 	return metrics
 }
+
+func (m *MemStorage) DeleteMetric(metric *model.Metrics) error {
+	if metric == nil {
+		return fmt.Errorf("metric is nil")
+	}
+
+	key := m.generateKey(metric)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.metrics[key]; !ok {
+		return fmt.Errorf("%w: %s", ErrMetricNotFound, key)
+	}
+	delete(m.metrics, key)
+	return nil
+}
+
+func (m *MemStorage) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
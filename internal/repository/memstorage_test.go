@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemStorage_KeepsCallerMetric(t *testing.T) {
+	storage := NewMemStorage()
+	first := counter("requests", 2)
+	require.NoError(t, storage.UpdateMetric(first))
+	second := counter("requests", 3)
+	require.NoError(t, storage.UpdateMetrics([]*model.Metrics{second}))
+
+	require.Equal(t, int64(2), *first.Delta)
+	require.Equal(t, int64(3), *second.Delta, "Stored total must not be written into the update")
+	require.Equal(t, int64(5), counterValue(t, storage, "requests", ""))
+
+	*first.Delta = 100
+	require.Equal(t, int64(5), counterValue(t, storage, "requests", ""), "Storage must not share values with the caller")
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/prbllm/go-metrics/internal/model"
+)
+
+// mergeHistogram добавляет к обновлению накопленные значения существующей гистограммы.
+// Гистограммы с разными границами бакетов не объединяются.
+func mergeHistogram(existing, update *model.Metrics) error {
+	if len(existing.Buckets) != len(update.Buckets) {
+		return fmt.Errorf("%w: metric %s has %d buckets, got %d", ErrBucketsMismatch, update.ID, len(existing.Buckets), len(update.Buckets))
+	}
+	buckets := make([]model.Bucket, len(update.Buckets))
+	for i := range update.Buckets {
+		if existing.Buckets[i].UpperBound != update.Buckets[i].UpperBound {
+			return fmt.Errorf("%w: metric %s bucket %d has bound %g, got %g", ErrBucketsMismatch, update.ID, i, existing.Buckets[i].UpperBound, update.Buckets[i].UpperBound)
+		}
+		buckets[i] = model.Bucket{
+			UpperBound: update.Buckets[i].UpperBound,
+			Count:      existing.Buckets[i].Count + update.Buckets[i].Count,
+		}
+	}
+	update.Buckets = buckets
+	mergeCountAndSum(existing, update)
+	return nil
+}
+
+// mergeSummary суммирует количество и сумму наблюдений, квантили берутся из последнего обновления.
+func mergeSummary(existing, update *model.Metrics) {
+	mergeCountAndSum(existing, update)
+	if len(update.Quantiles) == 0 {
+		update.Quantiles = existing.Quantiles
+	}
+}
+
+func mergeCountAndSum(existing, update *model.Metrics) {
+	if existing.Count != nil && update.Count != nil {
+		count := *existing.Count + *update.Count
+		update.Count = &count
+	}
+	if existing.Sum != nil && update.Sum != nil {
+		sum := *existing.Sum + *update.Sum
+		update.Sum = &sum
+	}
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/prbllm/go-metrics/internal/model"
+)
+
+const (
+	walOpUpdate = "update"
+	walOpDelete = "delete"
+	walOpBatch  = "batch"
+)
+
+// walRecord - одна операция журнала. Tenant хранится отдельно, потому что метрика
+// не сериализует его в JSON. Пакет обновлений записывается одной записью в Batch,
+// чтобы после падения он восстанавливался целиком или не восстанавливался совсем.
+type walRecord struct {
+	Seq    uint64           `json:"seq"`
+	Op     string           `json:"op"`
+	Tenant string           `json:"tenant,omitempty"`
+	Metric *model.Metrics   `json:"metric,omitempty"`
+	Batch  []snapshotMetric `json:"batch,omitempty"`
+}
+
+// wal - журнал упреждающей записи. Каждая запись - строка вида "<crc32> <json>\n",
+// где crc32 - контрольная сумма JSON в шестнадцатеричном виде. Запись, оборванная
+// при падении процесса, или запись с неверной суммой считается концом журнала.
+type wal struct {
+	file *os.File
+	sync bool
+	size int64
+}
+
+// openWAL открывает журнал и возвращает его корректные записи. Все, что идет после
+// первой поврежденной записи, отрезается, чтобы новые записи не оказались за мусором.
+func openWAL(path string, sync bool) (*wal, []walRecord, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o640)
+	if err != nil {
+		return nil, nil, err
+	}
+	records, valid, err := readWAL(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if info.Size() > valid {
+		fmt.Printf("Discarding %d bytes of damaged write-ahead log tail in %s\n", info.Size()-valid, path)
+		if err := file.Truncate(valid); err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+	}
+	if _, err := file.Seek(valid, io.SeekStart); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return &wal{file: file, sync: sync, size: valid}, records, nil
+}
+
+// readWAL читает записи до первой поврежденной и возвращает длину корректной части.
+func readWAL(r io.Reader) ([]walRecord, int64, error) {
+	var records []walRecord
+	var valid int64
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return records, valid, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		record, ok := decodeWALRecord(line)
+		if !ok {
+			return records, valid, nil
+		}
+		records = append(records, record)
+		valid += int64(len(line))
+	}
+}
+
+func decodeWALRecord(line []byte) (walRecord, bool) {
+	var record walRecord
+	sum, payload, ok := bytes.Cut(bytes.TrimSuffix(line, []byte("\n")), []byte(" "))
+	if !ok {
+		return record, false
+	}
+	expected, err := strconv.ParseUint(string(sum), 16, 32)
+	if err != nil || uint32(expected) != crc32.ChecksumIEEE(payload) {
+		return record, false
+	}
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return record, false
+	}
+	if record.Op == walOpBatch {
+		for _, entry := range record.Batch {
+			if entry.Metric == nil {
+				return record, false
+			}
+		}
+		return record, len(record.Batch) > 0
+	}
+	if record.Metric == nil {
+		return record, false
+	}
+	return record, true
+}
+
+// encodeWALRecord кодирует запись в строку журнала.
+func encodeWALRecord(record walRecord) ([]byte, error) {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	line := make([]byte, 0, len(payload)+10)
+	line = fmt.Appendf(line, "%08x ", crc32.ChecksumIEEE(payload))
+	line = append(line, payload...)
+	return append(line, '\n'), nil
+}
+
+// append дописывает строку одним вызовом write. Если запись не удалась целиком,
+// журнал обрезается до прежней длины, чтобы следующие записи не оказались за
+// поврежденной строкой.
+func (w *wal) append(line []byte) error {
+	if _, err := w.file.Write(line); err != nil {
+		if truncErr := w.file.Truncate(w.size); truncErr == nil {
+			w.file.Seek(w.size, io.SeekStart)
+		}
+		return err
+	}
+	w.size += int64(len(line))
+	if w.sync {
+		return w.file.Sync()
+	}
+	return nil
+}
+
+// reset очищает журнал после снимка, который уже содержит все его записи.
+func (w *wal) reset() error {
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	w.size = 0
+	_, err := w.file.Seek(0, io.SeekStart)
+	return err
+}
+
+func (w *wal) close() error {
+	return w.file.Close()
+}
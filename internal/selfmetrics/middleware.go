@@ -0,0 +1,40 @@
+package selfmetrics
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Middleware считает запросы и ошибки по шаблону маршрута chi, чтобы значения
+// параметров пути не порождали новые ряды.
+func (r *Registry) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, req)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(req.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		r.Inc(HTTPRequestsTotal, map[string]string{
+			"handler": route,
+			"method":  req.Method,
+			"code":    strconv.Itoa(recorder.status),
+		})
+		if recorder.status >= http.StatusBadRequest {
+			r.Inc(HTTPRequestErrors, map[string]string{"handler": route, "method": req.Method})
+		}
+	})
+}
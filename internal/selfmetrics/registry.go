@@ -0,0 +1,185 @@
+package selfmetrics
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prbllm/go-metrics/internal/model"
+)
+
+const (
+	HTTPRequestsTotal    = model.SelfMetricPrefix + "http_requests_total"
+	HTTPRequestErrors    = model.SelfMetricPrefix + "http_request_errors_total"
+	IngestedSamplesTotal = model.SelfMetricPrefix + "ingested_samples_total"
+	ThrottledRequests    = model.SelfMetricPrefix + "throttled_requests_total"
+	AuditDroppedEvents   = model.SelfMetricPrefix + "audit_dropped_events_total"
+	IngestionRate        = model.SelfMetricPrefix + "ingestion_rate"
+	UptimeSeconds        = model.SelfMetricPrefix + "uptime_seconds"
+	Goroutines           = model.SelfMetricPrefix + "go_goroutines"
+	HeapAllocBytes       = model.SelfMetricPrefix + "go_heap_alloc_bytes"
+	HeapObjects          = model.SelfMetricPrefix + "go_heap_objects"
+	GCCount              = model.SelfMetricPrefix + "go_gc_count"
+	GCPauseTotalSeconds  = model.SelfMetricPrefix + "go_gc_pause_total_seconds"
+)
+
+// ingestionRateWindow - окно в секундах, по которому считается средняя скорость приема.
+const ingestionRateWindow = 60
+
+type counter struct {
+	id     string
+	labels map[string]string
+	value  int64
+}
+
+type gaugeFunc struct {
+	id     string
+	labels map[string]string
+	fn     func() float64
+}
+
+// Registry хранит метрики о работе самого сервера. Метрики не пишутся в репозиторий
+// на каждый запрос, а собираются в момент чтения через Collect.
+type Registry struct {
+	mu       sync.Mutex
+	counters map[string]*counter
+	gauges   []gaugeFunc
+	rate     *rateWindow
+	start    time.Time
+	now      func() time.Time
+}
+
+func NewRegistry() *Registry {
+	r := &Registry{
+		counters: make(map[string]*counter),
+		start:    time.Now(),
+		now:      time.Now,
+	}
+	r.rate = newRateWindow(ingestionRateWindow)
+	r.GaugeFunc(IngestionRate, nil, func() float64 {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.rate.perSecond(r.now())
+	})
+	r.GaugeFunc(UptimeSeconds, nil, func() float64 {
+		return r.now().Sub(r.start).Seconds()
+	})
+	r.GaugeFunc(Goroutines, nil, func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	return r
+}
+
+func (r *Registry) Add(id string, labels map[string]string, delta int64) {
+	key := id + model.FormatLabels(labels)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.counters[key]
+	if !ok {
+		c = &counter{id: id, labels: labels}
+		r.counters[key] = c
+	}
+	c.value += delta
+}
+
+func (r *Registry) Inc(id string, labels map[string]string) {
+	r.Add(id, labels, 1)
+}
+
+// ObserveIngested учитывает успешно записанные сэмплы для счетчика и скорости приема.
+func (r *Registry) ObserveIngested(n int) {
+	r.Add(IngestedSamplesTotal, nil, int64(n))
+	r.mu.Lock()
+	r.rate.add(r.now(), n)
+	r.mu.Unlock()
+}
+
+// ObserveThrottled учитывает запрос, отклоненный лимитом частоты класса маршрутов class.
+func (r *Registry) ObserveThrottled(class string) {
+	r.Inc(ThrottledRequests, map[string]string{"class": class})
+}
+
+// GaugeFunc регистрирует gauge, значение которого вычисляется при каждом сборе.
+func (r *Registry) GaugeFunc(id string, labels map[string]string, fn func() float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gauges = append(r.gauges, gaugeFunc{id: id, labels: labels, fn: fn})
+}
+
+func (r *Registry) Collect() []*model.Metrics {
+	r.mu.Lock()
+	metrics := make([]*model.Metrics, 0, len(r.counters)+len(r.gauges)+4)
+	for _, c := range r.counters {
+		delta := c.value
+		metrics = append(metrics, &model.Metrics{ID: c.id, MType: model.Counter, Delta: &delta, Labels: c.labels})
+	}
+	gauges := make([]gaugeFunc, len(r.gauges))
+	copy(gauges, r.gauges)
+	r.mu.Unlock()
+
+	for _, g := range gauges {
+		value := g.fn()
+		metrics = append(metrics, &model.Metrics{ID: g.id, MType: model.Gauge, Value: &value, Labels: g.labels})
+	}
+	metrics = append(metrics, collectRuntime()...)
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].FullID() < metrics[j].FullID()
+	})
+	return metrics
+}
+
+func collectRuntime() []*model.Metrics {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	gauges := []struct {
+		id    string
+		value float64
+	}{
+		{HeapAllocBytes, float64(memStats.HeapAlloc)},
+		{HeapObjects, float64(memStats.HeapObjects)},
+		{GCCount, float64(memStats.NumGC)},
+		{GCPauseTotalSeconds, float64(memStats.PauseTotalNs) / float64(time.Second)},
+	}
+	metrics := make([]*model.Metrics, 0, len(gauges))
+	for _, g := range gauges {
+		value := g.value
+		metrics = append(metrics, &model.Metrics{ID: g.id, MType: model.Gauge, Value: &value})
+	}
+	return metrics
+}
+
+// rateWindow считает события по секундам в скользящем окне фиксированной длины.
+type rateWindow struct {
+	seconds []int64
+	counts  []int
+}
+
+func newRateWindow(size int) *rateWindow {
+	return &rateWindow{seconds: make([]int64, size), counts: make([]int, size)}
+}
+
+func (w *rateWindow) add(now time.Time, n int) {
+	sec := now.Unix()
+	idx := int(sec % int64(len(w.seconds)))
+	if w.seconds[idx] != sec {
+		w.seconds[idx] = sec
+		w.counts[idx] = 0
+	}
+	w.counts[idx] += n
+}
+
+func (w *rateWindow) perSecond(now time.Time) float64 {
+	sec := now.Unix()
+	total := 0
+	for i := range w.seconds {
+		if sec-w.seconds[i] < int64(len(w.seconds)) {
+			total += w.counts[i]
+		}
+	}
+	return float64(total) / float64(len(w.seconds))
+}
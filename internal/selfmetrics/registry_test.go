@@ -0,0 +1,74 @@
+package selfmetrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func findMetric(metrics []*model.Metrics, fullID string) *model.Metrics {
+	for _, metric := range metrics {
+		if metric.FullID() == fullID {
+			return metric
+		}
+	}
+	return nil
+}
+
+func TestRegistryMiddleware(t *testing.T) {
+	registry := NewRegistry()
+	router := chi.NewRouter()
+	router.Use(registry.Middleware)
+	router.Get("/value/{metricType}/{metricName}", func(w http.ResponseWriter, r *http.Request) {
+		if chi.URLParam(r, "metricName") == "missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("1"))
+	})
+
+	for _, path := range []string{"/value/gauge/a", "/value/gauge/b", "/value/gauge/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	metrics := registry.Collect()
+	ok := findMetric(metrics, HTTPRequestsTotal+`{code="200",handler="/value/{metricType}/{metricName}",method="GET"}`)
+	require.NotNil(t, ok, "Requests must be counted by route pattern")
+	require.Equal(t, int64(2), *ok.Delta)
+
+	errors := findMetric(metrics, HTTPRequestErrors+`{handler="/value/{metricType}/{metricName}",method="GET"}`)
+	require.NotNil(t, errors)
+	require.Equal(t, int64(1), *errors.Delta)
+}
+
+func TestRegistryIngestion(t *testing.T) {
+	registry := NewRegistry()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	registry.now = func() time.Time { return now }
+
+	registry.ObserveIngested(30)
+	now = now.Add(time.Second)
+	registry.ObserveIngested(30)
+
+	metrics := registry.Collect()
+	total := findMetric(metrics, IngestedSamplesTotal)
+	require.NotNil(t, total)
+	require.Equal(t, int64(60), *total.Delta)
+
+	rate := findMetric(metrics, IngestionRate)
+	require.NotNil(t, rate)
+	require.Equal(t, 1.0, *rate.Value)
+
+	now = now.Add(2 * time.Minute)
+	rate = findMetric(registry.Collect(), IngestionRate)
+	require.Equal(t, 0.0, *rate.Value, "Old samples must leave the window")
+
+	for _, id := range []string{HeapAllocBytes, GCCount, Goroutines, UptimeSeconds} {
+		require.NotNil(t, findMetric(metrics, id), "Metric %s must be collected", id)
+	}
+}
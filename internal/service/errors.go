@@ -0,0 +1,16 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/prbllm/go-metrics/internal/repository"
+)
+
+// IsRejected сообщает, что метрика отклонена из-за своего содержимого или лимитов
+// хранилища, а не из-за сбоя хранилища. Протоколы с частичной записью отклоняют
+// только такую метрику и продолжают обработку запроса; повтор запроса ее не примет.
+func IsRejected(err error) bool {
+	return errors.Is(err, ErrInvalidMetricName) ||
+		errors.Is(err, repository.ErrCardinalityLimit) ||
+		errors.Is(err, repository.ErrBucketsMismatch)
+}
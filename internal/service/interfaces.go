@@ -1,9 +1,20 @@
 package service
 
-import "github.com/prbllm/go-metrics/internal/model"
+import (
+	"context"
+
+	"github.com/prbllm/go-metrics/internal/model"
+)
 
 type Service interface {
-	UpdateMetric(metricType, metricName, metricValue string) error
-	GetMetric(metricType, metricName string) (*model.Metrics, error)
-	GetAllMetrics() ([]*model.Metrics, error)
+	UpdateMetric(metricType, metricName, metricValue string, labels map[string]string) error
+	SaveMetric(metric *model.Metrics) (*model.Metrics, error)
+	SaveMetrics(metrics []*model.Metrics) ([]*model.Metrics, error)
+	GetMetric(metricType, metricName string, labels map[string]string) (*model.Metrics, error)
+	GetAllMetrics(matchers ...*model.LabelMatcher) ([]*model.Metrics, error)
+	DeleteMetric(metricType, metricName string, labels map[string]string) error
+	Ping(ctx context.Context) error
+	// ForTenant возвращает сервис, который читает и пишет только ряды арендатора tenant.
+	// Пустое значение - пространство по умолчанию.
+	ForTenant(tenant string) Service
 }
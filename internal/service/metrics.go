@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 
@@ -9,25 +10,77 @@ import (
 )
 
 type MetricsService struct {
-	repository repository.MetricsRepository
+	repository       repository.MetricsRepository
+	histogramBuckets []float64
+	naming           *NamingPolicy
+	onIngested       func(n int)
+	tenant           string
 }
 
-func NewMetricsService(repository repository.MetricsRepository) Service {
-	return &MetricsService{repository: repository}
+type Option func(*MetricsService)
+
+// WithHistogramBuckets задает границы бакетов для гистограмм, которые создаются
+// из одиночных наблюдений текстового API.
+func WithHistogramBuckets(bounds []float64) Option {
+	return func(s *MetricsService) {
+		s.histogramBuckets = bounds
+	}
+}
+
+// WithNamingPolicy задает правила проверки имен метрик и меток.
+func WithNamingPolicy(policy *NamingPolicy) Option {
+	return func(s *MetricsService) {
+		s.naming = policy
+	}
+}
+
+// WithIngestionObserver задает функцию, которая вызывается после каждой успешной записи
+// с количеством записанных метрик.
+func WithIngestionObserver(observer func(n int)) Option {
+	return func(s *MetricsService) {
+		s.onIngested = observer
+	}
 }
 
-func (s *MetricsService) GetMetric(metricType, metricName string) (*model.Metrics, error) {
+func NewMetricsService(repository repository.MetricsRepository, opts ...Option) Service {
+	s := &MetricsService{
+		repository:       repository,
+		histogramBuckets: model.DefaultHistogramBuckets,
+		naming:           DefaultNamingPolicy(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *MetricsService) GetMetric(metricType, metricName string, labels map[string]string) (*model.Metrics, error) {
+	name, err := s.naming.NormalizeName(metricName)
+	if err != nil {
+		return nil, err
+	}
+	labels, err = s.naming.NormalizeLabels(metricType, labels)
+	if err != nil {
+		return nil, err
+	}
 	metric := &model.Metrics{
-		MType: metricType,
-		ID:    metricName,
+		MType:  metricType,
+		ID:     name,
+		Labels: labels,
+		Tenant: s.tenant,
 	}
 	return s.repository.GetMetric(metric)
 }
 
-func (s *MetricsService) UpdateMetric(metricType, metricName, metricValue string) error {
+func (s *MetricsService) UpdateMetric(metricType, metricName, metricValue string, labels map[string]string) error {
 	metric := &model.Metrics{
-		MType: metricType,
-		ID:    metricName,
+		MType:  metricType,
+		ID:     metricName,
+		Labels: labels,
+		Tenant: s.tenant,
+	}
+	if err := s.naming.NormalizeForWrite(metric); err != nil {
+		return err
 	}
 	switch metricType {
 	case model.Counter:
@@ -42,10 +95,118 @@ func (s *MetricsService) UpdateMetric(metricType, metricName, metricValue string
 			return fmt.Errorf("invalid metric value: %w", err)
 		}
 		metric.Value = &value
+	case model.Histogram:
+		value, err := strconv.ParseFloat(metricValue, 64)
+		if err != nil {
+			return fmt.Errorf("invalid metric value: %w", err)
+		}
+		s.observe(metric, value)
+	default:
+		return fmt.Errorf("metric type %s cannot be updated with a single value", metricType)
+	}
+	if err := s.repository.UpdateMetric(metric); err != nil {
+		return err
+	}
+	s.ingested(1)
+	return nil
+}
+
+func (s *MetricsService) SaveMetric(metric *model.Metrics) (*model.Metrics, error) {
+	if err := ValidateMetric(metric); err != nil {
+		return nil, err
+	}
+	if err := s.naming.NormalizeForWrite(metric); err != nil {
+		return nil, err
+	}
+	metric.Tenant = s.tenant
+	if err := s.repository.UpdateMetric(metric); err != nil {
+		return nil, err
+	}
+	s.ingested(1)
+	return s.repository.GetMetric(metric)
+}
+
+// SaveMetrics сохраняет пакет метрик. Сначала проверяется весь пакет, затем он
+// записывается атомарно, поэтому при любой ошибке ни одна метрика не записывается
+// и клиент может повторить пакет целиком.
+func (s *MetricsService) SaveMetrics(metrics []*model.Metrics) ([]*model.Metrics, error) {
+	for i, metric := range metrics {
+		if err := ValidateMetric(metric); err != nil {
+			return nil, fmt.Errorf("metric #%d: %w", i, err)
+		}
+		if err := s.naming.NormalizeForWrite(metric); err != nil {
+			return nil, fmt.Errorf("metric #%d: %w", i, err)
+		}
+		metric.Tenant = s.tenant
+	}
+
+	if err := s.repository.UpdateMetrics(metrics); err != nil {
+		return nil, err
+	}
+	s.ingested(len(metrics))
+	saved := make([]*model.Metrics, 0, len(metrics))
+	for _, metric := range metrics {
+		stored, err := s.repository.GetMetric(metric)
+		if err != nil {
+			return nil, err
+		}
+		saved = append(saved, stored)
 	}
-	return s.repository.UpdateMetric(metric)
+	return saved, nil
 }
 
-func (s *MetricsService) GetAllMetrics() ([]*model.Metrics, error) {
-	return s.repository.GetAllMetrics(), nil
+func (s *MetricsService) GetAllMetrics(matchers ...*model.LabelMatcher) ([]*model.Metrics, error) {
+	metrics := s.repository.GetAllMetrics()
+	filtered := make([]*model.Metrics, 0, len(metrics))
+	for _, metric := range metrics {
+		if metric.Tenant == s.tenant && model.MatchLabels(metric.Labels, matchers) {
+			filtered = append(filtered, metric)
+		}
+	}
+	return filtered, nil
+}
+
+func (s *MetricsService) DeleteMetric(metricType, metricName string, labels map[string]string) error {
+	name, err := s.naming.NormalizeName(metricName)
+	if err != nil {
+		return err
+	}
+	if err := s.naming.CheckReserved(name); err != nil {
+		return err
+	}
+	labels, err = s.naming.NormalizeLabels(metricType, labels)
+	if err != nil {
+		return err
+	}
+	return s.repository.DeleteMetric(&model.Metrics{MType: metricType, ID: name, Labels: labels, Tenant: s.tenant})
+}
+
+// observe заполняет гистограмму одним наблюдением value.
+func (s *MetricsService) observe(metric *model.Metrics, value float64) {
+	count := uint64(1)
+	metric.Count = &count
+	metric.Sum = &value
+	metric.Buckets = make([]model.Bucket, len(s.histogramBuckets))
+	for i, bound := range s.histogramBuckets {
+		metric.Buckets[i].UpperBound = bound
+		if value <= bound {
+			metric.Buckets[i].Count = 1
+		}
+	}
+}
+
+func (s *MetricsService) Ping(ctx context.Context) error {
+	return s.repository.Ping(ctx)
+}
+
+func (s *MetricsService) ForTenant(tenant string) Service {
+	scoped := *s
+	scoped.tenant = tenant
+	return &scoped
+}
+
+func (s *MetricsService) ingested(n int) {
+	if s.onIngested != nil {
+		s.onIngested(n)
+	}
 }
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"fmt"
+	"math"
 	"sort"
 	"strconv"
 	"testing"
@@ -36,10 +38,10 @@ func TestMetricsService_UpdateMetric(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			err := service.UpdateMetric(test.metricType, test.metricName, test.metricValue)
+			err := service.UpdateMetric(test.metricType, test.metricName, test.metricValue, nil)
 			require.NoError(t, err, "Update failed")
 
-			metric, err := service.GetMetric(test.metricType, test.metricName)
+			metric, err := service.GetMetric(test.metricType, test.metricName, nil)
 			require.NoError(t, err, "Get failed")
 			if test.metricType == model.Gauge {
 				expectedValue, err := strconv.ParseFloat(test.metricValue, 64)
@@ -61,13 +63,13 @@ func TestMetricsService_CounterAccumulation(t *testing.T) {
 	const metricName = "test_counter"
 	const metricValue = "5"
 	const expectedDelta = int64(10)
-	err := service.UpdateMetric(model.Counter, metricName, metricValue)
+	err := service.UpdateMetric(model.Counter, metricName, metricValue, nil)
 	require.NoError(t, err, "First update failed")
 
-	err = service.UpdateMetric(model.Counter, metricName, metricValue)
+	err = service.UpdateMetric(model.Counter, metricName, metricValue, nil)
 	require.NoError(t, err, "Second update failed")
 
-	metric, err := service.GetMetric(model.Counter, metricName)
+	metric, err := service.GetMetric(model.Counter, metricName, nil)
 	require.NoError(t, err, "Get failed")
 	require.Equal(t, expectedDelta, *metric.Delta, "Delta is not equal to expected")
 }
@@ -80,13 +82,13 @@ func TestMetricsService_GaugeReplacement(t *testing.T) {
 	const metricValue = "10.5"
 	const newMetricValue = "20.7"
 
-	err := service.UpdateMetric(model.Gauge, metricName, metricValue)
+	err := service.UpdateMetric(model.Gauge, metricName, metricValue, nil)
 	require.NoError(t, err, "First update failed")
 
-	err = service.UpdateMetric(model.Gauge, metricName, newMetricValue)
+	err = service.UpdateMetric(model.Gauge, metricName, newMetricValue, nil)
 	require.NoError(t, err, "Second update failed")
 
-	metric, err := service.GetMetric(model.Gauge, metricName)
+	metric, err := service.GetMetric(model.Gauge, metricName, nil)
 	require.NoError(t, err, "Get failed")
 	expectedValue, err := strconv.ParseFloat(newMetricValue, 64)
 	require.NoError(t, err)
@@ -103,8 +105,8 @@ func TestMetricsService_GetAllMetrics(t *testing.T) {
 		{ID: "test_gauge", MType: model.Gauge, Value: &expectedValue},
 		{ID: "test_counter", MType: model.Counter, Delta: &expectedDelta},
 	}
-	service.UpdateMetric(model.Gauge, expectedMetrics[0].ID, strconv.FormatFloat(expectedValue, 'f', -1, 64))
-	service.UpdateMetric(model.Counter, expectedMetrics[1].ID, strconv.FormatInt(expectedDelta, 10))
+	service.UpdateMetric(model.Gauge, expectedMetrics[0].ID, strconv.FormatFloat(expectedValue, 'f', -1, 64), nil)
+	service.UpdateMetric(model.Counter, expectedMetrics[1].ID, strconv.FormatInt(expectedDelta, 10), nil)
 
 	metrics, err := service.GetAllMetrics()
 	require.NoError(t, err, "Get all metrics failed")
@@ -130,15 +132,230 @@ func TestMetricsService_GetMetric(t *testing.T) {
 	expectedValue := float64(10.5)
 
 	expectedMetric := &model.Metrics{MType: model.Gauge, ID: "test_gauge", Value: &expectedValue}
-	service.UpdateMetric(model.Gauge, expectedMetric.ID, strconv.FormatFloat(expectedValue, 'f', -1, 64))
-	metric, err := service.GetMetric(model.Gauge, expectedMetric.ID)
+	service.UpdateMetric(model.Gauge, expectedMetric.ID, strconv.FormatFloat(expectedValue, 'f', -1, 64), nil)
+	metric, err := service.GetMetric(model.Gauge, expectedMetric.ID, nil)
 	require.NoError(t, err, "Get metric failed")
 	require.Equal(t, metric, expectedMetric, "Metric is not equal to expected")
 
 	expectedDelta := int64(10)
 	expectedMetric = &model.Metrics{MType: model.Counter, ID: "test_counter", Delta: &expectedDelta}
-	service.UpdateMetric(model.Counter, expectedMetric.ID, strconv.FormatInt(expectedDelta, 10))
-	metric, err = service.GetMetric(model.Counter, expectedMetric.ID)
+	service.UpdateMetric(model.Counter, expectedMetric.ID, strconv.FormatInt(expectedDelta, 10), nil)
+	metric, err = service.GetMetric(model.Counter, expectedMetric.ID, nil)
 	require.NoError(t, err, "Get metric failed")
 	require.Equal(t, metric, expectedMetric, "Metric is not equal to expected")
 }
+
+func TestMetricsService_Labels(t *testing.T) {
+	storage := repository.NewMemStorage()
+	service := NewMetricsService(storage)
+
+	const metricName = "requests"
+	require.NoError(t, service.UpdateMetric(model.Counter, metricName, "1", map[string]string{"host": "a", "service": "api"}))
+	require.NoError(t, service.UpdateMetric(model.Counter, metricName, "2", map[string]string{"service": "api", "host": "a"}))
+	require.NoError(t, service.UpdateMetric(model.Counter, metricName, "5", map[string]string{"host": "b", "service": "api"}))
+	require.NoError(t, service.UpdateMetric(model.Counter, metricName, "7", nil))
+
+	metric, err := service.GetMetric(model.Counter, metricName, map[string]string{"host": "a", "service": "api"})
+	require.NoError(t, err, "Get failed")
+	require.Equal(t, int64(3), *metric.Delta, "Labels order must not affect storage key")
+
+	metric, err = service.GetMetric(model.Counter, metricName, nil)
+	require.NoError(t, err, "Get failed")
+	require.Equal(t, int64(7), *metric.Delta, "Unlabeled metric must be stored separately")
+
+	_, err = service.GetMetric(model.Counter, metricName, map[string]string{"host": "c"})
+	require.Error(t, err, "Expected not found error")
+
+	matcher, err := model.ParseLabelMatcher(`host="b"`)
+	require.NoError(t, err)
+	metrics, err := service.GetAllMetrics(matcher)
+	require.NoError(t, err, "Get all metrics failed")
+	require.Len(t, metrics, 1)
+	require.Equal(t, int64(5), *metrics[0].Delta)
+
+	matcher, err = model.ParseLabelMatcher("service=~ap.*")
+	require.NoError(t, err)
+	metrics, err = service.GetAllMetrics(matcher)
+	require.NoError(t, err, "Get all metrics failed")
+	require.Len(t, metrics, 2)
+}
+
+func TestMetricsService_Histogram(t *testing.T) {
+	storage := repository.NewMemStorage()
+	service := NewMetricsService(storage, WithHistogramBuckets([]float64{0.1, 1, 10}))
+
+	const metricName = "latency"
+	for _, value := range []string{"0.05", "0.5", "5", "50"} {
+		require.NoError(t, service.UpdateMetric(model.Histogram, metricName, value, nil), "Update failed")
+	}
+
+	metric, err := service.GetMetric(model.Histogram, metricName, nil)
+	require.NoError(t, err, "Get failed")
+	require.Equal(t, uint64(4), *metric.Count)
+	require.InDelta(t, 55.55, *metric.Sum, 1e-9)
+	require.Equal(t, []model.Bucket{{UpperBound: 0.1, Count: 1}, {UpperBound: 1, Count: 2}, {UpperBound: 10, Count: 3}}, metric.Buckets)
+
+	count := uint64(2)
+	sum := 0.3
+	updated, err := service.SaveMetric(&model.Metrics{
+		ID: metricName, MType: model.Histogram, Count: &count, Sum: &sum,
+		Buckets: []model.Bucket{{UpperBound: 0.1, Count: 1}, {UpperBound: 1, Count: 2}, {UpperBound: 10, Count: 2}},
+	})
+	require.NoError(t, err, "Save failed")
+	require.Equal(t, uint64(6), *updated.Count)
+	require.Equal(t, []model.Bucket{{UpperBound: 0.1, Count: 2}, {UpperBound: 1, Count: 4}, {UpperBound: 10, Count: 5}}, updated.Buckets)
+
+	_, err = service.SaveMetric(&model.Metrics{
+		ID: metricName, MType: model.Histogram, Count: &count, Sum: &sum,
+		Buckets: []model.Bucket{{UpperBound: 0.5, Count: 1}, {UpperBound: 1, Count: 2}, {UpperBound: 10, Count: 2}},
+	})
+	require.ErrorIs(t, err, repository.ErrBucketsMismatch)
+
+	_, err = service.SaveMetric(&model.Metrics{
+		ID: metricName, MType: model.Histogram, Count: &count, Sum: &sum,
+		Buckets: []model.Bucket{{UpperBound: 0.1, Count: 2}, {UpperBound: 1, Count: 1}},
+	})
+	require.Error(t, err, "Expected validation error for non-cumulative buckets")
+}
+
+func TestMetricsService_Summary(t *testing.T) {
+	storage := repository.NewMemStorage()
+	service := NewMetricsService(storage)
+
+	const metricName = "rpc_duration"
+	count := uint64(10)
+	sum := 2.5
+	_, err := service.SaveMetric(&model.Metrics{
+		ID: metricName, MType: model.Summary, Count: &count, Sum: &sum,
+		Quantiles: []model.Quantile{{Quantile: 0.5, Value: 0.2}, {Quantile: 0.99, Value: 0.9}},
+	})
+	require.NoError(t, err, "First save failed")
+
+	updated, err := service.SaveMetric(&model.Metrics{
+		ID: metricName, MType: model.Summary, Count: &count, Sum: &sum,
+		Quantiles: []model.Quantile{{Quantile: 0.5, Value: 0.3}, {Quantile: 0.99, Value: 1.1}},
+	})
+	require.NoError(t, err, "Second save failed")
+	require.Equal(t, uint64(20), *updated.Count)
+	require.Equal(t, 5.0, *updated.Sum)
+	require.Equal(t, []model.Quantile{{Quantile: 0.5, Value: 0.3}, {Quantile: 0.99, Value: 1.1}}, updated.Quantiles)
+
+	_, err = service.SaveMetric(&model.Metrics{
+		ID: metricName, MType: model.Summary, Count: &count, Sum: &sum,
+		Quantiles: []model.Quantile{{Quantile: 1.5, Value: 0.3}},
+	})
+	require.Error(t, err, "Expected validation error for quantile out of range")
+
+	require.Error(t, service.UpdateMetric(model.Summary, metricName, "1", nil), "Summary cannot be updated with a single value")
+}
+
+func TestMetricsService_Tenants(t *testing.T) {
+	storage := repository.NewMemStorage()
+	defaultService := NewMetricsService(storage)
+	teamA := defaultService.ForTenant("team-a")
+	teamB := defaultService.ForTenant("team-b")
+
+	require.NoError(t, defaultService.UpdateMetric(model.Counter, "requests", "1", nil))
+	require.NoError(t, teamA.UpdateMetric(model.Counter, "requests", "10", nil))
+	require.NoError(t, teamA.UpdateMetric(model.Counter, "requests", "5", nil))
+
+	metric, err := defaultService.GetMetric(model.Counter, "requests", nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), *metric.Delta)
+
+	metric, err = teamA.GetMetric(model.Counter, "requests", nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(15), *metric.Delta)
+
+	_, err = teamB.GetMetric(model.Counter, "requests", nil)
+	require.ErrorIs(t, err, repository.ErrMetricNotFound)
+	require.ErrorIs(t, teamB.DeleteMetric(model.Counter, "requests", nil), repository.ErrMetricNotFound)
+
+	metrics, err := teamA.GetAllMetrics()
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	require.Equal(t, "team-a", metrics[0].Tenant)
+
+	metrics, err = defaultService.GetAllMetrics()
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	require.Equal(t, "", metrics[0].Tenant)
+
+	metrics, err = teamB.GetAllMetrics()
+	require.NoError(t, err)
+	require.Empty(t, metrics)
+}
+
+func TestMetricsService_SaveMetricsIsAtomic(t *testing.T) {
+	histogram := func(bounds ...float64) *model.Metrics {
+		count, sum := uint64(1), 1.0
+		buckets := make([]model.Bucket, len(bounds))
+		for i, bound := range bounds {
+			buckets[i] = model.Bucket{UpperBound: bound, Count: 1}
+		}
+		return &model.Metrics{ID: "latency", MType: model.Histogram, Count: &count, Sum: &sum, Buckets: buckets}
+	}
+	counter := func(delta int64) *model.Metrics {
+		return &model.Metrics{ID: "requests", MType: model.Counter, Delta: &delta}
+	}
+
+	tests := []struct {
+		name    string
+		limits  repository.CardinalityLimits
+		batch   []*model.Metrics
+		wantErr error
+	}{
+		{
+			name:    "cardinality limit",
+			limits:  repository.CardinalityLimits{MaxSeries: 2},
+			batch:   []*model.Metrics{counter(5), {ID: "new", MType: model.Counter, Delta: new(int64)}},
+			wantErr: repository.ErrCardinalityLimit,
+		},
+		{
+			name:    "histogram buckets mismatch",
+			batch:   []*model.Metrics{counter(5), histogram(1, 2)},
+			wantErr: repository.ErrBucketsMismatch,
+		},
+		{
+			name:  "infinite bucket bound",
+			batch: []*model.Metrics{counter(5), histogram(1, math.Inf(1))},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			storage := repository.NewLimitedStorage(repository.NewMemStorage(), test.limits)
+			service := NewMetricsService(storage)
+			_, err := service.SaveMetrics([]*model.Metrics{counter(1), histogram(1)})
+			require.NoError(t, err)
+
+			_, err = service.SaveMetrics(test.batch)
+			require.Error(t, err)
+			if test.wantErr != nil {
+				require.ErrorIs(t, err, test.wantErr)
+			}
+			metric, err := service.GetMetric(model.Counter, "requests", nil)
+			require.NoError(t, err)
+			require.Equal(t, int64(1), *metric.Delta, "Rejected batch must not be written partially")
+			require.Equal(t, 2, storage.SeriesCount())
+		})
+	}
+}
+
+func TestIsRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "invalid name", err: fmt.Errorf("metric #1: %w", ErrInvalidMetricName), expected: true},
+		{name: "cardinality limit", err: repository.ErrCardinalityLimit, expected: true},
+		{name: "buckets mismatch", err: repository.ErrBucketsMismatch, expected: true},
+		{name: "storage failure", err: fmt.Errorf("write-ahead log: disk full"), expected: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, IsRejected(test.err))
+		})
+	}
+}
@@ -1,20 +1,50 @@
 package service
 
-import "github.com/prbllm/go-metrics/internal/model"
+import (
+	"context"
+
+	"github.com/prbllm/go-metrics/internal/model"
+)
 
 // MockMetricsService for testing
 type MockMetricsService struct {
 	Error error
 }
 
-func (m *MockMetricsService) UpdateMetric(metricType, metricName, metricValue string) error {
+func (m *MockMetricsService) UpdateMetric(metricType, metricName, metricValue string, labels map[string]string) error {
 	return m.Error
 }
 
-func (m *MockMetricsService) GetMetric(metricType, metricName string) (*model.Metrics, error) {
+func (m *MockMetricsService) SaveMetric(metric *model.Metrics) (*model.Metrics, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	return metric, nil
+}
+
+func (m *MockMetricsService) SaveMetrics(metrics []*model.Metrics) ([]*model.Metrics, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	return metrics, nil
+}
+
+func (m *MockMetricsService) GetMetric(metricType, metricName string, labels map[string]string) (*model.Metrics, error) {
 	return nil, m.Error
 }
 
-func (m *MockMetricsService) GetAllMetrics() ([]*model.Metrics, error) {
+func (m *MockMetricsService) GetAllMetrics(matchers ...*model.LabelMatcher) ([]*model.Metrics, error) {
 	return nil, m.Error
 }
+
+func (m *MockMetricsService) DeleteMetric(metricType, metricName string, labels map[string]string) error {
+	return m.Error
+}
+
+func (m *MockMetricsService) Ping(ctx context.Context) error {
+	return m.Error
+}
+
+func (m *MockMetricsService) ForTenant(tenant string) Service {
+	return m
+}
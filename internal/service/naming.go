@@ -0,0 +1,161 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/prbllm/go-metrics/internal/model"
+)
+
+var ErrInvalidMetricName = errors.New("invalid metric name")
+
+const (
+	DefaultAllowedNameChars = "a-zA-Z0-9_:"
+	DefaultMaxNameLength    = 255
+)
+
+var labelNameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// NamingPolicy описывает правила для имен метрик и меток, принимаемых сервером.
+// По умолчанию правила совпадают с ограничениями Prometheus, чтобы любая принятая
+// метрика могла быть выгружена в его формате.
+type NamingPolicy struct {
+	// AllowedChars - содержимое класса символов регулярного выражения, например "a-zA-Z0-9_:".
+	AllowedChars     string
+	MaxLength        int
+	ReservedPrefixes []string
+	// Sanitize включает автоматическую замену недопустимых символов на "_" и обрезку
+	// слишком длинных имен вместо отклонения метрики.
+	Sanitize bool
+
+	invalidChars *regexp.Regexp
+}
+
+func NewNamingPolicy(allowedChars string, maxLength int, reservedPrefixes []string, sanitize bool) (*NamingPolicy, error) {
+	if allowedChars == "" {
+		return nil, fmt.Errorf("allowed name characters cannot be empty")
+	}
+	invalidChars, err := regexp.Compile("[^" + allowedChars + "]")
+	if err != nil {
+		return nil, fmt.Errorf("invalid allowed name characters %q: %w", allowedChars, err)
+	}
+	if maxLength <= 0 {
+		return nil, fmt.Errorf("max name length must be positive")
+	}
+	return &NamingPolicy{
+		AllowedChars:     allowedChars,
+		MaxLength:        maxLength,
+		ReservedPrefixes: reservedPrefixes,
+		Sanitize:         sanitize,
+		invalidChars:     invalidChars,
+	}, nil
+}
+
+func DefaultNamingPolicy() *NamingPolicy {
+	policy, _ := NewNamingPolicy(DefaultAllowedNameChars, DefaultMaxNameLength, []string{model.SelfMetricPrefix}, false)
+	return policy
+}
+
+// NormalizeName проверяет имя метрики и при включенной санитизации исправляет его.
+// Зарезервированные префиксы здесь не проверяются: читать служебные метрики можно.
+func (p *NamingPolicy) NormalizeName(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("%w: metric name cannot be empty", ErrInvalidMetricName)
+	}
+
+	if loc := p.invalidChars.FindStringIndex(name); loc != nil {
+		if !p.Sanitize {
+			r, _ := utf8.DecodeRuneInString(name[loc[0]:])
+			return "", fmt.Errorf("%w: metric name %q contains invalid character %q at position %d, allowed characters are [%s]",
+				ErrInvalidMetricName, name, r, loc[0], p.AllowedChars)
+		}
+		name = p.invalidChars.ReplaceAllString(name, "_")
+	}
+
+	if name[0] >= '0' && name[0] <= '9' {
+		if !p.Sanitize {
+			return "", fmt.Errorf("%w: metric name %q cannot start with a digit", ErrInvalidMetricName, name)
+		}
+		name = "_" + name
+	}
+
+	if len(name) > p.MaxLength {
+		if !p.Sanitize {
+			return "", fmt.Errorf("%w: metric name %q is %d bytes long, maximum is %d", ErrInvalidMetricName, name, len(name), p.MaxLength)
+		}
+		cut := p.MaxLength
+		for cut > 0 && !utf8.RuneStart(name[cut]) {
+			cut--
+		}
+		name = name[:cut]
+	}
+	return name, nil
+}
+
+// CheckReserved запрещает клиентам писать метрики с зарезервированными префиксами.
+func (p *NamingPolicy) CheckReserved(name string) error {
+	for _, prefix := range p.ReservedPrefixes {
+		if prefix != "" && strings.HasPrefix(name, prefix) {
+			return fmt.Errorf("%w: metric name %q uses reserved prefix %q", ErrInvalidMetricName, name, prefix)
+		}
+	}
+	return nil
+}
+
+// NormalizeLabels проверяет имена меток по правилам Prometheus и возвращает новый набор меток.
+// Метки le и quantile зарезервированы для гистограмм и сводок, имена с "__" - для внутреннего использования.
+func (p *NamingPolicy) NormalizeLabels(metricType string, labels map[string]string) (map[string]string, error) {
+	if len(labels) == 0 {
+		return labels, nil
+	}
+	result := make(map[string]string, len(labels))
+	for _, name := range model.SortedLabelNames(labels) {
+		value := labels[name]
+		normalized := name
+		if normalized == "" {
+			return nil, fmt.Errorf("%w: label name cannot be empty", ErrInvalidMetricName)
+		}
+		if labelNameInvalidChars.MatchString(normalized) || (normalized[0] >= '0' && normalized[0] <= '9') {
+			if !p.Sanitize {
+				return nil, fmt.Errorf("%w: label name %q must match [a-zA-Z_][a-zA-Z0-9_]*", ErrInvalidMetricName, name)
+			}
+			normalized = labelNameInvalidChars.ReplaceAllString(normalized, "_")
+			if normalized[0] >= '0' && normalized[0] <= '9' {
+				normalized = "_" + normalized
+			}
+		}
+		if strings.HasPrefix(normalized, "__") {
+			return nil, fmt.Errorf("%w: label name %q is reserved for internal use", ErrInvalidMetricName, name)
+		}
+		if (metricType == model.Histogram && normalized == "le") || (metricType == model.Summary && normalized == "quantile") {
+			return nil, fmt.Errorf("%w: label name %q is reserved for %s metrics", ErrInvalidMetricName, name, metricType)
+		}
+		if _, exists := result[normalized]; exists {
+			return nil, fmt.Errorf("%w: label %q conflicts with another label after sanitization", ErrInvalidMetricName, name)
+		}
+		result[normalized] = value
+	}
+	return result, nil
+}
+
+// NormalizeForWrite применяет к обновлению все правила, включая зарезервированные префиксы,
+// и заменяет имя и метки metric приведенными.
+func (p *NamingPolicy) NormalizeForWrite(metric *model.Metrics) error {
+	name, err := p.NormalizeName(metric.ID)
+	if err != nil {
+		return err
+	}
+	if err := p.CheckReserved(name); err != nil {
+		return err
+	}
+	labels, err := p.NormalizeLabels(metric.MType, metric.Labels)
+	if err != nil {
+		return err
+	}
+	metric.ID = name
+	metric.Labels = labels
+	return nil
+}
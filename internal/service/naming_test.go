@@ -0,0 +1,91 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamingPolicy_NormalizeName(t *testing.T) {
+	strict := DefaultNamingPolicy()
+	sanitizing, err := NewNamingPolicy(DefaultAllowedNameChars, 10, nil, true)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		policy      *NamingPolicy
+		input       string
+		expected    string
+		errContains string
+	}{
+		{name: "valid", policy: strict, input: "http_requests:rate", expected: "http_requests:rate"},
+		{name: "empty", policy: strict, input: "", errContains: "cannot be empty"},
+		{name: "space", policy: strict, input: "bad name", errContains: `invalid character ' ' at position 3`},
+		{name: "unicode", policy: strict, input: "метрика", errContains: `invalid character 'м' at position 0`},
+		{name: "leading digit", policy: strict, input: "1metric", errContains: "cannot start with a digit"},
+		{name: "too long", policy: strict, input: strings.Repeat("a", 256), errContains: "maximum is 255"},
+		{name: "sanitize chars", policy: sanitizing, input: "a b-c", expected: "a_b_c"},
+		{name: "sanitize digit", policy: sanitizing, input: "1m", expected: "_1m"},
+		{name: "sanitize length", policy: sanitizing, input: "abcdefghijkl", expected: "abcdefghij"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.policy.NormalizeName(test.input)
+			if test.errContains != "" {
+				require.ErrorIs(t, err, ErrInvalidMetricName)
+				require.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, got)
+		})
+	}
+}
+
+func TestNamingPolicy_NormalizeLabels(t *testing.T) {
+	strict := DefaultNamingPolicy()
+
+	labels, err := strict.NormalizeLabels(model.Gauge, map[string]string{"host": "a b"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"host": "a b"}, labels, "Label values must not be changed")
+
+	_, err = strict.NormalizeLabels(model.Gauge, map[string]string{"host-name": "a"})
+	require.ErrorIs(t, err, ErrInvalidMetricName)
+	_, err = strict.NormalizeLabels(model.Gauge, map[string]string{"__name__": "a"})
+	require.ErrorIs(t, err, ErrInvalidMetricName)
+	_, err = strict.NormalizeLabels(model.Histogram, map[string]string{"le": "1"})
+	require.ErrorIs(t, err, ErrInvalidMetricName)
+
+	sanitizing, err := NewNamingPolicy(DefaultAllowedNameChars, DefaultMaxNameLength, nil, true)
+	require.NoError(t, err)
+	labels, err = sanitizing.NormalizeLabels(model.Gauge, map[string]string{"host-name": "a"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"host_name": "a"}, labels)
+	_, err = sanitizing.NormalizeLabels(model.Gauge, map[string]string{"host-name": "a", "host_name": "b"})
+	require.ErrorIs(t, err, ErrInvalidMetricName)
+}
+
+func TestMetricsService_NamingPolicy(t *testing.T) {
+	sanitizing, err := NewNamingPolicy(DefaultAllowedNameChars, DefaultMaxNameLength, []string{model.SelfMetricPrefix}, true)
+	require.NoError(t, err)
+	service := NewMetricsService(repository.NewMemStorage(), WithNamingPolicy(sanitizing))
+
+	require.NoError(t, service.UpdateMetric(model.Gauge, "cpu load", "0.5", nil))
+	metric, err := service.GetMetric(model.Gauge, "cpu_load", nil)
+	require.NoError(t, err)
+	require.Equal(t, 0.5, *metric.Value)
+	_, err = service.GetMetric(model.Gauge, "cpu load", nil)
+	require.NoError(t, err, "Lookup must apply the same sanitization")
+
+	err = service.UpdateMetric(model.Gauge, model.SelfMetricPrefix+"fake", "1", nil)
+	require.ErrorIs(t, err, ErrInvalidMetricName)
+	require.Contains(t, err.Error(), "reserved prefix")
+
+	value := 1.0
+	_, err = service.SaveMetric(&model.Metrics{ID: model.SelfMetricPrefix + "fake", MType: model.Gauge, Value: &value})
+	require.ErrorIs(t, err, ErrInvalidMetricName)
+}
@@ -2,16 +2,18 @@ package service
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 
 	"github.com/prbllm/go-metrics/internal/model"
 )
 
 func ValidateMetricType(metricType string) error {
-	if metricType != model.Counter && metricType != model.Gauge {
-		return fmt.Errorf("invalid metric type")
+	switch metricType {
+	case model.Counter, model.Gauge, model.Histogram, model.Summary:
+		return nil
 	}
-	return nil
+	return fmt.Errorf("invalid metric type")
 }
 
 func ValidateMetricValue(metricType, value string) error {
@@ -21,11 +23,95 @@ func ValidateMetricValue(metricType, value string) error {
 		if err != nil {
 			return fmt.Errorf("%s value must be integer", metricType)
 		}
-	case model.Gauge:
+	case model.Gauge, model.Histogram:
 		_, err := strconv.ParseFloat(value, 64)
 		if err != nil {
 			return fmt.Errorf("%s value must be float", metricType)
 		}
+	case model.Summary:
+		return fmt.Errorf("%s with quantiles can only be sent via JSON API", metricType)
+	}
+	return nil
+}
+
+func ValidateMetric(metric *model.Metrics) error {
+	if metric == nil {
+		return fmt.Errorf("metric is nil")
+	}
+	if metric.ID == "" {
+		return fmt.Errorf("metric id cannot be empty")
+	}
+	if err := ValidateMetricType(metric.MType); err != nil {
+		return err
+	}
+	switch metric.MType {
+	case model.Counter:
+		if metric.Delta == nil {
+			return fmt.Errorf("%s metric %s has no delta", metric.MType, metric.ID)
+		}
+	case model.Gauge:
+		if metric.Value == nil {
+			return fmt.Errorf("%s metric %s has no value", metric.MType, metric.ID)
+		}
+	case model.Histogram:
+		return validateHistogram(metric)
+	case model.Summary:
+		return validateSummary(metric)
+	}
+	return nil
+}
+
+// ValidateHistogramBuckets проверяет границы бакетов. Бакет +Inf не хранится:
+// его значение равно count и добавляется при выдаче в формате Prometheus.
+func ValidateHistogramBuckets(bounds []float64) error {
+	if len(bounds) == 0 {
+		return fmt.Errorf("histogram buckets cannot be empty")
+	}
+	for i, bound := range bounds {
+		if math.IsNaN(bound) {
+			return fmt.Errorf("histogram bucket bound cannot be NaN")
+		}
+		if math.IsInf(bound, 0) {
+			return fmt.Errorf("histogram bucket bound must be finite, +Inf bucket is implied by count")
+		}
+		if i > 0 && bound <= bounds[i-1] {
+			return fmt.Errorf("histogram bucket bounds must be strictly increasing")
+		}
+	}
+	return nil
+}
+
+func validateHistogram(metric *model.Metrics) error {
+	if metric.Count == nil || metric.Sum == nil {
+		return fmt.Errorf("%s metric %s must have count and sum", metric.MType, metric.ID)
+	}
+	bounds := make([]float64, len(metric.Buckets))
+	for i, bucket := range metric.Buckets {
+		bounds[i] = bucket.UpperBound
+		if i > 0 && bucket.Count < metric.Buckets[i-1].Count {
+			return fmt.Errorf("%s metric %s bucket counts must be cumulative", metric.MType, metric.ID)
+		}
+		if bucket.Count > *metric.Count {
+			return fmt.Errorf("%s metric %s bucket count exceeds total count", metric.MType, metric.ID)
+		}
+	}
+	if err := ValidateHistogramBuckets(bounds); err != nil {
+		return fmt.Errorf("%s metric %s: %w", metric.MType, metric.ID, err)
+	}
+	return nil
+}
+
+func validateSummary(metric *model.Metrics) error {
+	if metric.Count == nil || metric.Sum == nil {
+		return fmt.Errorf("%s metric %s must have count and sum", metric.MType, metric.ID)
+	}
+	for i, quantile := range metric.Quantiles {
+		if quantile.Quantile < 0 || quantile.Quantile > 1 || math.IsNaN(quantile.Quantile) {
+			return fmt.Errorf("%s metric %s quantile must be in [0, 1]", metric.MType, metric.ID)
+		}
+		if i > 0 && quantile.Quantile <= metric.Quantiles[i-1].Quantile {
+			return fmt.Errorf("%s metric %s quantiles must be strictly increasing", metric.MType, metric.ID)
+		}
 	}
 	return nil
 }
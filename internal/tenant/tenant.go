@@ -0,0 +1,103 @@
+// Package tenant определяет арендатора запроса. Ряды разных арендаторов хранятся
+// раздельно, и каждый арендатор видит только свои метрики.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Header - заголовок, в котором клиент передает имя арендатора.
+const Header = "X-Tenant-ID"
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+var (
+	// ErrUnknownToken возвращается, если токен не привязан ни к одному арендатору.
+	ErrUnknownToken = errors.New("unknown tenant token")
+	// ErrMissingToken возвращается, если токены заданы, а запрос пришел без токена.
+	ErrMissingToken = errors.New("tenant token required")
+)
+
+type contextKey struct{}
+
+// Resolver определяет арендатора запроса. Если заданы токены, арендатор определяется
+// только по токену из заголовка Authorization: Bearer <token>, а заголовок X-Tenant-ID
+// игнорируется, чтобы нельзя было писать в чужое пространство, а запрос без токена
+// отклоняется. Без токенов арендатор берется из X-Tenant-ID, а запросы без него
+// попадают в пространство по умолчанию.
+type Resolver struct {
+	tokens map[string]string
+}
+
+func NewResolver(tokens map[string]string) (*Resolver, error) {
+	for _, name := range tokens {
+		if err := ValidateName(name); err != nil {
+			return nil, err
+		}
+	}
+	return &Resolver{tokens: tokens}, nil
+}
+
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid tenant %q: must match %s", name, namePattern)
+	}
+	return nil
+}
+
+func (r *Resolver) Resolve(req *http.Request) (string, error) {
+	if len(r.tokens) > 0 {
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			return "", ErrMissingToken
+		}
+		name, ok := r.tokens[strings.TrimSpace(token)]
+		if !ok {
+			return "", ErrUnknownToken
+		}
+		return name, nil
+	}
+
+	name := req.Header.Get(Header)
+	if name == "" {
+		return "", nil
+	}
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// Middleware сохраняет арендатора в контексте запроса. Отсутствующий или неизвестный
+// токен отклоняется с кодом 401, некорректное имя арендатора - с кодом 400.
+func (r *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name, err := r.Resolve(req)
+		if err != nil {
+			fmt.Printf("Error resolving tenant: %v\n", err)
+			status := http.StatusBadRequest
+			if errors.Is(err, ErrUnknownToken) || errors.Is(err, ErrMissingToken) {
+				status = http.StatusUnauthorized
+				w.Header().Set("WWW-Authenticate", `Bearer realm="go-metrics"`)
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(NewContext(req.Context(), name)))
+	})
+}
+
+func NewContext(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, contextKey{}, name)
+}
+
+// FromContext возвращает арендатора из контекста или пустую строку для пространства по умолчанию.
+func FromContext(ctx context.Context) string {
+	name, _ := ctx.Value(contextKey{}).(string)
+	return name
+}
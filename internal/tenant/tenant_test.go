@@ -0,0 +1,93 @@
+package tenant
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolverMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		tokens         map[string]string
+		headers        map[string]string
+		expectedStatus int
+		expectedTenant string
+	}{
+		{
+			name:           "default tenant",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "tenant header",
+			headers:        map[string]string{Header: "team-a"},
+			expectedStatus: http.StatusOK,
+			expectedTenant: "team-a",
+		},
+		{
+			name:           "invalid tenant header",
+			headers:        map[string]string{Header: "team/a"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "token",
+			tokens:         map[string]string{"secret": "team-b"},
+			headers:        map[string]string{"Authorization": "Bearer secret"},
+			expectedStatus: http.StatusOK,
+			expectedTenant: "team-b",
+		},
+		{
+			name:           "header ignored with tokens",
+			tokens:         map[string]string{"secret": "team-b"},
+			headers:        map[string]string{"Authorization": "Bearer secret", Header: "team-a"},
+			expectedStatus: http.StatusOK,
+			expectedTenant: "team-b",
+		},
+		{
+			name:           "missing token",
+			tokens:         map[string]string{"secret": "team-b"},
+			headers:        map[string]string{Header: "team-b"},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "non-bearer authorization",
+			tokens:         map[string]string{"secret": "team-b"},
+			headers:        map[string]string{"Authorization": "Basic c2VjcmV0"},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "unknown token",
+			tokens:         map[string]string{"secret": "team-b"},
+			headers:        map[string]string{"Authorization": "Bearer other", Header: "team-b"},
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resolver, err := NewResolver(tc.tokens)
+			require.NoError(t, err)
+
+			var got string
+			handler := resolver.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = FromContext(r.Context())
+			}))
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			for key, value := range tc.headers {
+				request.Header.Set(key, value)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, request)
+
+			require.Equal(t, tc.expectedStatus, rr.Code)
+			require.Equal(t, tc.expectedTenant, got)
+		})
+	}
+}
+
+func TestNewResolverRejectsInvalidTenant(t *testing.T) {
+	_, err := NewResolver(map[string]string{"secret": "bad tenant"})
+	require.Error(t, err)
+}
@@ -0,0 +1,211 @@
+// Package transfer выгружает все ряды хранилища метрик в JSON Lines и загружает их
+// в другое хранилище. Используются только методы repository.MetricsRepository,
+// поэтому перенос работает между любыми реализациями хранилища.
+package transfer
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/repository"
+	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/prbllm/go-metrics/internal/tenant"
+)
+
+const (
+	// ModeMerge прибавляет значения counter, гистограмм и сводок к существующим рядам.
+	ModeMerge = "merge"
+	// ModeReplace заменяет существующие ряды значениями из выгрузки.
+	ModeReplace = "replace"
+)
+
+// Record - строка выгрузки: метрика и арендатор, которому она принадлежит.
+type Record struct {
+	Tenant string `json:"tenant,omitempty"`
+	*model.Metrics
+}
+
+// Result - итог выгрузки или загрузки. Skipped - ряды, которые не переносятся:
+// метрики самого сервера и значения, непредставимые в JSON (NaN, ±Inf).
+type Result struct {
+	Records int `json:"records"`
+	Skipped int `json:"skipped"`
+}
+
+// ValidateMode проверяет режим загрузки.
+func ValidateMode(mode string) error {
+	if mode != ModeMerge && mode != ModeReplace {
+		return fmt.Errorf("unknown import mode %q, expected %s or %s", mode, ModeMerge, ModeReplace)
+	}
+	return nil
+}
+
+// Export пишет в w ряды storage, для которых keep возвращает true, по одному
+// JSON-объекту на строку. Ряды упорядочены по арендатору, типу и имени с метками.
+// keep == nil выгружает все ряды.
+func Export(w io.Writer, storage repository.MetricsRepository, keep func(tenantName string) bool) (Result, error) {
+	var result Result
+	metrics := storage.GetAllMetrics()
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].Tenant != metrics[j].Tenant {
+			return metrics[i].Tenant < metrics[j].Tenant
+		}
+		if metrics[i].MType != metrics[j].MType {
+			return metrics[i].MType < metrics[j].MType
+		}
+		return metrics[i].FullID() < metrics[j].FullID()
+	})
+
+	buf := bufio.NewWriter(w)
+	for _, metric := range metrics {
+		if keep != nil && !keep(metric.Tenant) {
+			continue
+		}
+		if isSelfMetric(metric, metric.Tenant) {
+			result.Skipped++
+			continue
+		}
+		line, err := json.Marshal(Record{Tenant: metric.Tenant, Metrics: metric})
+		if err != nil {
+			fmt.Printf("Skipping %s of tenant %q in export: %v\n", metric.FullID(), metric.Tenant, err)
+			result.Skipped++
+			continue
+		}
+		buf.Write(line)
+		if err := buf.WriteByte('\n'); err != nil {
+			return result, err
+		}
+		result.Records++
+	}
+	return result, buf.Flush()
+}
+
+// isSelfMetric сообщает, что метрика принадлежит серверу: такие метрики не хранятся
+// в репозитории, а добавляются к выдаче при чтении.
+func isSelfMetric(metric *model.Metrics, tenantName string) bool {
+	return tenantName == "" && strings.HasPrefix(metric.ID, model.SelfMetricPrefix)
+}
+
+// Read читает и проверяет выгрузку целиком, чтобы поврежденный файл не был загружен
+// частично. Имена и метки приводятся по правилам naming так же, как при обычной
+// записи. Выгрузка, сжатая gzip, распознается автоматически. Метрики сервера
+// пропускаются.
+func Read(r io.Reader, naming *service.NamingPolicy) ([]Record, Result, error) {
+	var result Result
+	reader := bufio.NewReader(r)
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, result, err
+		}
+		defer gz.Close()
+		reader = bufio.NewReader(gz)
+	}
+
+	var records []Record
+	decoder := json.NewDecoder(reader)
+	for line := 1; ; line++ {
+		var record Record
+		err := decoder.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, result, fmt.Errorf("record %d: %w", line, err)
+		}
+		if err := validateRecord(record); err != nil {
+			return nil, result, fmt.Errorf("record %d: %w", line, err)
+		}
+		if isSelfMetric(record.Metrics, record.Tenant) {
+			result.Skipped++
+			continue
+		}
+		if err := naming.NormalizeForWrite(record.Metrics); err != nil {
+			return nil, result, fmt.Errorf("record %d: %w", line, err)
+		}
+		record.Metrics.Tenant = record.Tenant
+		records = append(records, record)
+	}
+	result.Records = len(records)
+	return records, result, nil
+}
+
+func validateRecord(record Record) error {
+	if record.Tenant != "" {
+		if err := tenant.ValidateName(record.Tenant); err != nil {
+			return err
+		}
+	}
+	return service.ValidateMetric(record.Metrics)
+}
+
+// Apply записывает ряды в storage одним пакетом, поэтому при ошибке ни один ряд
+// выгрузки не записывается. В режиме ModeMerge ряды записываются как обычные
+// обновления: counter, гистограммы и сводки складываются с существующими, gauge
+// заменяется. В режиме ModeReplace существующие ряды сначала удаляются, а если
+// пакет не записан, возвращаются. Ряды, которых нет в выгрузке, не меняются.
+// Apply возвращает количество записанных рядов.
+func Apply(storage repository.MetricsRepository, records []Record, mode string) (int, error) {
+	if err := ValidateMode(mode); err != nil {
+		return 0, err
+	}
+	metrics := make([]*model.Metrics, len(records))
+	for i, record := range records {
+		metrics[i] = record.Metrics
+	}
+
+	var removed []*model.Metrics
+	if mode == ModeReplace {
+		var err error
+		removed, err = removeExisting(storage, metrics)
+		if err != nil {
+			restore(storage, removed)
+			return 0, err
+		}
+	}
+	if err := storage.UpdateMetrics(metrics); err != nil {
+		restore(storage, removed)
+		return 0, err
+	}
+	return len(records), nil
+}
+
+// removeExisting удаляет существующие ряды metrics и возвращает их прежние значения.
+func removeExisting(storage repository.MetricsRepository, metrics []*model.Metrics) ([]*model.Metrics, error) {
+	var removed []*model.Metrics
+	for i, metric := range metrics {
+		existing, err := storage.GetMetric(metric)
+		if errors.Is(err, repository.ErrMetricNotFound) {
+			continue
+		}
+		if err == nil {
+			existing = existing.Clone()
+			err = storage.DeleteMetric(metric)
+		}
+		if errors.Is(err, repository.ErrMetricNotFound) {
+			continue
+		}
+		if err != nil {
+			return removed, fmt.Errorf("record %d: replace %s: %w", i+1, metric.FullID(), err)
+		}
+		removed = append(removed, existing)
+	}
+	return removed, nil
+}
+
+// restore возвращает ряды, удаленные перед неудачной загрузкой в режиме ModeReplace.
+func restore(storage repository.MetricsRepository, removed []*model.Metrics) {
+	if len(removed) == 0 {
+		return
+	}
+	if err := storage.UpdateMetrics(removed); err != nil {
+		fmt.Printf("Error restoring %d replaced series after failed import: %v\n", len(removed), err)
+	}
+}
@@ -0,0 +1,190 @@
+package transfer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/prbllm/go-metrics/internal/model"
+	"github.com/prbllm/go-metrics/internal/repository"
+	"github.com/prbllm/go-metrics/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func counter(id, tenant string, delta int64) *model.Metrics {
+	return &model.Metrics{ID: id, MType: model.Counter, Delta: &delta, Tenant: tenant}
+}
+
+func histogram(id string, count uint64) *model.Metrics {
+	sum := float64(count)
+	return &model.Metrics{ID: id, MType: model.Histogram, Count: &count, Sum: &sum, Buckets: []model.Bucket{{UpperBound: 1, Count: count}}}
+}
+
+func getMetric(t *testing.T, storage repository.MetricsRepository, metric *model.Metrics) *model.Metrics {
+	t.Helper()
+	stored, err := storage.GetMetric(metric)
+	require.NoError(t, err)
+	return stored
+}
+
+func sourceStorage(t *testing.T) repository.MetricsRepository {
+	t.Helper()
+	storage := repository.NewMemStorage()
+	value := 21.5
+	for _, metric := range []*model.Metrics{
+		counter("requests", "", 10),
+		counter("requests", "team-a", 3),
+		{ID: "temp", MType: model.Gauge, Value: &value, Labels: map[string]string{"room": "a"}},
+		histogram("latency", 4),
+		counter(model.SelfMetricPrefix+"ingested_total", "", 100),
+	} {
+		require.NoError(t, storage.UpdateMetric(metric))
+	}
+	return storage
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	result, err := Export(&buf, sourceStorage(t), nil)
+	require.NoError(t, err)
+	require.Equal(t, Result{Records: 4, Skipped: 1}, result, "server metrics are not exported")
+	require.Equal(t, `{"tenant":"team-a","id":"requests","type":"counter","delta":3}`, strings.Split(strings.TrimSpace(buf.String()), "\n")[3])
+
+	records, result, err := Read(&buf, service.DefaultNamingPolicy())
+	require.NoError(t, err)
+	require.Equal(t, Result{Records: 4}, result)
+
+	// Загрузка идет через интерфейс репозитория, поэтому работает и с хранилищем на диске.
+	target, err := repository.NewDurableStorage(repository.NewMemStorage(), t.TempDir(), false)
+	require.NoError(t, err)
+	t.Cleanup(func() { target.Close() })
+	applied, err := Apply(target, records, ModeMerge)
+	require.NoError(t, err)
+	require.Equal(t, 4, applied)
+
+	require.Len(t, target.GetAllMetrics(), 4)
+	require.Equal(t, int64(10), *getMetric(t, target, counter("requests", "", 0)).Delta)
+	require.Equal(t, int64(3), *getMetric(t, target, counter("requests", "team-a", 0)).Delta)
+	require.Equal(t, 21.5, *getMetric(t, target, &model.Metrics{ID: "temp", MType: model.Gauge, Labels: map[string]string{"room": "a"}}).Value)
+	require.Equal(t, uint64(4), *getMetric(t, target, histogram("latency", 0)).Count)
+}
+
+func TestExportKeepsTenant(t *testing.T) {
+	var buf bytes.Buffer
+	result, err := Export(&buf, sourceStorage(t), func(tenantName string) bool { return tenantName == "team-a" })
+	require.NoError(t, err)
+	require.Equal(t, Result{Records: 1}, result)
+	require.Equal(t, "{\"tenant\":\"team-a\",\"id\":\"requests\",\"type\":\"counter\",\"delta\":3}\n", buf.String())
+}
+
+func TestApplyModes(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		counter   int64
+		histogram uint64
+	}{
+		{name: "merge adds counters and histograms", mode: ModeMerge, counter: 15, histogram: 6},
+		{name: "replace overwrites existing series", mode: ModeReplace, counter: 5, histogram: 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			target := repository.NewLimitedStorage(repository.NewMemStorage(), repository.CardinalityLimits{MaxSeries: 3})
+			require.NoError(t, target.UpdateMetric(counter("requests", "", 10)))
+			require.NoError(t, target.UpdateMetric(histogram("latency", 4)))
+			require.NoError(t, target.UpdateMetric(counter("untouched", "", 1)))
+
+			records := []Record{
+				{Metrics: counter("requests", "", 5)},
+				{Metrics: histogram("latency", 2)},
+			}
+			_, err := Apply(target, records, tc.mode)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.counter, *getMetric(t, target, counter("requests", "", 0)).Delta)
+			require.Equal(t, tc.histogram, *getMetric(t, target, histogram("latency", 0)).Count)
+			require.Equal(t, int64(1), *getMetric(t, target, counter("untouched", "", 0)).Delta, "series missing from the export are kept")
+			require.Equal(t, 3, target.SeriesCount())
+		})
+	}
+
+	_, err := Apply(repository.NewMemStorage(), nil, "overwrite")
+	require.Error(t, err)
+}
+
+func TestApplyIsAtomic(t *testing.T) {
+	tests := []struct {
+		name string
+		mode string
+	}{
+		{name: "merge", mode: ModeMerge},
+		{name: "replace restores removed series", mode: ModeReplace},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			target := repository.NewLimitedStorage(repository.NewMemStorage(), repository.CardinalityLimits{MaxSeries: 2})
+			require.NoError(t, target.UpdateMetric(counter("a", "", 10)))
+			records := []Record{
+				{Metrics: counter("a", "", 1)},
+				{Metrics: counter("b", "", 1)},
+				{Metrics: counter("c", "", 1)},
+			}
+			applied, err := Apply(target, records, tc.mode)
+			require.ErrorIs(t, err, repository.ErrCardinalityLimit)
+			require.Equal(t, 0, applied)
+
+			require.Equal(t, int64(10), *getMetric(t, target, counter("a", "", 0)).Delta, "nothing is written on error")
+			require.Equal(t, 1, target.SeriesCount())
+		})
+	}
+}
+
+func TestReadCompressed(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := Export(gz, sourceStorage(t), nil)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	records, result, err := Read(&buf, service.DefaultNamingPolicy())
+	require.NoError(t, err)
+	require.Equal(t, 4, result.Records)
+	require.Len(t, records, 4)
+	require.Equal(t, "team-a", records[3].Metrics.Tenant)
+}
+
+func TestReadRejectsInvalidRecords(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "malformed json", input: "{\"id\":\"a\",\"type\":\"counter\",\"delta\":1}\n{\"id\":"},
+		{name: "counter without delta", input: `{"id":"a","type":"counter"}`},
+		{name: "unknown type", input: `{"id":"a","type":"meter","value":1}`},
+		{name: "invalid tenant", input: `{"tenant":"team a","id":"a","type":"counter","delta":1}`},
+		{name: "invalid metric name", input: `{"id":"bad name","type":"counter","delta":1}`},
+		{name: "reserved prefix of tenant", input: `{"tenant":"team-a","id":"` + model.SelfMetricPrefix + `up","type":"counter","delta":1}`},
+		{name: "reserved label", input: `{"id":"a","type":"counter","delta":1,"labels":{"__name__":"b"}}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			records, _, err := Read(strings.NewReader(tc.input), service.DefaultNamingPolicy())
+			require.Error(t, err)
+			require.Nil(t, records, "nothing is imported from a damaged export")
+		})
+	}
+}
+
+func TestReadNormalizesNames(t *testing.T) {
+	policy, err := service.NewNamingPolicy(service.DefaultAllowedNameChars, service.DefaultMaxNameLength, nil, true)
+	require.NoError(t, err)
+	records, _, err := Read(strings.NewReader(`{"id":"http.requests","type":"counter","delta":1,"labels":{"status-code":"200"}}`), policy)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, "http_requests", records[0].ID)
+	require.Equal(t, map[string]string{"status_code": "200"}, records[0].Labels)
+}
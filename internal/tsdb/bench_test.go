@@ -0,0 +1,111 @@
+package tsdb
+
+import (
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+// regularSamples возвращает n samples с интервалом 15 секунд и небольшим дрожанием,
+// как при сборе агентом.
+func regularSamples(n int, value func(i int) float64) []Sample {
+	rnd := rand.New(rand.NewSource(int64(n)))
+	samples := make([]Sample, n)
+	t := int64(1700000000000)
+	for i := range samples {
+		samples[i] = Sample{T: t, V: value(i)}
+		t += 15000 + rnd.Int63n(20) - 10
+	}
+	return samples
+}
+
+var benchmarkValues = []struct {
+	name  string
+	value func(i int) float64
+}{
+	{name: "constant", value: func(int) float64 { return 1 }},
+	{name: "counter", value: func(i int) float64 { return float64(i * 3) }},
+	{name: "gauge", value: func(i int) float64 { return 20 + float64(i%50)/10 }},
+	{name: "random", value: func(int) float64 { return rand.Float64() }},
+}
+
+const benchmarkSamples = 10000
+
+// liveBytes возвращает объем кучи, который остается занятым результатом build после
+// сборки мусора. Оба бенчмарка измеряют память одинаково: с учетом емкости срезов,
+// структур чанков и ряда.
+func liveBytes(build func() any) int {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	v := build()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(v)
+	return int(after.HeapAlloc) - int(before.HeapAlloc)
+}
+
+func appendCompressed(b *testing.B, samples []Sample) *Series {
+	series := newSeries(DefaultMaxSamplesPerChunk, DefaultChunkRange)
+	for _, sample := range samples {
+		if err := series.Append(sample.T, sample.V); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return series
+}
+
+func appendNaive(samples []Sample) []Sample {
+	var stored []Sample
+	for _, sample := range samples {
+		stored = append(stored, sample)
+	}
+	return stored
+}
+
+// BenchmarkCompressed сравнивается с BenchmarkNaive по метрике bytes/sample.
+func BenchmarkCompressed(b *testing.B) {
+	for _, bm := range benchmarkValues {
+		samples := regularSamples(benchmarkSamples, bm.value)
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				appendCompressed(b, samples)
+			}
+			b.StopTimer()
+			size := liveBytes(func() any { return appendCompressed(b, samples) })
+			b.ReportMetric(float64(size)/benchmarkSamples, "bytes/sample")
+		})
+	}
+}
+
+func BenchmarkNaive(b *testing.B) {
+	for _, bm := range benchmarkValues {
+		samples := regularSamples(benchmarkSamples, bm.value)
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				appendNaive(samples)
+			}
+			b.StopTimer()
+			size := liveBytes(func() any { return appendNaive(samples) })
+			b.ReportMetric(float64(size)/benchmarkSamples, "bytes/sample")
+		})
+	}
+}
+
+func BenchmarkQuery(b *testing.B) {
+	series := newSeries(DefaultMaxSamplesPerChunk, DefaultChunkRange)
+	samples := regularSamples(benchmarkSamples, benchmarkValues[2].value)
+	for _, sample := range samples {
+		if err := series.Append(sample.T, sample.V); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ResetTimer()
+	for range b.N {
+		if _, err := series.Samples(samples[0].T, samples[len(samples)-1].T); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
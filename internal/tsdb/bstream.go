@@ -0,0 +1,94 @@
+package tsdb
+
+import "io"
+
+// bstream - поток битов, дописываемый с конца. Биты внутри байта идут от старшего
+// к младшему.
+type bstream struct {
+	stream []byte
+	// free - количество незанятых бит в последнем байте.
+	free uint8
+}
+
+func (b *bstream) bytes() []byte {
+	return b.stream
+}
+
+func (b *bstream) writeBit(bit bool) {
+	if b.free == 0 {
+		b.stream = append(b.stream, 0)
+		b.free = 8
+	}
+	if bit {
+		b.stream[len(b.stream)-1] |= 1 << (b.free - 1)
+	}
+	b.free--
+}
+
+// writeBits записывает младшие nbits бит значения u, начиная со старшего из них.
+func (b *bstream) writeBits(u uint64, nbits int) {
+	u <<= 64 - uint(nbits)
+	for nbits >= 8 {
+		b.writeByte(byte(u >> 56))
+		u <<= 8
+		nbits -= 8
+	}
+	for nbits > 0 {
+		b.writeBit(u>>63 == 1)
+		u <<= 1
+		nbits--
+	}
+}
+
+func (b *bstream) writeByte(byt byte) {
+	if b.free == 0 {
+		b.stream = append(b.stream, byt)
+		return
+	}
+	// Старшие биты дописываются в текущий байт, младшие - в новый.
+	b.stream[len(b.stream)-1] |= byt >> (8 - b.free)
+	b.stream = append(b.stream, byt<<b.free)
+}
+
+// bstreamReader читает биты, записанные bstream.
+type bstreamReader struct {
+	stream []byte
+	// pos - номер следующего бита.
+	pos int
+}
+
+func newBReader(stream []byte) *bstreamReader {
+	return &bstreamReader{stream: stream}
+}
+
+func (r *bstreamReader) readBit() (bool, error) {
+	if r.pos >= len(r.stream)*8 {
+		return false, io.EOF
+	}
+	bit := r.stream[r.pos/8]&(1<<(7-r.pos%8)) != 0
+	r.pos++
+	return bit, nil
+}
+
+func (r *bstreamReader) readBits(nbits int) (uint64, error) {
+	if r.pos+nbits > len(r.stream)*8 {
+		return 0, io.EOF
+	}
+	var u uint64
+	for nbits > 0 {
+		offset := r.pos % 8
+		if offset == 0 && nbits >= 8 {
+			u = u<<8 | uint64(r.stream[r.pos/8])
+			r.pos += 8
+			nbits -= 8
+			continue
+		}
+		u <<= 1
+		if r.stream[r.pos/8]&(1<<(7-offset)) != 0 {
+			u |= 1
+		}
+		r.pos++
+		nbits--
+	}
+	return u, nil
+}
@@ -0,0 +1,335 @@
+// Package tsdb хранит историю значений рядов в сжатых блоках (чанках). Сжатие
+// уменьшает 16 байт на sample до 2-4 байт для регулярно собираемых счетчиков и
+// постоянных значений; см. BenchmarkCompressed и BenchmarkNaive.
+package tsdb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// ErrOutOfOrder возвращается при добавлении sample с меткой времени не позже последней.
+var ErrOutOfOrder = errors.New("out of order sample")
+
+// Sample - значение ряда в момент T (миллисекунды Unix).
+type Sample struct {
+	T int64
+	V float64
+}
+
+// sampleSize - размер несжатого Sample в памяти.
+const sampleSize = 16
+
+// Chunk хранит samples, сжатые по схеме Gorilla (Facebook, VLDB 2015):
+//   - первая метка времени записывается целиком, вторая - как разница с первой,
+//     остальные - как разница разниц (delta-of-delta), которая для регулярного
+//     сбора почти всегда равна нулю и занимает один бит;
+//   - первое значение записывается целиком, остальные - как XOR с предыдущим, из
+//     которого сохраняются только значащие биты между ведущими и хвостовыми нулями.
+//
+// Chunk не безопасен для конкурентного использования, доступ к нему синхронизирует Series.
+type Chunk struct {
+	b   bstream
+	num int
+
+	minT, maxT int64
+	tDelta     int64
+	v          float64
+	leading    uint8
+	trailing   uint8
+}
+
+func NewChunk() *Chunk {
+	return &Chunk{leading: 0xff}
+}
+
+func (c *Chunk) NumSamples() int {
+	return c.num
+}
+
+func (c *Chunk) MinTime() int64 {
+	return c.minT
+}
+
+func (c *Chunk) MaxTime() int64 {
+	return c.maxT
+}
+
+// Size возвращает количество байт, занятых сжатыми данными.
+func (c *Chunk) Size() int {
+	return len(c.b.bytes())
+}
+
+func (c *Chunk) Append(t int64, v float64) error {
+	if c.num > 0 && t <= c.maxT {
+		return fmt.Errorf("%w: %d <= %d", ErrOutOfOrder, t, c.maxT)
+	}
+
+	switch c.num {
+	case 0:
+		c.b.writeBits(uint64(t), 64)
+		c.b.writeBits(math.Float64bits(v), 64)
+		c.minT = t
+	case 1:
+		c.tDelta = t - c.maxT
+		writeVarbitUint(&c.b, uint64(c.tDelta))
+		c.writeValue(v)
+	default:
+		delta := t - c.maxT
+		writeDoD(&c.b, delta-c.tDelta)
+		c.tDelta = delta
+		c.writeValue(v)
+	}
+
+	c.maxT = t
+	c.v = v
+	c.num++
+	return nil
+}
+
+// writeDoD кодирует разницу разниц меток времени префиксным кодом: 0 - разница
+// не изменилась, 10/110/1110 - значение в 14/17/20 битах, 1111 - в 64 битах.
+func writeDoD(b *bstream, dod int64) {
+	switch {
+	case dod == 0:
+		b.writeBit(false)
+	case bitRange(dod, 14):
+		b.writeBits(0b10, 2)
+		b.writeBits(uint64(dod), 14)
+	case bitRange(dod, 17):
+		b.writeBits(0b110, 3)
+		b.writeBits(uint64(dod), 17)
+	case bitRange(dod, 20):
+		b.writeBits(0b1110, 4)
+		b.writeBits(uint64(dod), 20)
+	default:
+		b.writeBits(0b1111, 4)
+		b.writeBits(uint64(dod), 64)
+	}
+}
+
+// bitRange сообщает, помещается ли x в nbits бит со знаком.
+func bitRange(x int64, nbits uint8) bool {
+	return -((1<<(nbits-1))-1) <= x && x <= 1<<(nbits-1)
+}
+
+// writeVarbitUint записывает первую разницу меток времени: 0 - 1 бит,
+// до 2^14 - 16 бит, иначе 68 бит.
+func writeVarbitUint(b *bstream, u uint64) {
+	switch {
+	case u == 0:
+		b.writeBit(false)
+	case u < 1<<14:
+		b.writeBits(0b10, 2)
+		b.writeBits(u, 14)
+	default:
+		b.writeBits(0b11, 2)
+		b.writeBits(u, 64)
+	}
+}
+
+func (c *Chunk) writeValue(v float64) {
+	delta := math.Float64bits(v) ^ math.Float64bits(c.v)
+	if delta == 0 {
+		c.b.writeBit(false)
+		return
+	}
+	c.b.writeBit(true)
+
+	leading := uint8(bits.LeadingZeros64(delta))
+	trailing := uint8(bits.TrailingZeros64(delta))
+	// Количество ведущих нулей хранится в 5 битах.
+	if leading >= 32 {
+		leading = 31
+	}
+
+	if c.leading != 0xff && leading >= c.leading && trailing >= c.trailing {
+		// Значащие биты помещаются в окно предыдущего значения.
+		c.b.writeBit(false)
+		c.b.writeBits(delta>>c.trailing, 64-int(c.leading)-int(c.trailing))
+		return
+	}
+
+	c.leading, c.trailing = leading, trailing
+	sigbits := 64 - leading - trailing
+	c.b.writeBit(true)
+	c.b.writeBits(uint64(leading), 5)
+	// 64 значащих бита не помещаются в 6 бит и записываются как 0.
+	c.b.writeBits(uint64(sigbits), 6)
+	c.b.writeBits(delta>>trailing, int(sigbits))
+}
+
+// Iterator возвращает итератор по samples, добавленным к моменту вызова.
+func (c *Chunk) Iterator() *Iterator {
+	stream := c.b.bytes()
+	return &Iterator{br: newBReader(stream[:len(stream):len(stream)]), total: c.num, leading: 0xff}
+}
+
+// Samples декодирует все samples чанка.
+func (c *Chunk) Samples() ([]Sample, error) {
+	samples := make([]Sample, 0, c.num)
+	it := c.Iterator()
+	for it.Next() {
+		samples = append(samples, it.At())
+	}
+	return samples, it.Err()
+}
+
+// Iterator последовательно декодирует samples чанка.
+type Iterator struct {
+	br    *bstreamReader
+	total int
+	read  int
+	err   error
+
+	t        int64
+	v        float64
+	tDelta   int64
+	leading  uint8
+	trailing uint8
+}
+
+func (it *Iterator) At() Sample {
+	return Sample{T: it.t, V: it.v}
+}
+
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+func (it *Iterator) Next() bool {
+	if it.err != nil || it.read >= it.total {
+		return false
+	}
+
+	switch it.read {
+	case 0:
+		t, err := it.br.readBits(64)
+		if err != nil {
+			return it.fail(err)
+		}
+		v, err := it.br.readBits(64)
+		if err != nil {
+			return it.fail(err)
+		}
+		it.t, it.v = int64(t), math.Float64frombits(v)
+	case 1:
+		delta, err := readVarbitUint(it.br)
+		if err != nil {
+			return it.fail(err)
+		}
+		it.tDelta = int64(delta)
+		it.t += it.tDelta
+		if err := it.readValue(); err != nil {
+			return it.fail(err)
+		}
+	default:
+		dod, err := readDoD(it.br)
+		if err != nil {
+			return it.fail(err)
+		}
+		it.tDelta += dod
+		it.t += it.tDelta
+		if err := it.readValue(); err != nil {
+			return it.fail(err)
+		}
+	}
+	it.read++
+	return true
+}
+
+func (it *Iterator) fail(err error) bool {
+	it.err = fmt.Errorf("decode sample %d: %w", it.read, err)
+	return false
+}
+
+func readDoD(br *bstreamReader) (int64, error) {
+	// Префикс - до четырех единиц, завершаемых нулем.
+	prefix := 0
+	for prefix < 4 {
+		bit, err := br.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if !bit {
+			break
+		}
+		prefix++
+	}
+
+	var nbits int
+	switch prefix {
+	case 0:
+		return 0, nil
+	case 1:
+		nbits = 14
+	case 2:
+		nbits = 17
+	case 3:
+		nbits = 20
+	default:
+		nbits = 64
+	}
+	u, err := br.readBits(nbits)
+	if err != nil {
+		return 0, err
+	}
+	if nbits == 64 {
+		return int64(u), nil
+	}
+	// Восстанавливаем знак nbits-битного числа.
+	if u > 1<<(nbits-1) {
+		return int64(u) - 1<<nbits, nil
+	}
+	return int64(u), nil
+}
+
+func readVarbitUint(br *bstreamReader) (uint64, error) {
+	bit, err := br.readBit()
+	if err != nil || !bit {
+		return 0, err
+	}
+	bit, err = br.readBit()
+	if err != nil {
+		return 0, err
+	}
+	if !bit {
+		return br.readBits(14)
+	}
+	return br.readBits(64)
+}
+
+func (it *Iterator) readValue() error {
+	changed, err := it.br.readBit()
+	if err != nil || !changed {
+		return err
+	}
+	newWindow, err := it.br.readBit()
+	if err != nil {
+		return err
+	}
+	if newWindow {
+		leading, err := it.br.readBits(5)
+		if err != nil {
+			return err
+		}
+		sigbits, err := it.br.readBits(6)
+		if err != nil {
+			return err
+		}
+		if sigbits == 0 {
+			sigbits = 64
+		}
+		it.leading = uint8(leading)
+		it.trailing = 64 - uint8(leading) - uint8(sigbits)
+	}
+	sigbits := 64 - int(it.leading) - int(it.trailing)
+	delta, err := it.br.readBits(sigbits)
+	if err != nil {
+		return err
+	}
+	it.v = math.Float64frombits(math.Float64bits(it.v) ^ delta<<it.trailing)
+	return nil
+}
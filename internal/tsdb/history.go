@@ -0,0 +1,364 @@
+package tsdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Tier - уровень хранения агрегатов: значения ряда за каждый интервал Resolution
+// сворачиваются в min/max/sum/count и хранятся Retention.
+type Tier struct {
+	Resolution time.Duration
+	Retention  time.Duration
+}
+
+// Point - значение ряда или агрегат за интервал [T, T+Resolution). Для исходных
+// samples Resolution равен нулю, а Min, Max и Sum совпадают со значением.
+type Point struct {
+	T          int64
+	Resolution time.Duration
+	Min        float64
+	Max        float64
+	Sum        float64
+	Count      uint64
+}
+
+func (p Point) Avg() float64 {
+	return p.Sum / float64(p.Count)
+}
+
+// add добавляет в агрегат другой агрегат. NaN пропускаются, чтобы не испортить
+// минимум и максимум.
+func (p *Point) add(other Point) {
+	if other.Count == 0 || (other.Count == 1 && math.IsNaN(other.Sum)) {
+		return
+	}
+	if p.Count == 0 {
+		p.Min, p.Max = other.Min, other.Max
+	} else {
+		p.Min = math.Min(p.Min, other.Min)
+		p.Max = math.Max(p.Max, other.Max)
+	}
+	p.Sum += other.Sum
+	p.Count += other.Count
+}
+
+// tier хранит агрегаты уровня в четырех Store. compactedUntil - граница, до которой
+// (не включая) агрегаты уже посчитаны.
+type tier struct {
+	Tier
+	min, max, sum, count *Store
+	compactedUntil       atomic.Int64
+}
+
+func newTier(t Tier) *tier {
+	// Чанк покрывает DefaultMaxSamplesPerChunk агрегатов, а не DefaultChunkRange.
+	chunkRange := WithChunkRange(t.Resolution * DefaultMaxSamplesPerChunk)
+	return &tier{
+		Tier:  t,
+		min:   NewStore(chunkRange),
+		max:   NewStore(chunkRange),
+		sum:   NewStore(chunkRange),
+		count: NewStore(chunkRange),
+	}
+}
+
+func (t *tier) append(id string, p Point) error {
+	at := time.UnixMilli(p.T)
+	if err := t.min.Append(id, at, p.Min); err != nil {
+		return err
+	}
+	if err := t.max.Append(id, at, p.Max); err != nil {
+		return err
+	}
+	if err := t.sum.Append(id, at, p.Sum); err != nil {
+		return err
+	}
+	return t.count.Append(id, at, float64(p.Count))
+}
+
+func (t *tier) query(id string, mint, maxt int64) ([]Point, error) {
+	from, to := time.UnixMilli(mint), time.UnixMilli(maxt)
+	mins, err := t.min.Query(id, from, to)
+	if err != nil {
+		return nil, err
+	}
+	maxs, err := t.max.Query(id, from, to)
+	if err != nil {
+		return nil, err
+	}
+	sums, err := t.sum.Query(id, from, to)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := t.count.Query(id, from, to)
+	if err != nil {
+		return nil, err
+	}
+	// Агрегаты дописываются во все Store одновременно, поэтому samples совпадают.
+	if len(maxs) != len(mins) || len(sums) != len(mins) || len(counts) != len(mins) {
+		return nil, fmt.Errorf("rollup of %s at %v is inconsistent", id, t.Resolution)
+	}
+	points := make([]Point, len(mins))
+	for i := range mins {
+		points[i] = Point{
+			T:          mins[i].T,
+			Resolution: t.Resolution,
+			Min:        mins[i].V,
+			Max:        maxs[i].V,
+			Sum:        sums[i].V,
+			Count:      uint64(counts[i].V),
+		}
+	}
+	return points, nil
+}
+
+func (t *tier) seriesIDs() []string {
+	return t.min.SeriesIDs()
+}
+
+func (t *tier) delete(id string) {
+	t.min.Delete(id)
+	t.max.Delete(id)
+	t.sum.Delete(id)
+	t.count.Delete(id)
+}
+
+func (t *tier) truncate(before time.Time) {
+	t.min.Truncate(before)
+	t.max.Truncate(before)
+	t.sum.Truncate(before)
+	t.count.Truncate(before)
+}
+
+// History хранит исходные samples рядов в течение rawRetention и их агрегаты по
+// уровням. Compact сворачивает завершенные интервалы каждого уровня из предыдущего
+// (первый уровень - из исходных samples) и удаляет данные старше срока хранения.
+type History struct {
+	raw          *Store
+	rawRetention time.Duration
+	tiers        []*tier
+	now          func() time.Time
+
+	compactMu sync.Mutex
+}
+
+// NewHistory проверяет уровни и создает хранилище истории. Уровни упорядочиваются
+// по разрешению. Разрешение каждого уровня должно быть кратно разрешению
+// предыдущего и меньше срока хранения предыдущего уровня, чтобы данные успевали
+// свернуться до удаления.
+func NewHistory(rawRetention time.Duration, tiers []Tier) (*History, error) {
+	if rawRetention <= 0 {
+		return nil, fmt.Errorf("raw retention must be positive")
+	}
+	tiers = append([]Tier(nil), tiers...)
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].Resolution < tiers[j].Resolution })
+
+	h := &History{raw: NewStore(), rawRetention: rawRetention, now: time.Now}
+	prev := Tier{Resolution: time.Millisecond, Retention: rawRetention}
+	for _, t := range tiers {
+		switch {
+		case t.Resolution <= prev.Resolution || t.Resolution%prev.Resolution != 0:
+			return nil, fmt.Errorf("resolution %v must be a multiple of %v", t.Resolution, prev.Resolution)
+		case t.Resolution >= prev.Retention:
+			return nil, fmt.Errorf("resolution %v must be less than the previous retention %v", t.Resolution, prev.Retention)
+		case t.Retention < prev.Retention:
+			return nil, fmt.Errorf("retention %v of resolution %v must not be less than the previous retention %v", t.Retention, t.Resolution, prev.Retention)
+		}
+		h.tiers = append(h.tiers, newTier(t))
+		prev = t
+	}
+	return h, nil
+}
+
+// Append дописывает значение ряда id в исходные samples.
+func (h *History) Append(id string, t time.Time, v float64) error {
+	return h.raw.Append(id, t, v)
+}
+
+// Set дописывает значение ряда id в исходные samples. Значение с той же меткой
+// времени, что и последнее, заменяет его.
+func (h *History) Set(id string, t time.Time, v float64) error {
+	return h.raw.Set(id, t, v)
+}
+
+// Delete удаляет историю ряда id на всех уровнях.
+func (h *History) Delete(id string) {
+	h.raw.Delete(id)
+	for _, t := range h.tiers {
+		t.delete(id)
+	}
+}
+
+// Query возвращает историю ряда id в диапазоне [from, to]. Выбирается самый
+// детальный уровень, который еще хранит данные за from. Интервалы, которые этот
+// уровень еще не свернул, берутся из более детальных уровней, поэтому разрешение
+// точек может уменьшаться к концу диапазона.
+func (h *History) Query(id string, from, to time.Time) ([]Point, error) {
+	now := h.now()
+	level := len(h.tiers)
+	if !now.Add(-h.rawRetention).After(from) {
+		level = 0
+	} else {
+		for i, t := range h.tiers {
+			if !now.Add(-t.Retention).After(from) {
+				level = i + 1
+				break
+			}
+		}
+	}
+
+	mint, maxt := from.UnixMilli(), to.UnixMilli()
+	var points []Point
+	for ; level > 0; level-- {
+		t := h.tiers[level-1]
+		compactedUntil := t.compactedUntil.Load()
+		if compactedUntil == 0 {
+			continue
+		}
+		if mint <= maxt && mint < compactedUntil {
+			tierPoints, err := t.query(id, mint, min(maxt, compactedUntil-1))
+			if err != nil {
+				return nil, err
+			}
+			points = append(points, tierPoints...)
+		}
+		mint = max(mint, compactedUntil)
+	}
+	if mint > maxt {
+		return points, nil
+	}
+
+	rawPoints, err := h.queryRaw(id, mint, maxt)
+	if err != nil {
+		return nil, err
+	}
+	return append(points, rawPoints...), nil
+}
+
+// Compact сворачивает завершенные интервалы на всех уровнях и удаляет данные
+// старше срока хранения.
+func (h *History) Compact() error {
+	h.compactMu.Lock()
+	defer h.compactMu.Unlock()
+
+	now := h.now()
+	source := h.queryRaw
+	sourceUntil := now.UnixMilli()
+	sourceIDs := h.raw.SeriesIDs
+	var errs []error
+	for _, t := range h.tiers {
+		if err := h.compactTier(t, source, sourceIDs, sourceUntil); err != nil {
+			errs = append(errs, fmt.Errorf("compact %v: %w", t.Resolution, err))
+		}
+		source, sourceUntil, sourceIDs = t.query, t.compactedUntil.Load(), t.seriesIDs
+	}
+
+	// Удаление идет после сворачивания, чтобы данные попали на следующий уровень.
+	h.raw.Truncate(now.Add(-h.rawRetention))
+	for _, t := range h.tiers {
+		t.truncate(now.Add(-t.Retention))
+	}
+	return errors.Join(errs...)
+}
+
+func (h *History) queryRaw(id string, mint, maxt int64) ([]Point, error) {
+	samples, err := h.raw.Query(id, time.UnixMilli(mint), time.UnixMilli(maxt))
+	if err != nil {
+		return nil, err
+	}
+	points := make([]Point, len(samples))
+	for i, sample := range samples {
+		points[i] = Point{T: sample.T, Min: sample.V, Max: sample.V, Sum: sample.V, Count: 1}
+	}
+	return points, nil
+}
+
+// compactTier сворачивает в t интервалы от t.compactedUntil до последнего интервала,
+// который целиком покрыт источником. Ошибка в одном ряду не останавливает
+// сворачивание остальных: граница сдвигается в любом случае, иначе ряд с ошибкой
+// сворачивался бы повторно при каждом запуске.
+func (h *History) compactTier(t *tier, source func(id string, mint, maxt int64) ([]Point, error), sourceIDs func() []string, sourceUntil int64) error {
+	resolution := t.Resolution.Milliseconds()
+	start := t.compactedUntil.Load()
+	end := floor(sourceUntil, resolution)
+	if end <= start {
+		return nil
+	}
+
+	var errs []error
+	for _, id := range sourceIDs() {
+		if err := compactSeries(t, id, source, start, end); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", id, err))
+		}
+	}
+	t.compactedUntil.Store(end)
+	return errors.Join(errs...)
+}
+
+func compactSeries(t *tier, id string, source func(id string, mint, maxt int64) ([]Point, error), start, end int64) error {
+	points, err := source(id, start, end-1)
+	if err != nil {
+		return err
+	}
+	resolution := t.Resolution.Milliseconds()
+	var bucket Point
+	for _, p := range points {
+		bucketT := floor(p.T, resolution)
+		if bucket.Count > 0 && bucketT != bucket.T {
+			if err := t.append(id, bucket); err != nil {
+				return err
+			}
+			bucket = Point{}
+		}
+		bucket.T = bucketT
+		bucket.add(p)
+	}
+	if bucket.Count > 0 {
+		return t.append(id, bucket)
+	}
+	return nil
+}
+
+// floor округляет t вниз до кратного step.
+func floor(t, step int64) int64 {
+	r := t % step
+	if r < 0 {
+		r += step
+	}
+	return t - r
+}
+
+// Run запускает Compact каждые interval до отмены ctx.
+func (h *History) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := h.Compact(); err != nil {
+				fmt.Printf("Error compacting history: %v\n", err)
+			}
+		}
+	}
+}
+
+// Stats возвращает количество исходных samples и байт, занятых ими и агрегатами.
+func (h *History) Stats() (samples, bytes int) {
+	samples, bytes = h.raw.Stats()
+	for _, t := range h.tiers {
+		for _, store := range []*Store{t.min, t.max, t.sum, t.count} {
+			_, b := store.Stats()
+			bytes += b
+		}
+	}
+	return samples, bytes
+}
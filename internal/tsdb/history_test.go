@@ -0,0 +1,157 @@
+package tsdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHistoryValidatesTiers(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     time.Duration
+		tiers   []Tier
+		wantErr bool
+	}{
+		{
+			name:  "day, month and year",
+			raw:   24 * time.Hour,
+			tiers: []Tier{{Resolution: time.Hour, Retention: 365 * 24 * time.Hour}, {Resolution: time.Minute, Retention: 30 * 24 * time.Hour}},
+		},
+		{
+			name: "raw only",
+			raw:  time.Hour,
+		},
+		{
+			name:    "zero raw retention",
+			wantErr: true,
+		},
+		{
+			name:    "resolution not a multiple",
+			raw:     24 * time.Hour,
+			tiers:   []Tier{{Resolution: time.Minute, Retention: 48 * time.Hour}, {Resolution: 90 * time.Second, Retention: 96 * time.Hour}},
+			wantErr: true,
+		},
+		{
+			name:    "resolution beyond previous retention",
+			raw:     time.Hour,
+			tiers:   []Tier{{Resolution: time.Hour, Retention: 48 * time.Hour}},
+			wantErr: true,
+		},
+		{
+			name:    "retention shorter than previous",
+			raw:     24 * time.Hour,
+			tiers:   []Tier{{Resolution: time.Minute, Retention: time.Hour}},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewHistory(tc.raw, tc.tiers)
+			if tc.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func newTestHistory(t *testing.T) (*History, *time.Time) {
+	t.Helper()
+	h, err := NewHistory(time.Hour, []Tier{
+		{Resolution: time.Minute, Retention: 24 * time.Hour},
+		{Resolution: time.Hour, Retention: 7 * 24 * time.Hour},
+	})
+	require.NoError(t, err)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { return now }
+	return h, &now
+}
+
+// fill дописывает в ряд значения 0, 1, 2... каждые 15 секунд в течение d и
+// запускает сворачивание раз в минуту.
+func fill(t *testing.T, h *History, now *time.Time, id string, d time.Duration) int {
+	t.Helper()
+	n := 0
+	for end := now.Add(d); now.Before(end); *now = now.Add(15 * time.Second) {
+		require.NoError(t, h.Append(id, *now, float64(n)))
+		n++
+		if now.Second() == 45 {
+			require.NoError(t, h.Compact())
+		}
+	}
+	return n
+}
+
+func TestHistoryRollups(t *testing.T) {
+	h, now := newTestHistory(t)
+	start := *now
+	fill(t, h, now, "temp", 3*time.Hour)
+
+	points, err := h.Query("temp", start, start.Add(2*time.Minute-time.Millisecond))
+	require.NoError(t, err)
+	require.Equal(t, []Point{
+		{T: start.UnixMilli(), Resolution: time.Minute, Min: 0, Max: 3, Sum: 0 + 1 + 2 + 3, Count: 4},
+		{T: start.Add(time.Minute).UnixMilli(), Resolution: time.Minute, Min: 4, Max: 7, Sum: 4 + 5 + 6 + 7, Count: 4},
+	}, points, "data older than raw retention is served from minute rollups")
+	require.Equal(t, 5.5, points[1].Avg())
+
+	hourly, err := h.tiers[1].query("temp", start.UnixMilli(), now.UnixMilli())
+	require.NoError(t, err)
+	require.Len(t, hourly, 2, "the third hour is not complete yet")
+	require.Equal(t, Point{T: start.Add(time.Hour).UnixMilli(), Resolution: time.Hour, Min: 240, Max: 479, Sum: (240 + 479) * 240 / 2, Count: 240}, hourly[1])
+
+	from := now.Add(-10 * time.Minute)
+	points, err = h.Query("temp", from, *now)
+	require.NoError(t, err)
+	require.Len(t, points, 40)
+	for _, p := range points {
+		require.Zero(t, p.Resolution, "recent data is served raw")
+	}
+}
+
+func TestHistoryQueryStitchesTiers(t *testing.T) {
+	h, now := newTestHistory(t)
+	start := *now
+	total := fill(t, h, now, "requests", 26*time.Hour+30*time.Minute)
+
+	points, err := h.Query("requests", start, *now)
+	require.NoError(t, err)
+
+	var count uint64
+	for i, p := range points {
+		count += p.Count
+		if i > 0 {
+			require.Greater(t, p.T, points[i-1].T)
+			require.LessOrEqual(t, p.Resolution, points[i-1].Resolution)
+		}
+	}
+	require.Equal(t, uint64(total), count, "every sample is counted exactly once")
+	require.Equal(t, time.Hour, points[0].Resolution)
+	require.Zero(t, points[len(points)-1].Resolution)
+}
+
+func TestHistoryRetention(t *testing.T) {
+	h, now := newTestHistory(t)
+	start := *now
+	fill(t, h, now, "requests", 26*time.Hour)
+
+	samples, err := h.raw.Query("requests", start, *now)
+	require.NoError(t, err)
+	// Исходные samples удаляются чанками по 120 штук (30 минут).
+	require.LessOrEqual(t, len(samples), int(90*time.Minute/(15*time.Second)))
+	require.GreaterOrEqual(t, samples[0].T, now.Add(-90*time.Minute).UnixMilli())
+
+	minutes, err := h.tiers[0].query("requests", start.UnixMilli(), now.UnixMilli())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, minutes[0].T, now.Add(-26*time.Hour).UnixMilli())
+	require.Less(t, len(minutes), 26*60)
+
+	h.Delete("requests")
+	points, err := h.Query("requests", start, *now)
+	require.NoError(t, err)
+	require.Empty(t, points)
+}
@@ -0,0 +1,135 @@
+package tsdb
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxSamplesPerChunk - 120 samples соответствуют двум часам при сборе раз в минуту.
+	DefaultMaxSamplesPerChunk = 120
+	DefaultChunkRange         = 2 * time.Hour
+)
+
+// Series - история одного ряда: закрытые чанки и головной чанк, в который
+// дописываются новые samples. Головной чанк закрывается, когда в нем набирается
+// maxSamples samples или когда новый sample выходит за chunkRange от его начала.
+type Series struct {
+	maxSamples int
+	chunkRange int64
+
+	mu     sync.Mutex
+	chunks []*Chunk
+	head   *Chunk
+}
+
+func newSeries(maxSamples int, chunkRange time.Duration) *Series {
+	return &Series{maxSamples: maxSamples, chunkRange: chunkRange.Milliseconds(), head: NewChunk()}
+}
+
+// Append дописывает sample. Метки времени должны строго возрастать.
+func (s *Series) Append(t int64, v float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.append(t, v)
+}
+
+func (s *Series) append(t int64, v float64) error {
+	if s.head.NumSamples() > 0 {
+		if t <= s.head.MaxTime() {
+			return s.head.Append(t, v)
+		}
+		if s.head.NumSamples() >= s.maxSamples || t-s.head.MinTime() >= s.chunkRange {
+			s.chunks = append(s.chunks, s.head)
+			s.head = NewChunk()
+		}
+	}
+	return s.head.Append(t, v)
+}
+
+// Set дописывает sample, а если его метка времени совпадает с последней - заменяет
+// значение последнего sample. Чанк дописывается только в конец, поэтому головной
+// чанк для замены перекодируется заново.
+func (s *Series) Set(t int64, v float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.head.NumSamples() == 0 || t != s.head.MaxTime() {
+		return s.append(t, v)
+	}
+
+	samples, err := s.head.Samples()
+	if err != nil {
+		return err
+	}
+	samples[len(samples)-1].V = v
+	head := NewChunk()
+	for _, sample := range samples {
+		if err := head.Append(sample.T, sample.V); err != nil {
+			return err
+		}
+	}
+	s.head = head
+	return nil
+}
+
+// Samples возвращает samples с метками времени в диапазоне [mint, maxt].
+func (s *Series) Samples(mint, maxt int64) ([]Sample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var samples []Sample
+	for _, chunk := range s.allChunks() {
+		if chunk.NumSamples() == 0 || chunk.MaxTime() < mint || chunk.MinTime() > maxt {
+			continue
+		}
+		it := chunk.Iterator()
+		for it.Next() {
+			sample := it.At()
+			if sample.T > maxt {
+				break
+			}
+			if sample.T >= mint {
+				samples = append(samples, sample)
+			}
+		}
+		if err := it.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return samples, nil
+}
+
+// Truncate удаляет чанки, все samples которых старше mint, и сообщает, остались ли
+// у ряда samples. Чанки удаляются целиком, поэтому часть более старых samples может
+// сохраниться до закрытия чанка.
+func (s *Series) Truncate(mint int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keep := 0
+	for keep < len(s.chunks) && s.chunks[keep].MaxTime() < mint {
+		keep++
+	}
+	s.chunks = append(s.chunks[:0], s.chunks[keep:]...)
+	if len(s.chunks) == 0 && s.head.NumSamples() > 0 && s.head.MaxTime() < mint {
+		s.head = NewChunk()
+	}
+	return len(s.chunks) > 0 || s.head.NumSamples() > 0
+}
+
+// Stats возвращает количество samples и байт, занятых сжатыми данными ряда.
+func (s *Series) Stats() (samples, bytes int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, chunk := range s.allChunks() {
+		samples += chunk.NumSamples()
+		bytes += chunk.Size()
+	}
+	return samples, bytes
+}
+
+func (s *Series) allChunks() []*Chunk {
+	return append(s.chunks[:len(s.chunks):len(s.chunks)], s.head)
+}
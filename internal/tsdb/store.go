@@ -0,0 +1,141 @@
+package tsdb
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type Option func(*Store)
+
+// WithMaxSamplesPerChunk задает количество samples, после которого чанк закрывается.
+func WithMaxSamplesPerChunk(n int) Option {
+	return func(s *Store) {
+		if n > 0 {
+			s.maxSamples = n
+		}
+	}
+}
+
+// WithChunkRange задает промежуток времени, который может покрывать один чанк.
+func WithChunkRange(d time.Duration) Option {
+	return func(s *Store) {
+		if d > 0 {
+			s.chunkRange = d
+		}
+	}
+}
+
+// Store хранит историю рядов по их идентификаторам, например model.Metrics.FullID.
+type Store struct {
+	maxSamples int
+	chunkRange time.Duration
+
+	mu     sync.RWMutex
+	series map[string]*Series
+}
+
+func NewStore(opts ...Option) *Store {
+	s := &Store{
+		maxSamples: DefaultMaxSamplesPerChunk,
+		chunkRange: DefaultChunkRange,
+		series:     make(map[string]*Series),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Append дописывает sample в ряд id, создавая ряд при необходимости.
+func (s *Store) Append(id string, t time.Time, v float64) error {
+	return s.getOrCreate(id).Append(t.UnixMilli(), v)
+}
+
+// Set дописывает sample в ряд id или заменяет последний sample с той же меткой времени.
+func (s *Store) Set(id string, t time.Time, v float64) error {
+	return s.getOrCreate(id).Set(t.UnixMilli(), v)
+}
+
+func (s *Store) getOrCreate(id string) *Series {
+	s.mu.RLock()
+	series, ok := s.series[id]
+	s.mu.RUnlock()
+	if ok {
+		return series
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if series, ok := s.series[id]; ok {
+		return series
+	}
+	series = newSeries(s.maxSamples, s.chunkRange)
+	s.series[id] = series
+	return series
+}
+
+// Query возвращает samples ряда id в диапазоне [from, to]. Для неизвестного ряда
+// возвращается пустой результат.
+func (s *Store) Query(id string, from, to time.Time) ([]Sample, error) {
+	s.mu.RLock()
+	series, ok := s.series[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+	return series.Samples(from.UnixMilli(), to.UnixMilli())
+}
+
+// Delete удаляет ряд id.
+func (s *Store) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.series, id)
+}
+
+// Truncate удаляет данные старше before и ряды, у которых не осталось samples.
+func (s *Store) Truncate(before time.Time) {
+	mint := before.UnixMilli()
+	for _, id := range s.SeriesIDs() {
+		s.mu.RLock()
+		series, ok := s.series[id]
+		s.mu.RUnlock()
+		if !ok || series.Truncate(mint) {
+			continue
+		}
+
+		s.mu.Lock()
+		// Ряд мог получить новые samples после проверки.
+		if current, ok := s.series[id]; ok && current == series && !series.Truncate(mint) {
+			delete(s.series, id)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// SeriesIDs возвращает отсортированные идентификаторы рядов.
+func (s *Store) SeriesIDs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0, len(s.series))
+	for id := range s.series {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Stats возвращает общее количество samples и байт сжатых данных.
+func (s *Store) Stats() (samples, bytes int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, series := range s.series {
+		n, b := series.Stats()
+		samples += n
+		bytes += b
+	}
+	return samples, bytes
+}
@@ -0,0 +1,202 @@
+package tsdb
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// requireSamples сравнивает значения побитово, чтобы NaN считались равными.
+func requireSamples(t *testing.T, expected, actual []Sample) {
+	t.Helper()
+	require.Len(t, actual, len(expected))
+	for i := range expected {
+		require.Equal(t, expected[i].T, actual[i].T, "timestamp of sample %d", i)
+		require.Equal(t, math.Float64bits(expected[i].V), math.Float64bits(actual[i].V), "value of sample %d", i)
+	}
+}
+
+func TestChunkRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	tests := []struct {
+		name    string
+		samples []Sample
+	}{
+		{
+			name:    "single sample",
+			samples: []Sample{{T: 1700000000000, V: 42}},
+		},
+		{
+			name:    "regular interval and constant value",
+			samples: regularSamples(200, func(int) float64 { return 1 }),
+		},
+		{
+			name:    "growing counter",
+			samples: regularSamples(200, func(i int) float64 { return float64(i * 17) }),
+		},
+		{
+			name:    "random gauge",
+			samples: regularSamples(200, func(int) float64 { return rnd.NormFloat64() * 1e6 }),
+		},
+		{
+			name: "special values",
+			samples: []Sample{
+				{T: -5000, V: math.NaN()},
+				{T: 0, V: math.Inf(1)},
+				{T: 1, V: math.Inf(-1)},
+				{T: 2, V: -0.0},
+				{T: 3, V: math.SmallestNonzeroFloat64},
+				{T: 4, V: -math.MaxFloat64},
+				{T: 5, V: 0},
+			},
+		},
+		{
+			name: "irregular timestamps",
+			samples: []Sample{
+				{T: 0, V: 1},
+				{T: 1 << 20, V: 2},
+				{T: 1<<20 + 1, V: 3},
+				{T: 1<<20 + 10000, V: 4},
+				{T: 1<<20 + 10000 + 1<<16, V: 5},
+				{T: 1<<20 + 10000 + 1<<16 + 1<<19, V: 6},
+				{T: math.MaxInt64 / 2, V: 7},
+				{T: math.MaxInt64/2 + 1, V: 8},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			chunk := NewChunk()
+			for _, sample := range tc.samples {
+				require.NoError(t, chunk.Append(sample.T, sample.V))
+			}
+			require.Equal(t, len(tc.samples), chunk.NumSamples())
+			require.Equal(t, tc.samples[0].T, chunk.MinTime())
+			require.Equal(t, tc.samples[len(tc.samples)-1].T, chunk.MaxTime())
+
+			samples, err := chunk.Samples()
+			require.NoError(t, err)
+			requireSamples(t, tc.samples, samples)
+		})
+	}
+}
+
+func TestChunkCompression(t *testing.T) {
+	chunk := NewChunk()
+	for _, sample := range regularSamples(120, func(i int) float64 { return float64(i) }) {
+		require.NoError(t, chunk.Append(sample.T, sample.V))
+	}
+	require.Less(t, chunk.Size(), 120*sampleSize/4, "regular counter must compress at least 4x")
+}
+
+func TestChunkOutOfOrder(t *testing.T) {
+	chunk := NewChunk()
+	require.NoError(t, chunk.Append(10, 1))
+	require.ErrorIs(t, chunk.Append(10, 2), ErrOutOfOrder)
+	require.ErrorIs(t, chunk.Append(5, 2), ErrOutOfOrder)
+	require.Equal(t, 1, chunk.NumSamples())
+}
+
+func TestChunkIteratorSnapshot(t *testing.T) {
+	chunk := NewChunk()
+	require.NoError(t, chunk.Append(1, 1))
+	require.NoError(t, chunk.Append(2, 2))
+	it := chunk.Iterator()
+	require.NoError(t, chunk.Append(3, 3))
+
+	var samples []Sample
+	for it.Next() {
+		samples = append(samples, it.At())
+	}
+	require.NoError(t, it.Err())
+	requireSamples(t, []Sample{{T: 1, V: 1}, {T: 2, V: 2}}, samples)
+}
+
+func TestStoreChunkRotation(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		interval time.Duration
+		chunks   int
+	}{
+		{
+			name:     "by sample count",
+			opts:     []Option{WithMaxSamplesPerChunk(10)},
+			interval: time.Second,
+			chunks:   10,
+		},
+		{
+			name:     "by time range",
+			opts:     []Option{WithChunkRange(time.Minute)},
+			interval: 10 * time.Second,
+			chunks:   17,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store := NewStore(tc.opts...)
+			start := time.UnixMilli(1700000000000)
+			for i := range 100 {
+				require.NoError(t, store.Append("requests", start.Add(time.Duration(i)*tc.interval), float64(i)))
+			}
+
+			series := store.series["requests"]
+			require.Len(t, series.allChunks(), tc.chunks)
+			samples, _ := series.Stats()
+			require.Equal(t, 100, samples)
+
+			all, err := store.Query("requests", start, start.Add(100*tc.interval))
+			require.NoError(t, err)
+			require.Len(t, all, 100)
+			for i, sample := range all {
+				require.Equal(t, float64(i), sample.V)
+			}
+
+			// Диапазон пересекает границы чанков.
+			part, err := store.Query("requests", start.Add(5*tc.interval), start.Add(25*tc.interval))
+			require.NoError(t, err)
+			require.Len(t, part, 21)
+			require.Equal(t, float64(5), part[0].V)
+			require.Equal(t, float64(25), part[20].V)
+		})
+	}
+}
+
+func TestStoreAppendOutOfOrder(t *testing.T) {
+	store := NewStore(WithMaxSamplesPerChunk(2))
+	start := time.UnixMilli(0)
+	require.NoError(t, store.Append("a", start.Add(time.Second), 1))
+	require.NoError(t, store.Append("a", start.Add(2*time.Second), 2))
+	require.ErrorIs(t, store.Append("a", start.Add(2*time.Second), 3), ErrOutOfOrder)
+	require.ErrorIs(t, store.Append("a", start, 3), ErrOutOfOrder)
+	require.NoError(t, store.Append("b", start, 3), "series are independent")
+
+	samples, err := store.Query("a", start, start.Add(time.Minute))
+	require.NoError(t, err)
+	requireSamples(t, []Sample{{T: 1000, V: 1}, {T: 2000, V: 2}}, samples)
+	require.Equal(t, []string{"a", "b"}, store.SeriesIDs())
+
+	samples, err = store.Query("missing", start, start.Add(time.Minute))
+	require.NoError(t, err)
+	require.Empty(t, samples)
+}
+
+func TestStoreSetReplacesLastSample(t *testing.T) {
+	store := NewStore(WithMaxSamplesPerChunk(4))
+	start := time.UnixMilli(0)
+	for i, v := range []float64{1, 2, 3} {
+		require.NoError(t, store.Set("a", start.Add(time.Duration(i)*time.Second), v))
+	}
+	require.NoError(t, store.Set("a", start.Add(2*time.Second), 4))
+	require.NoError(t, store.Set("a", start.Add(2*time.Second), 5))
+	require.ErrorIs(t, store.Set("a", start.Add(time.Second), 6), ErrOutOfOrder)
+
+	samples, err := store.Query("a", start, start.Add(time.Minute))
+	require.NoError(t, err)
+	requireSamples(t, []Sample{{T: 0, V: 1}, {T: 1000, V: 2}, {T: 2000, V: 5}}, samples)
+}
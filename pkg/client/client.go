@@ -0,0 +1,342 @@
+// Package client позволяет любому Go-приложению отправлять собственные метрики на сервер go-metrics.
+//
+// Значения агрегируются в памяти процесса и периодически отправляются одним пакетом
+// через POST /updates/. Счетчики накапливают приращения между отправками, gauge
+// отправляет последнее установленное значение.
+//
+//	c := client.New("http://localhost:8080", client.WithFlushInterval(5*time.Second))
+//	defer c.Close()
+//
+//	requests := c.Counter("http_requests", map[string]string{"handler": "/api"})
+//	requests.Inc()
+//	c.Gauge("queue_size", nil).Set(42)
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultFlushInterval = 10 * time.Second
+
+	counterType = "counter"
+	gaugeType   = "gauge"
+	updatesPath = "/updates/"
+
+	tenantHeader = "X-Tenant-ID"
+)
+
+var ErrClosed = errors.New("client is closed")
+
+// ErrRejected возвращается, если сервер окончательно отклонил пакет (ответ 4xx, кроме
+// 408 и 429). Повтор такого пакета не поможет, поэтому его значения отбрасываются.
+var ErrRejected = errors.New("metrics rejected by server")
+
+// ErrInvalidValue возвращается Flush, если gauge содержит NaN или ±Inf. JSON не умеет
+// передавать такие значения, поэтому они отбрасываются, а остальные метрики пакета
+// отправляются.
+var ErrInvalidValue = errors.New("gauge value is not finite")
+
+// metric повторяет JSON-формат метрики сервера.
+type metric struct {
+	ID     string            `json:"id"`
+	MType  string            `json:"type"`
+	Delta  *int64            `json:"delta,omitempty"`
+	Value  *float64          `json:"value,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type Client struct {
+	endpoint      string
+	httpClient    *http.Client
+	flushInterval time.Duration
+	constLabels   map[string]string
+	onError       func(error)
+	tenant        string
+	token         string
+
+	mu       sync.Mutex
+	counters map[string]*Counter
+	gauges   map[string]*Gauge
+
+	flushMu   sync.Mutex
+	closeOnce sync.Once
+	closed    chan struct{}
+	done      chan struct{}
+}
+
+type Option func(*Client)
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithFlushInterval задает период фоновой отправки. Значение <= 0 отключает фоновую
+// отправку, тогда метрики отправляются только вызовами Flush и Close.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.flushInterval = interval
+	}
+}
+
+// WithLabels добавляет метки ко всем метрикам клиента, например имя сервиса и хоста.
+func WithLabels(labels map[string]string) Option {
+	return func(c *Client) {
+		c.constLabels = labels
+	}
+}
+
+// WithErrorHandler задает обработчик ошибок фоновой отправки. По умолчанию ошибки игнорируются,
+// а неотправленные приращения счетчиков отправляются при следующей попытке, если сервер
+// не отклонил их окончательно (ErrRejected). Отброшенные gauge со значением NaN или ±Inf
+// передаются как ErrInvalidValue.
+func WithErrorHandler(onError func(error)) Option {
+	return func(c *Client) {
+		c.onError = onError
+	}
+}
+
+// WithTenant отправляет метрики в пространство арендатора через заголовок X-Tenant-ID.
+func WithTenant(tenant string) Option {
+	return func(c *Client) {
+		c.tenant = tenant
+	}
+}
+
+// WithToken передает API-токен в заголовке Authorization: Bearer.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// New создает клиент и запускает фоновую отправку. serverURL - адрес сервера, например http://localhost:8080.
+func New(serverURL string, opts ...Option) *Client {
+	c := &Client{
+		endpoint:      strings.TrimRight(serverURL, "/") + updatesPath,
+		httpClient:    http.DefaultClient,
+		flushInterval: DefaultFlushInterval,
+		onError:       func(error) {},
+		counters:      make(map[string]*Counter),
+		gauges:        make(map[string]*Gauge),
+		closed:        make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.flushInterval > 0 {
+		go c.loop()
+	} else {
+		close(c.done)
+	}
+	return c
+}
+
+func (c *Client) loop() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), c.flushInterval)
+			if err := c.Flush(ctx); err != nil {
+				c.onError(err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Counter возвращает счетчик с заданным именем и метками. Повторный вызов с теми же
+// аргументами возвращает тот же счетчик.
+func (c *Client) Counter(name string, labels map[string]string) *Counter {
+	labels = c.mergeLabels(labels)
+	key := seriesKey(name, labels)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counter, ok := c.counters[key]
+	if !ok {
+		counter = &Counter{name: name, labels: labels}
+		c.counters[key] = counter
+	}
+	return counter
+}
+
+// Gauge возвращает gauge с заданным именем и метками. Повторный вызов с теми же
+// аргументами возвращает тот же gauge.
+func (c *Client) Gauge(name string, labels map[string]string) *Gauge {
+	labels = c.mergeLabels(labels)
+	key := seriesKey(name, labels)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	gauge, ok := c.gauges[key]
+	if !ok {
+		gauge = &Gauge{name: name, labels: labels}
+		c.gauges[key] = gauge
+	}
+	return gauge
+}
+
+// Flush отправляет накопленные значения. Сервер записывает пакет атомарно, поэтому при
+// ошибке приращения счетчиков возвращаются обратно и будут отправлены при следующей
+// попытке, а gauge снова помечаются измененными. Пакет, который сервер окончательно
+// отклонил (ErrRejected), отбрасывается, чтобы не блокировать следующие отправки.
+// Gauge со значением NaN или ±Inf не отправляются, Flush сообщает о них ErrInvalidValue.
+func (c *Client) Flush(ctx context.Context) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	c.mu.Lock()
+	counters := make([]*Counter, 0, len(c.counters))
+	for _, counter := range c.counters {
+		counters = append(counters, counter)
+	}
+	gauges := make([]*Gauge, 0, len(c.gauges))
+	for _, gauge := range c.gauges {
+		gauges = append(gauges, gauge)
+	}
+	c.mu.Unlock()
+
+	batch := make([]metric, 0, len(counters)+len(gauges))
+	taken := make([]int64, len(counters))
+	for i, counter := range counters {
+		delta := counter.take()
+		taken[i] = delta
+		if delta != 0 {
+			batch = append(batch, metric{ID: counter.name, MType: counterType, Delta: &delta, Labels: counter.labels})
+		}
+	}
+	flushed := make([]*Gauge, 0, len(gauges))
+	var invalid []error
+	for _, gauge := range gauges {
+		value, ok := gauge.take()
+		if !ok {
+			continue
+		}
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			invalid = append(invalid, fmt.Errorf("%w: %s = %v", ErrInvalidValue, seriesKey(gauge.name, gauge.labels), value))
+			continue
+		}
+		batch = append(batch, metric{ID: gauge.name, MType: gaugeType, Value: &value, Labels: gauge.labels})
+		flushed = append(flushed, gauge)
+	}
+	if len(batch) == 0 {
+		return errors.Join(invalid...)
+	}
+
+	if err := c.send(ctx, batch); err != nil {
+		if !errors.Is(err, ErrRejected) {
+			for i, counter := range counters {
+				counter.Add(taken[i])
+			}
+			for _, gauge := range flushed {
+				gauge.restore()
+			}
+		}
+		return errors.Join(append([]error{err}, invalid...)...)
+	}
+	return errors.Join(invalid...)
+}
+
+// Close останавливает фоновую отправку и отправляет оставшиеся значения.
+func (c *Client) Close() error {
+	return c.CloseContext(context.Background())
+}
+
+func (c *Client) CloseContext(ctx context.Context) error {
+	err := ErrClosed
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		<-c.done
+		err = c.Flush(ctx)
+	})
+	return err
+}
+
+func (c *Client) send(ctx context.Context, batch []metric) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("encode metrics: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.tenant != "" {
+		req.Header.Set(tenantHeader, c.tenant)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send metrics: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("server responded %s: %s", resp.Status, strings.TrimSpace(string(message)))
+		if isPermanent(resp.StatusCode) {
+			return fmt.Errorf("send metrics: %w: %w", ErrRejected, err)
+		}
+		return fmt.Errorf("send metrics: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// isPermanent сообщает, что ошибка относится к самому пакету и не исчезнет при повторе.
+func isPermanent(status int) bool {
+	return status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests
+}
+
+func (c *Client) mergeLabels(labels map[string]string) map[string]string {
+	if len(c.constLabels) == 0 && len(labels) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(c.constLabels)+len(labels))
+	for name, value := range c.constLabels {
+		merged[name] = value
+	}
+	for name, value := range labels {
+		merged[name] = value
+	}
+	return merged
+}
+
+func seriesKey(name string, labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for labelName := range labels {
+		names = append(names, labelName)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString(name)
+	for _, labelName := range names {
+		fmt.Fprintf(&sb, "\x00%s=%s", labelName, labels[labelName])
+	}
+	return sb.String()
+}
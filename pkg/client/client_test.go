@@ -0,0 +1,171 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeServer struct {
+	mu      sync.Mutex
+	batches [][]metric
+	fail    atomic.Bool
+	reject  atomic.Bool
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != updatesPath || r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	if s.fail.Load() {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if s.reject.Load() {
+		http.Error(w, "Invalid metric name", http.StatusBadRequest)
+		return
+	}
+	var batch []metric
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.batches = append(s.batches, batch)
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *fakeServer) received() [][]metric {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]metric(nil), s.batches...)
+}
+
+func TestClientFlushAggregates(t *testing.T) {
+	fake := &fakeServer{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c := New(server.URL, WithFlushInterval(0), WithLabels(map[string]string{"service": "api"}))
+	requests := c.Counter("requests", map[string]string{"handler": "/"})
+	requests.Inc()
+	requests.Add(4)
+	require.Same(t, requests, c.Counter("requests", map[string]string{"handler": "/"}), "Same series must return the same handle")
+	c.Gauge("queue", nil).Set(1)
+	c.Gauge("queue", nil).Set(7)
+
+	require.NoError(t, c.Flush(context.Background()))
+	batches := fake.received()
+	require.Len(t, batches, 1)
+	require.Len(t, batches[0], 2)
+	for _, m := range batches[0] {
+		require.Equal(t, "api", m.Labels["service"])
+		switch m.ID {
+		case "requests":
+			require.Equal(t, counterType, m.MType)
+			require.Equal(t, int64(5), *m.Delta)
+			require.Equal(t, "/", m.Labels["handler"])
+		case "queue":
+			require.Equal(t, gaugeType, m.MType)
+			require.Equal(t, 7.0, *m.Value)
+		default:
+			t.Fatalf("unexpected metric %s", m.ID)
+		}
+	}
+
+	require.NoError(t, c.Flush(context.Background()))
+	require.Len(t, fake.received(), 1, "Nothing changed, nothing must be sent")
+	require.NoError(t, c.Close())
+}
+
+func TestClientRetainsValuesOnFailure(t *testing.T) {
+	fake := &fakeServer{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c := New(server.URL, WithFlushInterval(0))
+	c.Counter("requests", nil).Add(3)
+	c.Gauge("queue", nil).Set(2)
+
+	fake.fail.Store(true)
+	require.Error(t, c.Flush(context.Background()))
+
+	c.Counter("requests", nil).Add(2)
+	fake.fail.Store(false)
+	require.NoError(t, c.Close(), "Close must flush remaining values")
+
+	batches := fake.received()
+	require.Len(t, batches, 1)
+	for _, m := range batches[0] {
+		if m.ID == "requests" {
+			require.Equal(t, int64(5), *m.Delta)
+		} else {
+			require.Equal(t, 2.0, *m.Value)
+		}
+	}
+	require.ErrorIs(t, c.Close(), ErrClosed)
+}
+
+func TestClientDropsRejectedValues(t *testing.T) {
+	fake := &fakeServer{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c := New(server.URL, WithFlushInterval(0))
+	c.Counter("requests", nil).Add(3)
+
+	fake.reject.Store(true)
+	require.ErrorIs(t, c.Flush(context.Background()), ErrRejected)
+
+	c.Counter("requests", nil).Add(2)
+	fake.reject.Store(false)
+	require.NoError(t, c.Close())
+
+	batches := fake.received()
+	require.Len(t, batches, 1)
+	require.Equal(t, int64(2), *batches[0][0].Delta, "Rejected increments must not be resent")
+}
+
+func TestClientSkipsNonFiniteGauges(t *testing.T) {
+	fake := &fakeServer{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c := New(server.URL, WithFlushInterval(0))
+	c.Gauge("ratio", nil).Set(math.NaN())
+	c.Gauge("temperature", nil).Set(21.5)
+	c.Counter("requests", nil).Add(3)
+	require.ErrorIs(t, c.Flush(context.Background()), ErrInvalidValue)
+
+	c.Counter("requests", nil).Add(2)
+	require.NoError(t, c.Close())
+
+	batches := fake.received()
+	require.Len(t, batches, 2)
+	require.Len(t, batches[0], 2, "Other metrics must be sent without the NaN gauge")
+	require.Equal(t, int64(2), *batches[1][0].Delta, "Sent increments must not be resent")
+}
+
+func TestClientBackgroundFlush(t *testing.T) {
+	fake := &fakeServer{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c := New(server.URL, WithFlushInterval(10*time.Millisecond))
+	defer c.Close()
+	c.Counter("requests", nil).Inc()
+
+	require.Eventually(t, func() bool {
+		return len(fake.received()) == 1
+	}, time.Second, 5*time.Millisecond)
+}
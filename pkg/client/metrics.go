@@ -0,0 +1,59 @@
+package client
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Counter накапливает приращения между отправками. Безопасен для конкурентного использования.
+type Counter struct {
+	name    string
+	labels  map[string]string
+	pending atomic.Int64
+}
+
+func (c *Counter) Inc() {
+	c.pending.Add(1)
+}
+
+func (c *Counter) Add(delta int64) {
+	c.pending.Add(delta)
+}
+
+func (c *Counter) take() int64 {
+	return c.pending.Swap(0)
+}
+
+// Gauge хранит последнее установленное значение. Значение отправляется, только если
+// оно было установлено после предыдущей отправки. Безопасен для конкурентного использования.
+type Gauge struct {
+	name   string
+	labels map[string]string
+
+	mu    sync.Mutex
+	value float64
+	dirty bool
+}
+
+func (g *Gauge) Set(value float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.value = value
+	g.dirty = true
+}
+
+func (g *Gauge) take() (float64, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.dirty {
+		return 0, false
+	}
+	g.dirty = false
+	return g.value, true
+}
+
+func (g *Gauge) restore() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.dirty = true
+}